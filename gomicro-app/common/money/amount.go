@@ -0,0 +1,87 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of decimal places an Amount holds. It covers the minor units
+// of every supported currency, so amounts stored in a NUMERIC(19,4) column are exact.
+const Scale = 4
+
+var scaleFactor = big.NewInt(10000)
+
+// Amount is a fixed-point decimal counted in 10^-Scale units, stored as NUMERIC(19,4)
+type Amount int64
+
+// ParseAmount parses a decimal string such as "12.34" or "-0.5" without going through floats
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "eE/") {
+		return 0, fmt.Errorf("money: invalid amount %q", s)
+	}
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(scaleFactor))
+	if !scaled.IsInt() {
+		return 0, fmt.Errorf("money: amount %q has more than %d decimal places", s, Scale)
+	}
+	if !scaled.Num().IsInt64() {
+		return 0, ErrOverflow
+	}
+	return Amount(scaled.Num().Int64()), nil
+}
+
+// Rat returns the amount as an exact rational number
+func (a Amount) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(int64(a)), scaleFactor)
+}
+
+// StringFixed formats the amount with exactly the given number of decimal places (0-Scale)
+func (a Amount) StringFixed(places int) string {
+	s := a.Rat().FloatString(Scale)
+	if places == 0 {
+		return s[:len(s)-Scale-1]
+	}
+	return s[:len(s)-Scale+places]
+}
+
+// String formats the amount with Scale decimal places
+func (a Amount) String() string {
+	return a.StringFixed(Scale)
+}
+
+// Value implements driver.Valuer, writing the amount as an exact decimal string
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan implements sql.Scanner for NUMERIC columns
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case int64:
+		parsed, err := ParseAmount(strconv.FormatInt(v, 10))
+		*a = parsed
+		return err
+	case float64:
+		// Only drivers without a decimal type (e.g. SQLite) return floats
+		parsed, err := ParseAmount(strconv.FormatFloat(v, 'f', Scale, 64))
+		*a = parsed
+		return err
+	case []byte:
+		parsed, err := ParseAmount(string(v))
+		*a = parsed
+		return err
+	case string:
+		parsed, err := ParseAmount(v)
+		*a = parsed
+		return err
+	default:
+		return fmt.Errorf("money: cannot scan %T into Amount", src)
+	}
+}
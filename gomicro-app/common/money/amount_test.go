@@ -0,0 +1,95 @@
+package money
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr error
+		invalid bool
+	}{
+		{in: "12.34", want: 123400},
+		{in: "-0.5", want: -5000},
+		{in: " 7 ", want: 70000},
+		{in: "0.0001", want: 1},
+		{in: "922337203685477.5807", want: 9223372036854775807},
+		{in: "-922337203685477.5808", want: -9223372036854775808},
+		{in: "922337203685477.5808", wantErr: ErrOverflow},
+		{in: "1.23456", invalid: true},
+		{in: "1e3", invalid: true},
+		{in: "1/3", invalid: true},
+		{in: "abc", invalid: true},
+		{in: "", invalid: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+		switch {
+		case tt.wantErr != nil:
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseAmount(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+		case tt.invalid:
+			if err == nil {
+				t.Errorf("ParseAmount(%q) = %d, want an error", tt.in, got)
+			}
+		case err != nil:
+			t.Errorf("ParseAmount(%q) error = %v", tt.in, err)
+		case got != tt.want:
+			t.Errorf("ParseAmount(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAmountStringFixed(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		places int
+		want   string
+	}{
+		{123400, 2, "12.34"},
+		{-5000, 2, "-0.50"},
+		{1, 4, "0.0001"},
+		{10000000, 0, "1000"},
+		{123450, 3, "12.345"},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.StringFixed(tt.places); got != tt.want {
+			t.Errorf("Amount(%d).StringFixed(%d) = %q, want %q", tt.amount, tt.places, got, tt.want)
+		}
+	}
+}
+
+func TestAmountScan(t *testing.T) {
+	tests := []struct {
+		src     interface{}
+		want    Amount
+		wantErr bool
+	}{
+		{src: nil, want: 0},
+		{src: []byte("12.3400"), want: 123400},
+		{src: "-1.5", want: -15000},
+		{src: int64(5), want: 50000},
+		{src: float64(1.25), want: 12500},
+		{src: true, wantErr: true},
+		{src: "12.34567", wantErr: true},
+	}
+	for _, tt := range tests {
+		var got Amount
+		err := got.Scan(tt.src)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Scan(%#v) = %d, want an error", tt.src, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Scan(%#v) error = %v", tt.src, err)
+		} else if got != tt.want {
+			t.Errorf("Scan(%#v) = %d, want %d", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package money
+
+import (
+	"os"
+	"strings"
+)
+
+// exponents maps supported ISO 4217 currency codes to their number of minor-unit digits
+var exponents = map[string]int{
+	"AUD": 2, "BHD": 3, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2, "DKK": 2,
+	"EUR": 2, "GBP": 2, "HKD": 2, "INR": 2, "JOD": 3, "JPY": 0, "KRW": 0,
+	"KWD": 3, "MXN": 2, "NOK": 2, "NZD": 2, "OMR": 3, "PLN": 2, "SEK": 2,
+	"SGD": 2, "TND": 3, "TRY": 2, "USD": 2, "ZAR": 2,
+}
+
+// Exponent returns the number of minor-unit digits of an ISO 4217 currency
+func Exponent(currency string) (int, error) {
+	exp, ok := exponents[currency]
+	if !ok {
+		return 0, ErrUnknownCurrency
+	}
+	return exp, nil
+}
+
+// ValidCurrency reports whether the currency code is supported
+func ValidCurrency(currency string) bool {
+	_, ok := exponents[currency]
+	return ok
+}
+
+// DefaultCurrency returns the currency used when none is given (DEFAULT_CURRENCY, USD by default)
+func DefaultCurrency() string {
+	if c := strings.ToUpper(os.Getenv("DEFAULT_CURRENCY")); ValidCurrency(c) {
+		return c
+	}
+	return "USD"
+}
@@ -0,0 +1,38 @@
+package money
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MigrateFloatColumn moves a legacy floating-point price column into the embedded
+// Money columns <prefix>amount and <prefix>currency, then drops it. Existing rows
+// are rounded to the minor unit of the given currency and assigned that currency.
+// It does nothing once the legacy column is gone.
+func MigrateFloatColumn(db *gorm.DB, model interface{}, column, prefix, currency string) error {
+	if !db.Migrator().HasColumn(model, column) {
+		return nil
+	}
+	exp, err := Exponent(currency)
+	if err != nil {
+		return err
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(
+			fmt.Sprintf("UPDATE ? SET %[1]samount = ROUND(CAST(? AS NUMERIC), %[2]d), %[1]scurrency = ?", prefix, exp),
+			clause.Table{Name: stmt.Schema.Table}, clause.Column{Name: column}, currency,
+		).Error
+		if err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(model, column)
+	})
+}
@@ -0,0 +1,215 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	ErrCurrencyMismatch = errors.New("money: currency mismatch")
+	ErrUnknownCurrency  = errors.New("money: unknown currency")
+	ErrOverflow         = errors.New("money: amount out of range")
+)
+
+// Money is an exact amount in an ISO 4217 currency. Embed it in GORM models with
+// `gorm:"embedded;embeddedPrefix:price_"` to get price_amount NUMERIC(19,4) and
+// price_currency CHAR(3) columns. Arithmetic never goes through floats and results
+// are rounded to the currency's minor unit using round-half-to-even.
+type Money struct {
+	Amount   Amount `gorm:"type:numeric(19,4);not null;default:0"`
+	Currency string `gorm:"type:char(3);not null;default:''"`
+}
+
+// New parses an amount in the given currency, rejecting more decimals than the currency has
+func New(amount, currency string) (Money, error) {
+	a, err := ParseAmount(amount)
+	if err != nil {
+		return Money{}, err
+	}
+	m := Money{Amount: a, Currency: strings.ToUpper(currency)}
+	if err := m.Validate(); err != nil {
+		return Money{}, err
+	}
+	return m, nil
+}
+
+// Zero returns a zero amount in the given currency
+func Zero(currency string) Money {
+	return Money{Currency: currency}
+}
+
+// Validate checks the currency and that the amount fits its minor unit
+func (m Money) Validate() error {
+	exp, err := Exponent(m.Currency)
+	if err != nil {
+		return err
+	}
+	if int64(m.Amount)%pow10(Scale-exp) != 0 {
+		return fmt.Errorf("money: %s amounts have at most %d decimal places", m.Currency, exp)
+	}
+	return nil
+}
+
+// Add returns m + o
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	sum := int64(m.Amount) + int64(o.Amount)
+	if (sum > int64(m.Amount)) != (o.Amount > 0) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: Amount(sum), Currency: m.Currency}, nil
+}
+
+// Sub returns m - o
+func (m Money) Sub(o Money) (Money, error) {
+	return m.Add(o.Neg())
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Mul returns m multiplied by an integer quantity
+func (m Money) Mul(quantity int64) (Money, error) {
+	product := new(big.Int).Mul(big.NewInt(int64(m.Amount)), big.NewInt(quantity))
+	if !product.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: Amount(product.Int64()), Currency: m.Currency}, nil
+}
+
+// MulRat returns m multiplied by an exact rate (a percentage, tax rate or exchange
+// rate), rounded half-to-even to the currency's minor unit
+func (m Money) MulRat(rate *big.Rat) (Money, error) {
+	return m.convert(m.Currency, rate)
+}
+
+// Convert returns m converted to another currency at the given rate (units of the
+// target currency per unit of m's currency), rounded to the target's minor unit
+func (m Money) Convert(currency string, rate *big.Rat) (Money, error) {
+	return m.convert(strings.ToUpper(currency), rate)
+}
+
+func (m Money) convert(currency string, rate *big.Rat) (Money, error) {
+	exp, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	// Work in minor units of the target currency, then scale back up to Amount units
+	minor := new(big.Rat).Mul(m.Amount.Rat(), rate)
+	minor.Mul(minor, new(big.Rat).SetInt64(pow10(exp)))
+	rounded := roundHalfEven(minor)
+	rounded.Mul(rounded, big.NewInt(pow10(Scale-exp)))
+	if !rounded.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: Amount(rounded.Int64()), Currency: currency}, nil
+}
+
+// Cmp compares m and o, returning -1, 0 or +1
+func (m Money) Cmp(o Money) (int, error) {
+	if m.Currency != o.Currency {
+		return 0, ErrCurrencyMismatch
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsPositive reports whether the amount is greater than zero
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// IsNegative reports whether the amount is below zero
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// String formats the amount with the currency's minor-unit digits, e.g. "12.50 USD"
+func (m Money) String() string {
+	return m.AmountString() + " " + m.Currency
+}
+
+// AmountString formats just the amount with the currency's minor-unit digits
+func (m Money) AmountString() string {
+	exp, err := Exponent(m.Currency)
+	if err != nil {
+		exp = Scale
+	}
+	return m.Amount.StringFixed(exp)
+}
+
+// MarshalJSON encodes money as {"amount":"12.50","currency":"USD"}. The amount is a
+// string so clients never parse it into a float by accident.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.AmountString(), m.Currency})
+}
+
+// UnmarshalJSON accepts {"amount":"12.50","currency":"USD"}, with the amount as a
+// string or number, or a bare amount in the default currency
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Amount   json.Number `json:"amount"`
+		Currency string      `json:"currency"`
+	}
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+	} else if err := json.Unmarshal(data, &raw.Amount); err != nil {
+		return fmt.Errorf("money: invalid amount %s", trimmed)
+	}
+	if raw.Currency == "" {
+		raw.Currency = DefaultCurrency()
+	}
+	parsed, err := New(raw.Amount.String(), raw.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// roundHalfEven rounds a rational number to the nearest integer, ties to even
+func roundHalfEven(r *big.Rat) *big.Int {
+	quo, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	// Compare twice the remainder with the denominator to find which side of .5 we're on
+	twice := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2))
+	cmp := twice.Cmp(r.Denom())
+	if cmp > 0 || (cmp == 0 && quo.Bit(0) == 1) {
+		if r.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	return quo
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,284 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func mustNew(t *testing.T, amount, currency string) Money {
+	t.Helper()
+	m, err := New(amount, currency)
+	if err != nil {
+		t.Fatalf("New(%q, %q): %v", amount, currency, err)
+	}
+	return m
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		amount, currency string
+		want             string
+		wantErr          bool
+	}{
+		{amount: "12.5", currency: "USD", want: "12.50 USD"},
+		{amount: "12.5", currency: "usd", want: "12.50 USD"},
+		{amount: "1.234", currency: "BHD", want: "1.234 BHD"},
+		{amount: "1000", currency: "JPY", want: "1000 JPY"},
+		{amount: "12.345", currency: "USD", wantErr: true},
+		{amount: "1.5", currency: "JPY", wantErr: true},
+		{amount: "1", currency: "XXX", wantErr: true},
+		{amount: "abc", currency: "USD", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := New(tt.amount, tt.currency)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("New(%q, %q) = %s, want an error", tt.amount, tt.currency, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("New(%q, %q) error = %v", tt.amount, tt.currency, err)
+		} else if got.String() != tt.want {
+			t.Errorf("New(%q, %q) = %s, want %s", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestAddSub(t *testing.T) {
+	sum, err := mustNew(t, "10.25", "USD").Add(mustNew(t, "0.75", "USD"))
+	if err != nil || sum.String() != "11.00 USD" {
+		t.Errorf("10.25 + 0.75 = %s, %v; want 11.00 USD", sum, err)
+	}
+	diff, err := mustNew(t, "1.00", "USD").Sub(mustNew(t, "2.50", "USD"))
+	if err != nil || diff.String() != "-1.50 USD" {
+		t.Errorf("1.00 - 2.50 = %s, %v; want -1.50 USD", diff, err)
+	}
+	if _, err := mustNew(t, "1", "USD").Add(mustNew(t, "1", "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("USD + EUR error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+}
+
+func TestAddOverflow(t *testing.T) {
+	tests := []struct {
+		a, b Amount
+	}{
+		{math.MaxInt64, 1},
+		{math.MinInt64, -1},
+		{math.MaxInt64/2 + 1, math.MaxInt64/2 + 1},
+	}
+	for _, tt := range tests {
+		_, err := Money{Amount: tt.a, Currency: "USD"}.Add(Money{Amount: tt.b, Currency: "USD"})
+		if !errors.Is(err, ErrOverflow) {
+			t.Errorf("%d + %d error = %v, want %v", tt.a, tt.b, err, ErrOverflow)
+		}
+	}
+	if _, err := (Money{Amount: math.MinInt64 + 1, Currency: "USD"}).Sub(Money{Amount: 2, Currency: "USD"}); !errors.Is(err, ErrOverflow) {
+		t.Errorf("MinInt64+1 - 2 error = %v, want %v", err, ErrOverflow)
+	}
+	if sum, err := (Money{Amount: math.MaxInt64, Currency: "USD"}).Add(Money{Currency: "USD"}); err != nil || sum.Amount != math.MaxInt64 {
+		t.Errorf("MaxInt64 + 0 = %d, %v; want MaxInt64", sum.Amount, err)
+	}
+}
+
+func TestMul(t *testing.T) {
+	got, err := mustNew(t, "2.50", "USD").Mul(3)
+	if err != nil || got.String() != "7.50 USD" {
+		t.Errorf("2.50 * 3 = %s, %v; want 7.50 USD", got, err)
+	}
+	got, err = mustNew(t, "2.50", "USD").Mul(-2)
+	if err != nil || got.String() != "-5.00 USD" {
+		t.Errorf("2.50 * -2 = %s, %v; want -5.00 USD", got, err)
+	}
+	if _, err := (Money{Amount: math.MaxInt64 / 2, Currency: "USD"}).Mul(3); !errors.Is(err, ErrOverflow) {
+		t.Errorf("MaxInt64/2 * 3 error = %v, want %v", err, ErrOverflow)
+	}
+}
+
+func TestMulRatRoundsHalfEven(t *testing.T) {
+	half := big.NewRat(1, 2)
+	tests := []struct {
+		amount, currency string
+		rate             *big.Rat
+		want             string
+	}{
+		{"0.05", "USD", half, "0.02 USD"},
+		{"0.15", "USD", half, "0.08 USD"},
+		{"-0.05", "USD", half, "-0.02 USD"},
+		{"-0.15", "USD", half, "-0.08 USD"},
+		{"0.10", "USD", big.NewRat(1, 3), "0.03 USD"},
+		{"0.20", "USD", big.NewRat(1, 3), "0.07 USD"},
+		{"101", "JPY", half, "50 JPY"},
+		{"103", "JPY", half, "52 JPY"},
+		{"0.005", "BHD", half, "0.002 BHD"},
+		{"19.99", "USD", big.NewRat(15, 100), "3.00 USD"},
+	}
+	for _, tt := range tests {
+		got, err := mustNew(t, tt.amount, tt.currency).MulRat(tt.rate)
+		if err != nil {
+			t.Errorf("%s %s * %s error = %v", tt.amount, tt.currency, tt.rate, err)
+		} else if got.String() != tt.want {
+			t.Errorf("%s %s * %s = %s, want %s", tt.amount, tt.currency, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestMulRatOverflow(t *testing.T) {
+	if _, err := (Money{Amount: math.MaxInt64 / 2, Currency: "USD"}).MulRat(big.NewRat(3, 1)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("MulRat overflow error = %v, want %v", err, ErrOverflow)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		amount, from, to string
+		rate             string
+		want             string
+		wantErr          error
+	}{
+		{amount: "10.00", from: "USD", to: "EUR", rate: "0.92", want: "9.20 EUR"},
+		{amount: "10.00", from: "USD", to: "eur", rate: "0.92", want: "9.20 EUR"},
+		{amount: "1.00", from: "USD", to: "JPY", rate: "150.5", want: "150 JPY"},
+		{amount: "1.00", from: "USD", to: "JPY", rate: "151.5", want: "152 JPY"},
+		{amount: "100", from: "JPY", to: "USD", rate: "0.0066666667", want: "0.67 USD"},
+		{amount: "1.00", from: "USD", to: "KWD", rate: "0.3075", want: "0.308 KWD"},
+		{amount: "1.00", from: "USD", to: "XXX", rate: "1", wantErr: ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		rate, ok := new(big.Rat).SetString(tt.rate)
+		if !ok {
+			t.Fatalf("bad test rate %q", tt.rate)
+		}
+		got, err := mustNew(t, tt.amount, tt.from).Convert(tt.to, rate)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Convert(%s %s -> %s) error = %v, want %v", tt.amount, tt.from, tt.to, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Convert(%s %s -> %s) error = %v", tt.amount, tt.from, tt.to, err)
+		} else if got.String() != tt.want {
+			t.Errorf("Convert(%s %s -> %s) = %s, want %s", tt.amount, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestCmp(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.00", "2.00", -1},
+		{"2.00", "2.00", 0},
+		{"-1.00", "-2.00", 1},
+	}
+	for _, tt := range tests {
+		got, err := mustNew(t, tt.a, "USD").Cmp(mustNew(t, tt.b, "USD"))
+		if err != nil || got != tt.want {
+			t.Errorf("Cmp(%s, %s) = %d, %v; want %d", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+	if _, err := mustNew(t, "1", "USD").Cmp(mustNew(t, "1", "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Cmp(USD, EUR) error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{Money{Amount: 125000, Currency: "USD"}, `{"amount":"12.50","currency":"USD"}`},
+		{Money{Amount: 10000000, Currency: "JPY"}, `{"amount":"1000","currency":"JPY"}`},
+		{Money{Amount: -12340, Currency: "BHD"}, `{"amount":"-1.234","currency":"BHD"}`},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.money)
+		if err != nil {
+			t.Errorf("Marshal(%s) error = %v", tt.money, err)
+		} else if string(got) != tt.want {
+			t.Errorf("Marshal(%s) = %s, want %s", tt.money, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	t.Setenv("DEFAULT_CURRENCY", "EUR")
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: `{"amount":"12.5","currency":"usd"}`, want: "12.50 USD"},
+		{in: `{"amount":12.5,"currency":"USD"}`, want: "12.50 USD"},
+		{in: `{"amount":"3.10"}`, want: "3.10 EUR"},
+		{in: `"3.10"`, want: "3.10 EUR"},
+		{in: `7`, want: "7.00 EUR"},
+		{in: `{"amount":"1.005","currency":"USD"}`, wantErr: true},
+		{in: `{"amount":"1","currency":"XXX"}`, wantErr: true},
+		{in: `{"amount":"1e3","currency":"USD"}`, wantErr: true},
+		{in: `true`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var got Money
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %s, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", tt.in, err)
+		} else if got.String() != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, m := range []Money{
+		mustNew(t, "0.01", "USD"),
+		mustNew(t, "-99999.99", "EUR"),
+		mustNew(t, "123", "KRW"),
+		mustNew(t, "0.001", "KWD"),
+	} {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal(%s): %v", m, err)
+		}
+		var got Money
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", data, err)
+		}
+		if got != m {
+			t.Errorf("round trip of %s = %s", m, got)
+		}
+	}
+}
+
+func TestRoundHalfEven(t *testing.T) {
+	tests := []struct {
+		num, denom int64
+		want       int64
+	}{
+		{5, 2, 2},
+		{7, 2, 4},
+		{-5, 2, -2},
+		{-7, 2, -4},
+		{11, 4, 3},
+		{9, 4, 2},
+		{-9, 4, -2},
+		{4, 1, 4},
+		{1, 3, 0},
+	}
+	for _, tt := range tests {
+		if got := roundHalfEven(big.NewRat(tt.num, tt.denom)); got.Int64() != tt.want {
+			t.Errorf("roundHalfEven(%d/%d) = %s, want %d", tt.num, tt.denom, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+)
+
+// RatePrecision is the number of decimal places exchange rates are kept with.
+// Rates are rounded to it before use, so a recorded rate reproduces the conversion.
+const RatePrecision = 10
+
+// RateDigits is the total number of digits of an exchange rate, as stored in a
+// NUMERIC(24,10) column
+const RateDigits = 24
+
+var ErrNoRate = errors.New("money: no exchange rate")
+
+var (
+	rateScale = new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(RatePrecision), nil))
+	maxRate   = new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(RateDigits-RatePrecision), nil))
+)
+
+// ParseRate parses a positive decimal exchange rate. It must have at most
+// RatePrecision decimal places and fit a NUMERIC(24,10) column, so it is stored
+// exactly and never rounds to zero.
+func ParseRate(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "eE/") || r.Sign() <= 0 {
+		return nil, fmt.Errorf("money: invalid exchange rate %q", s)
+	}
+	if !new(big.Rat).Mul(r, rateScale).IsInt() {
+		return nil, fmt.Errorf("money: exchange rate %q has more than %d decimal places", s, RatePrecision)
+	}
+	if r.Cmp(maxRate) >= 0 {
+		return nil, fmt.Errorf("money: exchange rate %q is out of range", s)
+	}
+	return r, nil
+}
+
+// FormatRate formats a rate with RatePrecision decimal places
+func FormatRate(r *big.Rat) string {
+	return r.FloatString(RatePrecision)
+}
+
+// RateTable holds exchange rates keyed by base and quote currency
+type RateTable map[[2]string]*big.Rat
+
+// Set records the rate for converting one unit of base into quote
+func (t RateTable) Set(base, quote string, rate *big.Rat) {
+	t[[2]string{base, quote}] = rate
+}
+
+// Rate returns the rate for converting from into to. It uses the direct pair, the
+// inverse of the opposite pair, or a cross rate through a currency both are quoted
+// against, in that order. The result is rounded to RatePrecision; a rate that
+// rounds to zero counts as missing.
+func (t RateTable) Rate(from, to string) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+	if r := t.pair(from, to); r != nil {
+		return nonZero(round(r))
+	}
+
+	// Try pivots in a fixed order so the same table always yields the same rate
+	var pivots []string
+	for key := range t {
+		pivots = append(pivots, key[0], key[1])
+	}
+	sort.Strings(pivots)
+	for _, pivot := range pivots {
+		if pivot == from || pivot == to {
+			continue
+		}
+		first, second := t.pair(from, pivot), t.pair(pivot, to)
+		if first != nil && second != nil {
+			return nonZero(round(new(big.Rat).Mul(first, second)))
+		}
+	}
+	return nil, ErrNoRate
+}
+
+func (t RateTable) pair(from, to string) *big.Rat {
+	if r, ok := t[[2]string{from, to}]; ok {
+		return r
+	}
+	if r, ok := t[[2]string{to, from}]; ok {
+		return new(big.Rat).Inv(r)
+	}
+	return nil
+}
+
+func nonZero(r *big.Rat) (*big.Rat, error) {
+	if r.Sign() == 0 {
+		return nil, ErrNoRate
+	}
+	return r, nil
+}
+
+func round(r *big.Rat) *big.Rat {
+	rounded, _ := new(big.Rat).SetString(FormatRate(r))
+	return rounded
+}
+
+// RateFile is the JSON layout of an exchange rate file: the value of one unit of
+// Base in each quoted currency, e.g. {"base": "USD", "rates": {"EUR": "0.92"}}
+type RateFile struct {
+	Base  string            `json:"base" binding:"required"`
+	Rates map[string]string `json:"rates" binding:"required"`
+}
+
+// Validate checks the currencies and rates in the file
+func (f *RateFile) Validate() error {
+	f.Base = strings.ToUpper(f.Base)
+	if !ValidCurrency(f.Base) {
+		return fmt.Errorf("%w %q", ErrUnknownCurrency, f.Base)
+	}
+	normalized := make(map[string]string, len(f.Rates))
+	for quote, rate := range f.Rates {
+		quote = strings.ToUpper(quote)
+		if !ValidCurrency(quote) || quote == f.Base {
+			return fmt.Errorf("%w %q", ErrUnknownCurrency, quote)
+		}
+		if _, err := ParseRate(rate); err != nil {
+			return err
+		}
+		normalized[quote] = rate
+	}
+	f.Rates = normalized
+	return nil
+}
+
+// LoadRateFile reads and validates an exchange rate file
+func LoadRateFile(path string) (*RateFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f RateFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("money: parse %s: %w", path, err)
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
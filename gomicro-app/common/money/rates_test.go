@@ -0,0 +1,109 @@
+package money
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "0.92", want: "23/25"},
+		{in: " 150.5 ", want: "301/2"},
+		{in: "0.0000000001", want: "1/10000000000"},
+		{in: "99999999999999.9999999999", want: "999999999999999999999999/10000000000"},
+		{in: "0.00000000001", wantErr: true},
+		{in: "1.12345678901", wantErr: true},
+		{in: "100000000000000", wantErr: true},
+		{in: "0", wantErr: true},
+		{in: "-1.5", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "1/3", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseRate(%q) = %s, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRate(%q) error = %v", tt.in, err)
+		} else if got.String() != tt.want {
+			t.Errorf("ParseRate(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRateFileValidate(t *testing.T) {
+	tests := []struct {
+		file    RateFile
+		wantErr bool
+	}{
+		{file: RateFile{Base: "usd", Rates: map[string]string{"eur": "0.92", "JPY": "150.5"}}},
+		{file: RateFile{Base: "XXX", Rates: map[string]string{"EUR": "0.92"}}, wantErr: true},
+		{file: RateFile{Base: "USD", Rates: map[string]string{"USD": "1"}}, wantErr: true},
+		{file: RateFile{Base: "USD", Rates: map[string]string{"EUR": "0.000000000001"}}, wantErr: true},
+		{file: RateFile{Base: "USD", Rates: map[string]string{"EUR": "1000000000000000"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		f := tt.file
+		err := f.Validate()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Validate(%v) succeeded, want an error", tt.file)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Validate(%v) error = %v", tt.file, err)
+			continue
+		}
+		if f.Base != "USD" || f.Rates["EUR"] != "0.92" || f.Rates["JPY"] != "150.5" {
+			t.Errorf("Validate(%v) = %v, want normalized currencies", tt.file, f)
+		}
+	}
+}
+
+func TestRateTableRate(t *testing.T) {
+	table := RateTable{}
+	table.Set("USD", "EUR", big.NewRat(92, 100))
+	table.Set("USD", "JPY", big.NewRat(150, 1))
+	table.Set("USD", "XAU", big.NewRat(1, 10000000000))
+
+	tests := []struct {
+		from, to string
+		want     string
+		wantErr  error
+	}{
+		{from: "USD", to: "USD", want: "1.0000000000"},
+		{from: "USD", to: "EUR", want: "0.9200000000"},
+		{from: "EUR", to: "USD", want: "1.0869565217"},
+		{from: "EUR", to: "JPY", want: "163.0434782609"},
+		{from: "JPY", to: "EUR", want: "0.0061333333"},
+		{from: "USD", to: "GBP", wantErr: ErrNoRate},
+		{from: "XAU", to: "EUR", want: "9200000000.0000000000"},
+		{from: "JPY", to: "XAU", wantErr: ErrNoRate},
+	}
+	for _, tt := range tests {
+		got, err := table.Rate(tt.from, tt.to)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Rate(%s, %s) error = %v, want %v", tt.from, tt.to, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Rate(%s, %s) error = %v", tt.from, tt.to, err)
+		} else if FormatRate(got) != tt.want {
+			t.Errorf("Rate(%s, %s) = %s, want %s", tt.from, tt.to, FormatRate(got), tt.want)
+		}
+	}
+}
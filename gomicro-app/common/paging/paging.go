@@ -0,0 +1,242 @@
+// Package paging implements the pagination and sorting conventions shared by list
+// endpoints: ?limit, ?sort, ?cursor and ?offset in, {data, next_cursor, total} out.
+package paging
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Page size limits for list endpoints
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrCursorSort    = errors.New("cursor was issued for a different sort")
+)
+
+// Sort whitelists the fields a list can be sorted by
+type Sort struct {
+	// Fields maps public sort names to non-null database columns
+	Fields map[string]string
+	// Default is the sort used when the request gives none, e.g. "-created_at"
+	Default string
+	// Computed lists fields selected by the query itself (such as a search rank);
+	// they can't be used in a WHERE clause, so they are paged with offsets
+	Computed map[string]bool
+}
+
+// Request holds the paging parameters of a list request
+type Request struct {
+	Limit  int
+	Offset int
+
+	sort     string
+	column   string
+	desc     bool
+	computed bool
+	after    *cursor
+}
+
+// cursor is the decoded form of next_cursor. It carries either the sort value and
+// ID of the last row (keyset) or the offset of the next page.
+type cursor struct {
+	Sort   string `json:"s"`
+	Offset int    `json:"o,omitempty"`
+	Value  string `json:"v,omitempty"`
+	Time   bool   `json:"t,omitempty"`
+	ID     string `json:"i,omitempty"`
+}
+
+// value returns the keyset value as a query argument. Times are bound as
+// time.Time so every driver compares them as timestamps rather than text.
+func (c *cursor) value() (interface{}, error) {
+	if !c.Time {
+		return c.Value, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, c.Value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return t, nil
+}
+
+// Page is the response envelope for list endpoints
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int64  `json:"total"`
+}
+
+// FromRequest reads limit, sort, cursor and offset from the query string. Limits
+// above MaxLimit are capped.
+func FromRequest(c *gin.Context, sort Sort) (*Request, error) {
+	r := &Request{Limit: DefaultLimit}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return nil, errors.New("limit must be a positive integer")
+		}
+		r.Limit = min(n, MaxLimit)
+	}
+
+	r.sort = c.DefaultQuery("sort", sort.Default)
+	name := strings.TrimPrefix(r.sort, "-")
+	column, ok := sort.Fields[name]
+	if !ok {
+		return nil, fmt.Errorf("sort must be one of %s", strings.Join(sortNames(sort), ", "))
+	}
+	r.column, r.desc, r.computed = column, strings.HasPrefix(r.sort, "-"), sort.Computed[name]
+
+	if offset := c.Query("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return nil, errors.New("offset must be a non-negative integer")
+		}
+		r.Offset = n
+	}
+
+	if token := c.Query("cursor"); token != "" {
+		cur, err := decodeCursor(token)
+		if err != nil {
+			return nil, err
+		}
+		if cur.Sort != r.sort {
+			return nil, ErrCursorSort
+		}
+		if cur.ID != "" {
+			if _, err := cur.value(); err != nil || r.computed {
+				return nil, ErrInvalidCursor
+			}
+			r.after = cur
+		} else {
+			r.Offset = cur.Offset
+		}
+	}
+	return r, nil
+}
+
+// Apply orders the query by the sort field, with the ID as tie-breaker, and
+// restricts it to the requested page. It fetches one extra row so NewPage can
+// tell whether there is a next page.
+func (r *Request) Apply(db *gorm.DB) *gorm.DB {
+	direction, op := "ASC", ">"
+	if r.desc {
+		direction, op = "DESC", "<"
+	}
+	db = db.Order(r.column + " " + direction).Order("id " + direction)
+	if r.after != nil {
+		value, _ := r.after.value()
+		db = db.Where(fmt.Sprintf("(%s, id) %s (?, ?)", r.column, op), value, r.after.ID)
+	}
+	return db.Offset(r.Offset).Limit(r.Limit + 1)
+}
+
+// NewPage builds the response envelope from the rows loaded with Apply and the
+// total number of matches
+func NewPage[T any](db *gorm.DB, r *Request, items []T, total int64) (*Page[T], error) {
+	page := &Page[T]{Data: items, Total: total}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	if len(items) <= r.Limit {
+		return page, nil
+	}
+
+	page.Data = items[:r.Limit]
+	next := cursor{Sort: r.sort}
+	if r.computed || r.Offset > 0 {
+		next.Offset = r.Offset + r.Limit
+	} else {
+		value, isTime, id, err := keysetValues(db, &page.Data[r.Limit-1], r.column)
+		if err != nil {
+			return nil, err
+		}
+		next.Value, next.Time, next.ID = value, isTime, id
+	}
+
+	encoded, err := json.Marshal(next)
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = base64.RawURLEncoding.EncodeToString(encoded)
+	return page, nil
+}
+
+// keysetValues reads the sort column and primary key of a row as strings, and
+// reports whether the sort column holds a time
+func keysetValues(db *gorm.DB, item interface{}, column string) (string, bool, string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(item); err != nil {
+		return "", false, "", err
+	}
+	field := stmt.Schema.LookUpField(column)
+	if field == nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		return "", false, "", fmt.Errorf("paging: %s has no column %s", stmt.Schema.Name, column)
+	}
+
+	row := reflect.ValueOf(item).Elem()
+	value, _ := field.ValueOf(context.Background(), row)
+	id, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(context.Background(), row)
+	_, isTime := value.(time.Time)
+	formattedValue, err := formatValue(value)
+	if err != nil {
+		return "", false, "", err
+	}
+	formattedID, err := formatValue(id)
+	return formattedValue, isTime, formattedID, err
+}
+
+// formatValue renders a column value in a form the database parses back to the same value
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), nil
+	case driver.Valuer:
+		dv, err := v.Value()
+		if err != nil {
+			return "", err
+		}
+		return formatValue(dv)
+	case []byte:
+		return string(v), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func decodeCursor(token string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cur cursor
+	if err := json.Unmarshal(data, &cur); err != nil || cur.Offset < 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &cur, nil
+}
+
+func sortNames(sort Sort) []string {
+	names := make([]string, 0, len(sort.Fields))
+	for name := range sort.Fields {
+		names = append(names, name, "-"+name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,181 @@
+package paging
+
+import (
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+)
+
+type item struct {
+	ID        uuid.UUID
+	Name      string
+	CreatedAt time.Time
+}
+
+var itemSort = Sort{
+	Fields: map[string]string{
+		"created_at": "created_at",
+		"name":       "name",
+		"rank":       "rank",
+	},
+	Default:  "-created_at",
+	Computed: map[string]bool{"rank": true},
+}
+
+func newRequest(t *testing.T, query url.Values) (*Request, error) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/items?"+query.Encode(), nil)
+	return FromRequest(c, itemSort)
+}
+
+func dryRun(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return db
+}
+
+func TestFromRequest(t *testing.T) {
+	tests := []struct {
+		query      url.Values
+		wantLimit  int
+		wantOffset int
+		wantErr    bool
+	}{
+		{query: url.Values{}, wantLimit: DefaultLimit},
+		{query: url.Values{"limit": {"5"}, "offset": {"10"}}, wantLimit: 5, wantOffset: 10},
+		{query: url.Values{"limit": {"1000"}}, wantLimit: MaxLimit},
+		{query: url.Values{"sort": {"name"}}, wantLimit: DefaultLimit},
+		{query: url.Values{"limit": {"0"}}, wantErr: true},
+		{query: url.Values{"limit": {"x"}}, wantErr: true},
+		{query: url.Values{"offset": {"-1"}}, wantErr: true},
+		{query: url.Values{"sort": {"price"}}, wantErr: true},
+		{query: url.Values{"cursor": {"not a cursor"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		r, err := newRequest(t, tt.query)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("FromRequest(%s) succeeded, want an error", tt.query.Encode())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FromRequest(%s) error = %v", tt.query.Encode(), err)
+		} else if r.Limit != tt.wantLimit || r.Offset != tt.wantOffset {
+			t.Errorf("FromRequest(%s) = limit %d offset %d, want %d and %d", tt.query.Encode(), r.Limit, r.Offset, tt.wantLimit, tt.wantOffset)
+		}
+	}
+}
+
+func TestKeysetCursorRoundTrip(t *testing.T) {
+	db := dryRun(t)
+	now := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.FixedZone("CET", 3600))
+	items := []item{
+		{ID: uuid.New(), Name: "c", CreatedAt: now},
+		{ID: uuid.New(), Name: "b", CreatedAt: now.Add(-time.Hour)},
+		{ID: uuid.New(), Name: "a", CreatedAt: now.Add(-2 * time.Hour)},
+	}
+
+	r, err := newRequest(t, url.Values{"limit": {"2"}})
+	if err != nil {
+		t.Fatalf("FromRequest: %v", err)
+	}
+	page, err := NewPage(db, r, items, 3)
+	if err != nil {
+		t.Fatalf("NewPage: %v", err)
+	}
+	if len(page.Data) != 2 || page.NextCursor == "" || page.Total != 3 {
+		t.Fatalf("NewPage = %d items, cursor %q, total %d; want 2 items and a cursor", len(page.Data), page.NextCursor, page.Total)
+	}
+
+	next, err := newRequest(t, url.Values{"limit": {"2"}, "cursor": {page.NextCursor}})
+	if err != nil {
+		t.Fatalf("FromRequest with cursor: %v", err)
+	}
+	if next.after == nil || next.after.ID != items[1].ID.String() || next.Offset != 0 {
+		t.Fatalf("cursor = %+v, offset %d; want the ID of the last row on the page", next.after, next.Offset)
+	}
+	value, err := next.after.value()
+	if err != nil {
+		t.Fatalf("cursor value: %v", err)
+	}
+	if got, ok := value.(time.Time); !ok || !got.Equal(items[1].CreatedAt) {
+		t.Errorf("cursor value = %v, want %v", value, items[1].CreatedAt)
+	}
+
+	stmt := next.Apply(db.Model(&item{})).Find(&[]item{}).Statement
+	if sql := stmt.SQL.String(); !strings.Contains(sql, "(created_at, id) < (?, ?)") || !strings.Contains(sql, "ORDER BY created_at DESC,id DESC") {
+		t.Errorf("Apply SQL = %s, want a descending keyset condition", sql)
+	}
+}
+
+func TestOffsetCursorRoundTrip(t *testing.T) {
+	db := dryRun(t)
+	items := []item{{ID: uuid.New()}, {ID: uuid.New()}, {ID: uuid.New()}}
+
+	for _, query := range []url.Values{
+		{"limit": {"2"}, "sort": {"rank"}},
+		{"limit": {"2"}, "sort": {"name"}, "offset": {"4"}},
+	} {
+		r, err := newRequest(t, query)
+		if err != nil {
+			t.Fatalf("FromRequest(%s): %v", query.Encode(), err)
+		}
+		page, err := NewPage(db, r, items, 10)
+		if err != nil {
+			t.Fatalf("NewPage(%s): %v", query.Encode(), err)
+		}
+
+		query.Del("offset")
+		query.Set("cursor", page.NextCursor)
+		next, err := newRequest(t, query)
+		if err != nil {
+			t.Fatalf("FromRequest(%s): %v", query.Encode(), err)
+		}
+		if next.after != nil || next.Offset != r.Offset+2 {
+			t.Errorf("cursor for %s = offset %d, want %d", query.Get("sort"), next.Offset, r.Offset+2)
+		}
+	}
+}
+
+func TestCursorSortMismatch(t *testing.T) {
+	r, err := newRequest(t, url.Values{"limit": {"1"}})
+	if err != nil {
+		t.Fatalf("FromRequest: %v", err)
+	}
+	page, err := NewPage(dryRun(t), r, []item{{ID: uuid.New()}, {ID: uuid.New()}}, 2)
+	if err != nil {
+		t.Fatalf("NewPage: %v", err)
+	}
+
+	if _, err := newRequest(t, url.Values{"sort": {"name"}, "cursor": {page.NextCursor}}); !errors.Is(err, ErrCursorSort) {
+		t.Errorf("FromRequest with another sort error = %v, want %v", err, ErrCursorSort)
+	}
+}
+
+func TestLastPage(t *testing.T) {
+	r, err := newRequest(t, url.Values{"limit": {"2"}})
+	if err != nil {
+		t.Fatalf("FromRequest: %v", err)
+	}
+	page, err := NewPage[item](dryRun(t), r, nil, 0)
+	if err != nil {
+		t.Fatalf("NewPage: %v", err)
+	}
+	if page.Data == nil || len(page.Data) != 0 || page.NextCursor != "" {
+		t.Errorf("NewPage(nil) = %+v, want an empty page without a cursor", page)
+	}
+}
@@ -0,0 +1,39 @@
+package serviceauth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carries the shared secret on service-to-service requests
+const Header = "X-Service-Token"
+
+// token returns the shared secret configured for internal calls
+func token() string {
+	return os.Getenv("INTERNAL_SERVICE_TOKEN")
+}
+
+// Middleware only admits requests carrying the shared internal service token.
+// Internal endpoints are not tenant scoped, so they must never be exposed to users.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := token()
+		if expected == "" {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Internal API is not configured"})
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader(Header)), []byte(expected)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid service token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Authorize adds the internal service token to an outgoing request
+func Authorize(req *http.Request) {
+	req.Header.Set(Header, token())
+}
@@ -0,0 +1,129 @@
+package tenant
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	fieldName  = "TenantID"
+	columnName = "tenant_id"
+)
+
+// Plugin scopes every GORM statement on models that have a TenantID field to the
+// tenant carried by the statement context. Queries, updates and deletes get a
+// tenant_id condition and created or saved rows get their TenantID overwritten,
+// so a tenant can neither read nor write another tenant's rows. Statements whose
+// context carries no tenant (background jobs, migrations) are left untouched.
+type Plugin struct{}
+
+// Name implements gorm.Plugin
+func (Plugin) Name() string {
+	return "tenant"
+}
+
+// Initialize implements gorm.Plugin
+func (Plugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("tenant:assign_create", assignTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Query().Before("gorm:query").Register("tenant:scope_query", scopeTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("tenant:scope_row", scopeTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("tenant:assign_update", assignTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("tenant:scope_update", scopeTenant); err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:delete").Register("tenant:scope_delete", scopeTenant)
+}
+
+// scopeTenant adds a tenant_id condition to the statement
+func scopeTenant(db *gorm.DB) {
+	tenantID, ok := statementTenant(db)
+	if !ok {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: columnName}, Value: tenantID},
+	}})
+}
+
+// assignTenant sets TenantID on every row being written
+func assignTenant(db *gorm.DB) {
+	tenantID, ok := statementTenant(db)
+	if !ok {
+		return
+	}
+	field := db.Statement.Schema.LookUpField(fieldName)
+	if dest, ok := db.Statement.Dest.(map[string]interface{}); ok {
+		if _, set := dest[field.DBName]; set {
+			dest[field.DBName] = tenantID
+		}
+		return
+	}
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := field.Set(db.Statement.Context, reflect.Indirect(rv.Index(i)), tenantID); err != nil {
+				db.AddError(err)
+			}
+		}
+	case reflect.Struct:
+		if err := field.Set(db.Statement.Context, rv, tenantID); err != nil {
+			db.AddError(err)
+		}
+	}
+}
+
+// statementTenant returns the tenant for statements on tenant-owned models
+func statementTenant(db *gorm.DB) (uuid.UUID, bool) {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.Context == nil {
+		return uuid.Nil, false
+	}
+	if db.Statement.Schema.LookUpField(fieldName) == nil {
+		return uuid.Nil, false
+	}
+	return FromContext(db.Statement.Context)
+}
+
+// MigrateColumn adds the tenant_id column to a table created before the model was
+// tenant-owned. The column is added nullable, existing rows are assigned to
+// defaultTenant and only then is it made NOT NULL, since AutoMigrate can't add a
+// NOT NULL column to a table that has rows. It does nothing for new tables and
+// once the column exists.
+func MigrateColumn(db *gorm.DB, model interface{}, defaultTenant uuid.UUID) error {
+	if !db.Migrator().HasTable(model) || db.Migrator().HasColumn(model, columnName) {
+		return nil
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	table := clause.Table{Name: stmt.Schema.Table}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("ALTER TABLE ? ADD COLUMN ? uuid", table, clause.Column{Name: columnName}).Error; err != nil {
+			return err
+		}
+		result := tx.Exec("UPDATE ? SET ? = ?", table, clause.Column{Name: columnName}, defaultTenant)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 && defaultTenant == uuid.Nil {
+			return errors.New("existing rows need a tenant; set DEFAULT_TENANT_ID to the organization that owns them")
+		}
+		return tx.Exec("ALTER TABLE ? ALTER COLUMN ? SET NOT NULL", table, clause.Column{Name: columnName}).Error
+	})
+}
@@ -0,0 +1,135 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// ErrMissingTenant is returned when a request carries no tenant
+var ErrMissingTenant = errors.New("tenant is required")
+
+// Tenant roles that may manage the tenant's data, as issued by user-service
+const (
+	RoleOwner = "owner"
+	RoleAdmin = "admin"
+)
+
+type contextKey struct{}
+
+// claims mirrors the tenant-related claims issued by user-service
+type claims struct {
+	UserID     string `json:"user_id"`
+	TenantID   string `json:"tenant_id"`
+	TenantRole string `json:"tenant_role"`
+	ClientID   string `json:"client_id"`
+	Scope      string `json:"scope"`
+	jwt.RegisteredClaims
+}
+
+// DefaultTenant returns the tenant in DEFAULT_TENANT_ID, which owns rows created
+// before products and orders belonged to organizations. It is uuid.Nil when unset.
+func DefaultTenant() (uuid.UUID, error) {
+	value := os.Getenv("DEFAULT_TENANT_ID")
+	if value == "" {
+		return uuid.Nil, nil
+	}
+	return uuid.Parse(value)
+}
+
+// WithTenant returns a copy of ctx carrying the tenant ID
+func WithTenant(ctx context.Context, tenantID uuid.UUID) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenantID)
+}
+
+// FromContext returns the tenant ID carried by ctx, if any
+func FromContext(ctx context.Context) (uuid.UUID, bool) {
+	tenantID, ok := ctx.Value(contextKey{}).(uuid.UUID)
+	return tenantID, ok && tenantID != uuid.Nil
+}
+
+// Middleware validates the bearer token and requires it to carry a tenant_id claim.
+// The tenant is stored on the request context so database queries made with it
+// are scoped to the tenant, and userID, tenantID, tenantRole, clientID and scope
+// are set on the gin context. OAuth access tokens are only accepted when the user
+// authorized the client for an organization; RequireScope limits what they reach.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+			return
+		}
+
+		tokenClaims := &claims{}
+		token, err := jwt.ParseWithClaims(parts[1], tokenClaims, func(token *jwt.Token) (interface{}, error) {
+			return []byte(os.Getenv("JWT_SECRET")), nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+		if err != nil || !token.Valid {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		tenantID, err := uuid.Parse(tokenClaims.TenantID)
+		if err != nil || tenantID == uuid.Nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": ErrMissingTenant.Error()})
+			return
+		}
+
+		c.Request = c.Request.WithContext(WithTenant(c.Request.Context(), tenantID))
+		c.Set("userID", tokenClaims.UserID)
+		c.Set("tenantID", tenantID.String())
+		c.Set("tenantRole", tokenClaims.TenantRole)
+		c.Set("clientID", tokenClaims.ClientID)
+		c.Set("scope", tokenClaims.Scope)
+		c.Next()
+	}
+}
+
+// IsManager reports whether the caller is an owner or admin of the tenant. It
+// must run after Middleware.
+func IsManager(c *gin.Context) bool {
+	role := c.GetString("tenantRole")
+	return role == RoleOwner || role == RoleAdmin
+}
+
+// RequireManager only admits owners and admins of the tenant. It must run after
+// Middleware.
+func RequireManager() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsManager(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Only organization owners and admins can change the catalog"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope rejects OAuth access tokens that were not granted the given scope.
+// Tenant tokens issued by user-service carry no client and are not restricted.
+// It must run after Middleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("clientID") != "" && !hasScope(c.GetString("scope"), scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient scope", "required_scope": scope})
+			return
+		}
+		c.Next()
+	}
+}
+
+// hasScope reports whether the space-delimited scope list contains want
+func hasScope(scope, want string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+// Package carts keeps shopping carts priced and checked against the current
+// products, merges carts when a shopper logs in and expires abandoned carts
+package carts
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"gorm.io/gorm"
+)
+
+// DefaultTTL is how long carts live after their last change when CART_TTL is not set
+const DefaultTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrItemNotFound is returned for product IDs and SKUs that don't exist
+	ErrItemNotFound = errors.New("product or SKU not found")
+
+	// ErrVariantRequired is returned for products with variants added without a variant SKU
+	ErrVariantRequired = errors.New("SKU is required for products with variants")
+
+	// ErrInsufficientStock is returned when a line asks for more than is in stock
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
+// TTL is how long carts live after their last change, from CART_TTL
+func TTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("CART_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return DefaultTTL
+}
+
+// Item is the product, and variant if any, a cart line is for
+type Item struct {
+	Product models.Product
+	Variant *models.Variant
+}
+
+// Price returns the item's current price in its product's base currency
+func (i Item) Price() money.Money {
+	price := i.Product.Price
+	if i.Variant != nil && i.Variant.PriceOverride != nil {
+		price.Amount = *i.Variant.PriceOverride
+	}
+	return price
+}
+
+// Stock returns the item's current stock
+func (i Item) Stock() int {
+	if i.Variant != nil {
+		return i.Variant.Stock
+	}
+	return i.Product.Stock
+}
+
+// Holds reports whether the cart line is for this item
+func (i Item) Holds(line models.CartLine) bool {
+	if i.Variant == nil {
+		return line.ProductID == i.Product.ID && line.VariantID == nil
+	}
+	return line.VariantID != nil && *line.VariantID == i.Variant.ID
+}
+
+// Resolve finds the item by SKU, which may be a variant's or a product's own,
+// or by product ID. Products with variants can only be added by variant SKU,
+// as they can only be ordered by one.
+func Resolve(db *gorm.DB, productID uuid.UUID, sku string) (*Item, error) {
+	item := &Item{}
+	if sku != "" {
+		var variant models.Variant
+		if err := db.First(&variant, "sku = ?", sku).Error; err == nil {
+			item.Variant = &variant
+			productID = variant.ProductID
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		} else if err := db.Select("id").First(&item.Product, "sku = ?", sku).Error; err == nil {
+			productID = item.Product.ID
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrItemNotFound
+		} else {
+			return nil, err
+		}
+	}
+
+	err := db.First(&item.Product, "id = ?", productID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrItemNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if item.Variant == nil {
+		var variants int64
+		if err := db.Model(&models.Variant{}).Where("product_id = ?", productID).Count(&variants).Error; err != nil {
+			return nil, err
+		}
+		if variants > 0 {
+			return nil, ErrVariantRequired
+		}
+	}
+	return item, nil
+}
+
+// Reprice prices the cart's lines at the items' current prices in the cart's
+// currency and checks them against current stock, filling in the lines' live
+// fields, the cart's Subtotal and whether it can be checked out as it is.
+func Reprice(db *gorm.DB, cart *models.Cart, rates money.RateTable) error {
+	subtotal := money.Zero(cart.Currency)
+	cart.Subtotal, cart.Valid = &subtotal, len(cart.Lines) > 0
+	for i := range cart.Lines {
+		line := &cart.Lines[i]
+		item, err := Resolve(db, line.ProductID, line.SKU)
+		if errors.Is(err, ErrItemNotFound) || errors.Is(err, ErrVariantRequired) {
+			line.Problem, cart.Valid = "no longer available", false
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		price := item.Price()
+		rate, err := rates.Rate(price.Currency, cart.Currency)
+		if errors.Is(err, money.ErrNoRate) {
+			line.Problem, cart.Valid = "can't be priced in "+cart.Currency, false
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		current, err := price.Convert(cart.Currency, rate)
+		if err != nil {
+			return err
+		}
+		total, err := current.Mul(int64(line.Quantity))
+		if err != nil {
+			return err
+		}
+		if subtotal, err = subtotal.Add(total); err != nil {
+			return err
+		}
+
+		line.Name = item.Product.Name
+		line.CurrentPrice, line.Total = &current, &total
+		line.PriceChanged = current != line.UnitPrice
+		if item.Stock() < line.Quantity {
+			line.Problem, cart.Valid = ErrInsufficientStock.Error(), false
+		}
+	}
+	*cart.Subtotal = subtotal
+	return nil
+}
+
+// Merge moves the lines of from into the active cart into, adding up the
+// quantities of items both carts hold, and marks from merged
+func Merge(tx *gorm.DB, from, into *models.Cart) error {
+	for _, line := range from.Lines {
+		var existing *models.CartLine
+		for i := range into.Lines {
+			if into.Lines[i].ProductID == line.ProductID && sameVariant(into.Lines[i].VariantID, line.VariantID) {
+				existing = &into.Lines[i]
+				break
+			}
+		}
+
+		if existing != nil {
+			existing.Quantity += line.Quantity
+			if err := tx.Model(existing).Update("quantity", existing.Quantity).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&line).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		line.CartID = into.ID
+		if err := tx.Model(&line).Update("cart_id", into.ID).Error; err != nil {
+			return err
+		}
+		into.Lines = append(into.Lines, line)
+	}
+
+	from.Lines = nil
+	from.Status, from.MergedInto = models.CartMerged, &into.ID
+	if err := tx.Omit("Lines").Save(from).Error; err != nil {
+		return err
+	}
+	into.ExpiresAt = time.Now().Add(TTL())
+	return tx.Omit("Lines").Save(into).Error
+}
+
+// Expire marks active carts past their expiry date expired and returns how
+// many were
+func Expire(ctx context.Context, db *gorm.DB, now time.Time) (int64, error) {
+	result := db.WithContext(ctx).Model(&models.Cart{}).
+		Where("status = ? AND expires_at < ?", models.CartActive, now).
+		Update("status", models.CartExpired)
+	return result.RowsAffected, result.Error
+}
+
+// StartExpiryWorker periodically expires abandoned carts
+func StartExpiryWorker(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			expired, err := Expire(context.Background(), db, time.Now())
+			if err != nil {
+				log.Printf("[ERROR] Expiring carts failed: %v", err)
+				continue
+			}
+			if expired > 0 {
+				log.Printf("Expired %d carts", expired)
+			}
+		}
+	}()
+}
+
+// sameVariant reports whether two lines are for the same variant, or both for
+// a product without variants
+func sameVariant(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
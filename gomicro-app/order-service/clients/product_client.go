@@ -0,0 +1,98 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/serviceauth"
+)
+
+// ErrInsufficientStock is returned when no warehouse can ship the whole order
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// AllocationRequest asks product-service to reserve stock for an order
+type AllocationRequest struct {
+	TenantID  uuid.UUID  `json:"tenant_id"`
+	OrderID   uuid.UUID  `json:"order_id"`
+	ProductID uuid.UUID  `json:"product_id"`
+	VariantID *uuid.UUID `json:"variant_id,omitempty"`
+	Quantity  int        `json:"quantity"`
+	Country   string     `json:"country"`
+	Region    string     `json:"region"`
+}
+
+// Allocation is the stock reserved for an order, as returned by product-service
+type Allocation struct {
+	ID          uuid.UUID `json:"id"`
+	WarehouseID uuid.UUID `json:"warehouse_id"`
+	Status      string    `json:"status"`
+}
+
+// AllocateStock reserves stock for the order in a warehouse chosen by
+// product-service. It returns nil when the item's stock isn't kept per warehouse.
+func AllocateStock(ctx context.Context, req AllocationRequest) (*Allocation, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := callProductService(ctx, "/internal/allocations", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusConflict:
+		return nil, ErrInsufficientStock
+	case http.StatusOK:
+		var allocation Allocation
+		if err := json.NewDecoder(resp.Body).Decode(&allocation); err != nil {
+			return nil, err
+		}
+		return &allocation, nil
+	}
+	return nil, fmt.Errorf("allocate stock: unexpected status %d", resp.StatusCode)
+}
+
+// CommitAllocation takes the order's reserved stock off hand once it ships.
+// Orders without an allocation are ignored.
+func CommitAllocation(ctx context.Context, orderID uuid.UUID) error {
+	return finishAllocation(ctx, orderID, "commit")
+}
+
+// ReleaseAllocation returns the order's reserved stock to its warehouse.
+// Orders without an allocation are ignored.
+func ReleaseAllocation(ctx context.Context, orderID uuid.UUID) error {
+	return finishAllocation(ctx, orderID, "release")
+}
+
+func finishAllocation(ctx context.Context, orderID uuid.UUID, action string) error {
+	resp, err := callProductService(ctx, fmt.Sprintf("/internal/allocations/%s/%s", orderID, action), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("%s allocation: unexpected status %d", action, resp.StatusCode)
+	}
+	return nil
+}
+
+// callProductService POSTs body to an internal product-service endpoint
+func callProductService(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, os.Getenv("PRODUCT_SERVICE_URL")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	serviceauth.Authorize(req)
+	return httpClient.Do(req)
+}
@@ -0,0 +1,80 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+)
+
+// ErrNotFound is returned when the requested resource does not exist upstream
+var ErrNotFound = errors.New("resource not found")
+
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+// Address is an address-book entry as returned by user-service
+type Address struct {
+	ID            uuid.UUID `json:"id"`
+	RecipientName string    `json:"recipient_name"`
+	Line1         string    `json:"line1"`
+	Line2         string    `json:"line2"`
+	City          string    `json:"city"`
+	Region        string    `json:"region"`
+	PostalCode    string    `json:"postal_code"`
+	Country       string    `json:"country"`
+	Phone         string    `json:"phone"`
+}
+
+// Snapshot copies the address into the form stored on orders
+func (a Address) Snapshot() models.AddressSnapshot {
+	return models.AddressSnapshot{
+		RecipientName: a.RecipientName,
+		Line1:         a.Line1,
+		Line2:         a.Line2,
+		City:          a.City,
+		Region:        a.Region,
+		PostalCode:    a.PostalCode,
+		Country:       a.Country,
+		Phone:         a.Phone,
+	}
+}
+
+// GetAddress fetches one of the calling user's addresses from user-service,
+// forwarding the caller's Authorization header
+func GetAddress(ctx context.Context, authorization string, addressID uuid.UUID) (*Address, error) {
+	var address Address
+	url := fmt.Sprintf("%s/api/users/me/addresses/%s", os.Getenv("USER_SERVICE_URL"), addressID)
+	if err := getJSON(ctx, authorization, url, &address); err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// getJSON performs an authorized GET request and decodes the JSON response into dest
+func getJSON(ctx context.Context, authorization, url string, dest interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("GET %s: unexpected status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
@@ -0,0 +1,141 @@
+// Package coupons generates coupon codes and redeems them at checkout
+package coupons
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reasons a coupon can't be redeemed
+var (
+	ErrNotFound      = errors.New("coupon not found")
+	ErrExpired       = errors.New("coupon has expired or is no longer active")
+	ErrExhausted     = errors.New("coupon has been fully redeemed")
+	ErrUserLimit     = errors.New("coupon redemption limit reached for this user")
+	ErrMinimumValue  = errors.New("order does not reach the coupon's minimum value")
+	ErrNotApplicable = errors.New("coupon does not apply to this order")
+)
+
+// alphabet leaves out characters that are easily confused, like 0/O and 1/I/L
+const alphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+// Normalize returns the stored form of a code
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Generate returns prefix followed by length random characters
+func Generate(prefix string, length int) (string, error) {
+	var code strings.Builder
+	code.WriteString(Normalize(prefix))
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		code.WriteByte(alphabet[n.Int64()])
+	}
+	return code.String(), nil
+}
+
+// Lock finds the code in tx and locks it until the transaction ends, then
+// checks the user can redeem it on an order with the given subtotal. The user
+// must come from the caller's token, not the request body. convert
+// takes the coupon's minimum order value to the order's currency.
+func Lock(tx *gorm.DB, code string, userID uuid.UUID, subtotal money.Money, convert func(money.Money) (money.Money, error), now time.Time) (*models.Coupon, error) {
+	var coupon models.Coupon
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", Normalize(code)).Take(&coupon).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !coupon.Active || (coupon.ExpiresAt != nil && !now.Before(*coupon.ExpiresAt)) {
+		return nil, ErrExpired
+	}
+	if coupon.UsageLimit != nil && coupon.RedemptionCount >= *coupon.UsageLimit {
+		return nil, ErrExhausted
+	}
+	if coupon.PerUserLimit != nil {
+		// A per-user limit can't be counted without a user
+		if userID == uuid.Nil {
+			return nil, ErrUserLimit
+		}
+		// Cancelled orders give their redemption back
+		var redeemed int64
+		err := tx.Model(&models.CouponRedemption{}).
+			Joins("JOIN orders ON orders.id = coupon_redemptions.order_id AND orders.deleted_at IS NULL AND orders.status <> ?", models.OrderStatusCancelled).
+			Where("coupon_redemptions.coupon_id = ? AND coupon_redemptions.user_id = ?", coupon.ID, userID).
+			Count(&redeemed).Error
+		if err != nil {
+			return nil, err
+		}
+		if redeemed >= int64(*coupon.PerUserLimit) {
+			return nil, ErrUserLimit
+		}
+	}
+	if coupon.MinOrderValue.IsPositive() {
+		minimum, err := convert(coupon.MinOrderValue)
+		if err != nil {
+			return nil, err
+		}
+		if cmp, err := subtotal.Cmp(minimum); err != nil {
+			return nil, err
+		} else if cmp < 0 {
+			return nil, ErrMinimumValue
+		}
+	}
+	return &coupon, nil
+}
+
+// Redeem counts a redemption of the locked coupon by the order and records the
+// discount it gave. Call it in the transaction that created the order, so the
+// redemption rolls back with it.
+func Redeem(tx *gorm.DB, coupon *models.Coupon, order *models.Order, discount money.Money) error {
+	err := tx.Model(coupon).Update("redemption_count", gorm.Expr("redemption_count + 1")).Error
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.CouponRedemption{
+		ID:          uuid.New(),
+		CouponID:    coupon.ID,
+		Code:        coupon.Code,
+		PromotionID: coupon.PromotionID,
+		BatchID:     coupon.BatchID,
+		OrderID:     order.ID,
+		UserID:      order.UserID,
+		Discount:    discount,
+	}).Error
+}
+
+// Release gives back the coupon redeemed by a cancelled order: the redemption
+// is removed and the coupon's redemption count goes back down, so the code can
+// be used again. Call it in the transaction that cancels the order.
+func Release(tx *gorm.DB, orderID uuid.UUID) error {
+	var redemption models.CouponRedemption
+	err := tx.Where("order_id = ?", orderID).Take(&redemption).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&models.Coupon{}).
+		Where("id = ? AND redemption_count > 0", redemption.CouponID).
+		Update("redemption_count", gorm.Expr("redemption_count - 1")).Error
+	if err != nil {
+		return err
+	}
+	return tx.Delete(&redemption).Error
+}
@@ -1,12 +1,15 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/common/tenant"
 	"github.com/ozturkeniss/gomicro-app/order-service/models"
 )
 
@@ -29,12 +32,97 @@ func InitDB() {
 
 	log.Println("Successfully connected to database")
 
+	// Scope every query on tenant-owned models to the tenant in the request context
+	if err := DB.Use(tenant.Plugin{}); err != nil {
+		log.Fatalf("Failed to register tenant plugin: %v", err)
+	}
+
+	// Orders created before organizations existed are given to DEFAULT_TENANT_ID
+	defaultTenant, err := tenant.DefaultTenant()
+	if err != nil {
+		log.Fatalf("Invalid DEFAULT_TENANT_ID: %v", err)
+	}
+	if err := tenant.MigrateColumn(DB, &models.Order{}, defaultTenant); err != nil {
+		log.Fatalf("Failed to migrate orders tenant_id column: %v", err)
+	}
+
 	// AutoMigrate the Order model
 	err = DB.AutoMigrate(&models.Order{})
 	if err != nil {
 		log.Fatalf("Failed to auto migrate Order model: %v", err)
 	}
 	log.Println("Order table auto migrated successfully")
+
+	// Move prices stored as floats before the money type into exact decimal columns
+	if err := money.MigrateFloatColumn(DB, &models.Order{}, "total_price", "total_price_", money.DefaultCurrency()); err != nil {
+		log.Fatalf("Failed to migrate total_price column: %v", err)
+	}
+
+	// Orders placed before adjustments were recorded have no discounts
+	err = DB.Exec("UPDATE orders SET subtotal_amount = total_price_amount, subtotal_currency = total_price_currency WHERE subtotal_currency = ''").Error
+	if err != nil {
+		log.Fatalf("Failed to backfill order subtotals: %v", err)
+	}
+
+	// Orders placed before tax was calculated carry no tax
+	err = DB.Exec("UPDATE orders SET tax_total_currency = total_price_currency WHERE tax_total_currency = ''").Error
+	if err != nil {
+		log.Fatalf("Failed to backfill order tax totals: %v", err)
+	}
+
+	// Orders placed before shipping was charged shipped for free
+	err = DB.Exec("UPDATE orders SET shipping_cost_currency = total_price_currency WHERE shipping_cost_currency = ''").Error
+	if err != nil {
+		log.Fatalf("Failed to backfill order shipping costs: %v", err)
+	}
+
+	// AutoMigrate the Promotion and OrderAdjustment models
+	err = DB.AutoMigrate(&models.Promotion{}, &models.OrderAdjustment{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate promotion models: %v", err)
+	}
+	log.Println("Promotion tables auto migrated successfully")
+
+	// AutoMigrate the Coupon and CouponRedemption models
+	err = DB.AutoMigrate(&models.Coupon{}, &models.CouponRedemption{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate coupon models: %v", err)
+	}
+	log.Println("Coupon tables auto migrated successfully")
+
+	// AutoMigrate the tax models
+	err = DB.AutoMigrate(&models.TaxRate{}, &models.TaxSettings{}, &models.OrderTaxLine{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate tax models: %v", err)
+	}
+	log.Println("Tax tables auto migrated successfully")
+
+	// AutoMigrate the shipping models
+	err = DB.AutoMigrate(&models.ShippingMethod{}, &models.ShippingRate{}, &models.Shipment{}, &models.ShipmentEvent{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate shipping models: %v", err)
+	}
+	log.Println("Shipping tables auto migrated successfully")
+
+	// AutoMigrate the Cart and CartLine models
+	err = DB.AutoMigrate(&models.Cart{}, &models.CartLine{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate cart models: %v", err)
+	}
+	log.Println("Cart tables auto migrated successfully")
+
+	// AutoMigrate the payment models
+	err = DB.AutoMigrate(&models.Payment{}, &models.PaymentTransaction{}, &models.PaymentWebhookEvent{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate payment models: %v", err)
+	}
+	log.Println("Payment tables auto migrated successfully")
+}
+
+// Scoped returns a session bound to ctx, so queries on tenant-owned models are
+// restricted to the tenant carried by the request
+func Scoped(ctx context.Context) *gorm.DB {
+	return DB.WithContext(ctx)
 }
 
 // TestDatabaseConnection tries to connect to the database and logs the result
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"log"
+
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"gorm.io/gorm"
+)
+
+// LoadRateTable reads all exchange rates into a lookup table. Rates that no
+// longer parse, such as ones saved before rates were range checked and rounded
+// to zero, are logged and left out.
+func LoadRateTable(db *gorm.DB) (money.RateTable, error) {
+	var rates []models.ExchangeRate
+	if err := db.Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	table := make(money.RateTable, len(rates))
+	for _, r := range rates {
+		rate, err := money.ParseRate(r.Rate)
+		if err != nil {
+			log.Printf("Skipping exchange rate %s/%s: %v", r.Base, r.Quote, err)
+			continue
+		}
+		table.Set(r.Base, r.Quote, rate)
+	}
+	return table, nil
+}
@@ -0,0 +1,518 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/order-service/carts"
+	"github.com/ozturkeniss/gomicro-app/order-service/coupons"
+	"github.com/ozturkeniss/gomicro-app/order-service/database"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateCart starts a cart, anonymous or for the calling user, priced in
+// currency (default the default currency). A user who already has an active
+// cart gets that one back.
+func CreateCart(c *gin.Context) {
+	var req struct {
+		UserID   *uuid.UUID `json:"user_id"`
+		Currency string     `json:"currency"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = money.DefaultCurrency()
+	}
+	if !money.ValidCurrency(currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return
+	}
+	if req.UserID != nil && req.UserID.String() != c.GetString("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Carts can only be started for yourself"})
+		return
+	}
+
+	cart := models.Cart{
+		ID:        uuid.New(),
+		UserID:    req.UserID,
+		Currency:  currency,
+		Status:    models.CartActive,
+		ExpiresAt: time.Now().Add(carts.TTL()),
+	}
+	result := database.Scoped(c.Request.Context()).Clauses(clause.OnConflict{DoNothing: true}).Create(&cart)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 && req.UserID != nil {
+		existing, err := activeUserCart(database.Scoped(c.Request.Context()), *req.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		writeCart(c, http.StatusOK, existing)
+		return
+	}
+
+	writeCart(c, http.StatusCreated, &cart)
+}
+
+// GetCart retrieves a cart, priced at current prices if it is active
+func GetCart(c *gin.Context) {
+	cart, ok := loadCart(c)
+	if !ok {
+		return
+	}
+
+	writeCart(c, http.StatusOK, cart)
+}
+
+// GetUserCart retrieves the calling user's active cart, priced at current prices
+func GetUserCart(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if userID.String() != c.GetString("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own cart"})
+		return
+	}
+
+	cart, err := activeUserCart(database.Scoped(c.Request.Context()), userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The user has no active cart"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeCart(c, http.StatusOK, cart)
+}
+
+// AddCartLine adds an item to a cart by product ID or SKU, or adds to the
+// quantity of the line already holding it
+func AddCartLine(c *gin.Context) {
+	cart, ok := loadActiveCart(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		ProductID uuid.UUID `json:"product_id"`
+		SKU       string    `json:"sku"`
+		Quantity  int       `json:"quantity" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ProductID == uuid.Nil && req.SKU == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "product_id or sku is required"})
+		return
+	}
+
+	item, err := carts.Resolve(database.Scoped(c.Request.Context()), req.ProductID, strings.TrimSpace(req.SKU))
+	if err != nil {
+		writeCartError(c, err)
+		return
+	}
+	if req.ProductID != uuid.Nil && req.ProductID != item.Product.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SKU does not belong to the product"})
+		return
+	}
+
+	var line *models.CartLine
+	for i := range cart.Lines {
+		if item.Holds(cart.Lines[i]) {
+			line = &cart.Lines[i]
+			break
+		}
+	}
+	if line == nil {
+		cart.Lines = append(cart.Lines, models.CartLine{ID: uuid.New(), CartID: cart.ID, ProductID: item.Product.ID, SKU: strings.TrimSpace(req.SKU)})
+		line = &cart.Lines[len(cart.Lines)-1]
+		if item.Variant != nil {
+			line.VariantID = &item.Variant.ID
+		}
+	}
+	line.Quantity += req.Quantity
+
+	if err := saveCartLine(c.Request.Context(), cart, line, item); err != nil {
+		writeCartError(c, err)
+		return
+	}
+
+	writeCart(c, http.StatusOK, cart)
+}
+
+// UpdateCartLine sets the quantity of a cart line; zero removes it
+func UpdateCartLine(c *gin.Context) {
+	cart, ok := loadActiveCart(c)
+	if !ok {
+		return
+	}
+	line, ok := cartLine(c, cart)
+	if !ok {
+		return
+	}
+	var req struct {
+		Quantity *int `json:"quantity" binding:"required,min=0"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if *req.Quantity == 0 {
+		removeCartLine(c, cart, line)
+		return
+	}
+
+	item, err := carts.Resolve(database.Scoped(c.Request.Context()), line.ProductID, line.SKU)
+	if err != nil {
+		writeCartError(c, err)
+		return
+	}
+	line.Quantity = *req.Quantity
+	if err := saveCartLine(c.Request.Context(), cart, line, item); err != nil {
+		writeCartError(c, err)
+		return
+	}
+
+	writeCart(c, http.StatusOK, cart)
+}
+
+// DeleteCartLine removes a line from a cart
+func DeleteCartLine(c *gin.Context) {
+	cart, ok := loadActiveCart(c)
+	if !ok {
+		return
+	}
+	line, ok := cartLine(c, cart)
+	if !ok {
+		return
+	}
+
+	removeCartLine(c, cart, line)
+}
+
+// MergeCart binds an anonymous cart to the calling user, who just logged in.
+// If the user already has an active cart, the anonymous cart's lines are
+// merged into it and that cart is returned.
+func MergeCart(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	cart, ok := loadActiveCart(c)
+	if !ok {
+		return
+	}
+	if cart.UserID != nil {
+		// loadCart only returns the caller's own carts
+		writeCart(c, http.StatusOK, cart)
+		return
+	}
+
+	result := cart
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		userCart, err := activeUserCart(tx.Clauses(clause.Locking{Strength: "UPDATE"}), userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			cart.UserID = &userID
+			cart.ExpiresAt = time.Now().Add(carts.TTL())
+			return tx.Omit("Lines").Save(cart).Error
+		}
+		if err != nil {
+			return err
+		}
+		result = userCart
+		return carts.Merge(tx, cart, userCart)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeCart(c, http.StatusOK, result)
+}
+
+// CheckoutCart converts the calling user's cart into orders, one per line,
+// shipped to the same address with the same shipping method. The coupon is
+// redeemed on the first line it applies to. If any line can't be ordered, the
+// orders already placed are cancelled and the cart stays active.
+func CheckoutCart(c *gin.Context) {
+	cart, ok := loadActiveCart(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		ShippingAddressID uuid.UUID  `json:"shipping_address_id" binding:"required"`
+		ShippingMethodID  *uuid.UUID `json:"shipping_method_id"`
+		CouponCode        string     `json:"coupon_code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if cart.UserID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Anonymous carts must be merged into a user's cart before checkout"})
+		return
+	}
+
+	if !priceCart(c, cart) {
+		return
+	}
+	if !cart.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cart has lines that can't be ordered", "cart": cart})
+		return
+	}
+
+	// Claim the cart so it can't be checked out twice
+	claim := database.Scoped(c.Request.Context()).Model(&models.Cart{}).
+		Where("id = ? AND status = ?", cart.ID, models.CartActive).
+		Update("status", models.CartConverted)
+	if claim.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": claim.Error.Error()})
+		return
+	}
+	if claim.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cart is already being checked out"})
+		return
+	}
+
+	orders, err := checkoutLines(c.Request.Context(), c.GetHeader("Authorization"), cart, req.ShippingAddressID, req.ShippingMethodID, req.CouponCode)
+	if err != nil {
+		if err := database.Scoped(c.Request.Context()).Model(cart).Update("status", models.CartActive).Error; err != nil {
+			log.Printf("Failed to reopen cart %s: %v", cart.ID, err)
+		}
+		writeOrderError(c, err)
+		return
+	}
+
+	cart.Status = models.CartConverted
+	for _, order := range orders {
+		cart.OrderIDs = append(cart.OrderIDs, order.ID)
+	}
+	if err := database.Scoped(c.Request.Context()).Model(cart).Select("order_ids").Updates(models.Cart{OrderIDs: cart.OrderIDs}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"cart": cart, "orders": orders})
+}
+
+// checkoutLines places an order for each of the cart's lines, cancelling the
+// ones already placed if one fails
+func checkoutLines(ctx context.Context, authorization string, cart *models.Cart, addressID uuid.UUID, methodID *uuid.UUID, couponCode string) ([]models.Order, error) {
+	var orders []models.Order
+	err := func() error {
+		for _, line := range cart.Lines {
+			order := models.Order{
+				UserID:            *cart.UserID,
+				ProductID:         line.ProductID,
+				SKU:               line.SKU,
+				Quantity:          line.Quantity,
+				Currency:          cart.Currency,
+				ShippingAddressID: &addressID,
+				ShippingMethodID:  methodID,
+				CouponCode:        couponCode,
+				CartID:            &cart.ID,
+			}
+			err := placeOrder(ctx, authorization, &order)
+			if couponCode != "" && (errors.Is(err, coupons.ErrNotApplicable) || errors.Is(err, coupons.ErrMinimumValue)) {
+				order.CouponCode = ""
+				err = placeOrder(ctx, authorization, &order)
+			}
+			if err != nil {
+				return err
+			}
+			if order.CouponCode != "" {
+				couponCode = ""
+			}
+			orders = append(orders, order)
+		}
+		if couponCode != "" {
+			return coupons.ErrNotApplicable
+		}
+		return nil
+	}()
+	if err == nil {
+		return orders, nil
+	}
+
+	for i := range orders {
+		if err := setOrderStatus(ctx, &orders[i], models.OrderStatusCancelled); err != nil {
+			log.Printf("Failed to cancel order %s of failed checkout of cart %s: %v", orders[i].ID, cart.ID, err)
+		}
+	}
+	return nil, err
+}
+
+// saveCartLine checks the line against the item's stock, records its current
+// price and saves it, extending the cart's life
+func saveCartLine(ctx context.Context, cart *models.Cart, line *models.CartLine, item *carts.Item) error {
+	if item.Stock() < line.Quantity {
+		return carts.ErrInsufficientStock
+	}
+	rates, err := database.LoadRateTable(database.DB)
+	if err != nil {
+		return err
+	}
+	price := item.Price()
+	rate, err := rates.Rate(price.Currency, cart.Currency)
+	if err != nil {
+		return err
+	}
+	if line.UnitPrice, err = price.Convert(cart.Currency, rate); err != nil {
+		return err
+	}
+
+	return database.Scoped(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(line).Error; err != nil {
+			return err
+		}
+		cart.ExpiresAt = time.Now().Add(carts.TTL())
+		return tx.Omit("Lines").Save(cart).Error
+	})
+}
+
+// removeCartLine deletes the line and responds with the cart
+func removeCartLine(c *gin.Context, cart *models.Cart, line *models.CartLine) {
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(line).Error; err != nil {
+			return err
+		}
+		cart.ExpiresAt = time.Now().Add(carts.TTL())
+		return tx.Omit("Lines").Save(cart).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range cart.Lines {
+		if cart.Lines[i].ID == line.ID {
+			cart.Lines = append(cart.Lines[:i], cart.Lines[i+1:]...)
+			break
+		}
+	}
+	writeCart(c, http.StatusOK, cart)
+}
+
+// activeUserCart loads the user's active cart with its lines
+func activeUserCart(db *gorm.DB, userID uuid.UUID) (*models.Cart, error) {
+	var cart models.Cart
+	err := db.Preload("Lines", orderCartLines).
+		First(&cart, "user_id = ? AND status = ?", userID, models.CartActive).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// loadCart loads the cart and its lines from the :id path parameter. Carts of
+// other users are reported as not found; anonymous carts are open to whoever
+// has their ID.
+func loadCart(c *gin.Context) (*models.Cart, bool) {
+	cartID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cart ID"})
+		return nil, false
+	}
+
+	var cart models.Cart
+	result := database.Scoped(c.Request.Context()).Preload("Lines", orderCartLines).First(&cart, "id = ?", cartID)
+	if result.Error != nil || (cart.UserID != nil && cart.UserID.String() != c.GetString("userID")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart not found"})
+		return nil, false
+	}
+	return &cart, true
+}
+
+// loadActiveCart loads the cart like loadCart and makes sure it can still be
+// changed. Carts past their expiry date count as expired even before the
+// expiry worker marks them.
+func loadActiveCart(c *gin.Context) (*models.Cart, bool) {
+	cart, ok := loadCart(c)
+	if !ok {
+		return nil, false
+	}
+	if cart.Status == models.CartActive && cart.ExpiresAt.Before(time.Now()) {
+		cart.Status = models.CartExpired
+	}
+	if cart.Status != models.CartActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cart is " + cart.Status})
+		return nil, false
+	}
+	return cart, true
+}
+
+// cartLine finds the line from the :lineId path parameter in the cart
+func cartLine(c *gin.Context, cart *models.Cart) (*models.CartLine, bool) {
+	lineID, err := uuid.Parse(c.Param("lineId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cart line ID"})
+		return nil, false
+	}
+	for i := range cart.Lines {
+		if cart.Lines[i].ID == lineID {
+			return &cart.Lines[i], true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Cart line not found"})
+	return nil, false
+}
+
+// orderCartLines lists cart lines in the order they were added
+func orderCartLines(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at")
+}
+
+// priceCart prices the cart at current prices and stock. It writes an error
+// response and returns false if it can't.
+func priceCart(c *gin.Context, cart *models.Cart) bool {
+	rates, err := database.LoadRateTable(database.DB)
+	if err == nil {
+		err = carts.Reprice(database.Scoped(c.Request.Context()), cart, rates)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// writeCart responds with the cart, priced at current prices if it is active
+func writeCart(c *gin.Context, status int, cart *models.Cart) {
+	if cart.Status == models.CartActive && !priceCart(c, cart) {
+		return
+	}
+	c.JSON(status, cart)
+}
+
+// writeCartError responds with the status code for a cart line error
+func writeCartError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, carts.ErrItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, carts.ErrVariantRequired), errors.Is(err, carts.ErrInsufficientStock):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, money.ErrNoRate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The item can't be priced in the cart's currency"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,488 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/common/paging"
+	"github.com/ozturkeniss/gomicro-app/order-service/coupons"
+	"github.com/ozturkeniss/gomicro-app/order-service/database"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxCouponBatch is the most codes one batch request generates
+	maxCouponBatch = 10000
+	// defaultCodeLength is the number of random characters in generated codes
+	defaultCodeLength = 10
+)
+
+// couponCode matches the codes tenants may choose themselves, once normalized
+var couponCode = regexp.MustCompile(`^[A-Z0-9_-]{3,64}$`)
+
+// couponSort lists the fields coupon lists can be sorted by
+var couponSort = paging.Sort{
+	Fields: map[string]string{
+		"created_at":  "created_at",
+		"code":        "code",
+		"redemptions": "redemption_count",
+	},
+	Default: "-created_at",
+}
+
+// redemptionSort lists the fields redemption lists can be sorted by
+var redemptionSort = paging.Sort{
+	Fields:  map[string]string{"created_at": "created_at"},
+	Default: "-created_at",
+}
+
+// couponTerms are the redemption rules shared by a coupon or a batch of them
+type couponTerms struct {
+	UsageLimit    *int         `json:"usage_limit"`
+	PerUserLimit  *int         `json:"per_user_limit"`
+	MinOrderValue *money.Money `json:"min_order_value"`
+	ExpiresAt     *time.Time   `json:"expires_at"`
+	Active        *bool        `json:"active"`
+}
+
+// apply validates the terms and sets them on the coupon
+func (t couponTerms) apply(coupon *models.Coupon) error {
+	if (t.UsageLimit != nil && *t.UsageLimit < 1) || (t.PerUserLimit != nil && *t.PerUserLimit < 1) {
+		return errors.New("Redemption limits must be at least 1")
+	}
+	coupon.UsageLimit, coupon.PerUserLimit, coupon.ExpiresAt = t.UsageLimit, t.PerUserLimit, t.ExpiresAt
+
+	coupon.MinOrderValue = money.Money{}
+	if t.MinOrderValue != nil && !t.MinOrderValue.IsZero() {
+		minimum := *t.MinOrderValue
+		minimum.Currency = strings.ToUpper(minimum.Currency)
+		if err := minimum.Validate(); err != nil {
+			return err
+		}
+		if minimum.IsNegative() {
+			return errors.New("Minimum order value cannot be negative")
+		}
+		coupon.MinOrderValue = minimum
+	}
+
+	coupon.Active = t.Active == nil || *t.Active
+	return nil
+}
+
+// CreateCoupon creates a coupon for a promotion that requires one, with the
+// given code or a generated one (owners and admins only)
+func CreateCoupon(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	var req struct {
+		PromotionID uuid.UUID `json:"promotion_id" binding:"required"`
+		Code        string    `json:"code"`
+		couponTerms
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkCouponPromotion(c, req.PromotionID) {
+		return
+	}
+
+	coupon := models.Coupon{ID: uuid.New(), PromotionID: req.PromotionID, Code: coupons.Normalize(req.Code)}
+	if err := req.apply(&coupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if coupon.Code == "" {
+		code, err := coupons.Generate("", defaultCodeLength)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		coupon.Code = code
+	} else if !couponCode.MatchString(coupon.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code must be 3 to 64 letters, digits, dashes or underscores"})
+		return
+	}
+
+	result := database.Scoped(c.Request.Context()).Clauses(clause.OnConflict{DoNothing: true}).Create(&coupon)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Coupon code already exists"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, coupon)
+}
+
+// CreateCouponBatch generates count unique codes with the same terms for a
+// promotion (owners and admins only). The codes are listed with
+// GET /api/coupons?batch_id=.
+func CreateCouponBatch(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	var req struct {
+		PromotionID uuid.UUID `json:"promotion_id" binding:"required"`
+		Count       int       `json:"count" binding:"required,min=1"`
+		Prefix      string    `json:"prefix"`
+		Length      int       `json:"length"`
+		couponTerms
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Count > maxCouponBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A batch can have at most 10000 codes"})
+		return
+	}
+	if req.Length == 0 {
+		req.Length = defaultCodeLength
+	}
+	prefix := coupons.Normalize(req.Prefix)
+	if req.Length < 6 || len(prefix)+req.Length > 64 || (prefix != "" && !couponCode.MatchString(prefix+"XXX")) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Codes need at least 6 random characters, at most 64 in all, and a prefix of letters, digits, dashes or underscores"})
+		return
+	}
+	if !checkCouponPromotion(c, req.PromotionID) {
+		return
+	}
+
+	var terms models.Coupon
+	if err := req.apply(&terms); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	batchID := uuid.New()
+	created := 0
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		// Codes that collide with existing ones are skipped, so keep generating
+		// until the batch is full
+		for attempts := 0; created < req.Count; attempts++ {
+			if attempts == 10 {
+				return errors.New("could not generate enough unique codes; use a longer code length")
+			}
+			batch := make([]models.Coupon, 0, req.Count-created)
+			seen := make(map[string]bool, req.Count-created)
+			for len(batch) < req.Count-created {
+				code, err := coupons.Generate(prefix, req.Length)
+				if err != nil {
+					return err
+				}
+				if seen[code] {
+					continue
+				}
+				seen[code] = true
+				coupon := terms
+				coupon.ID, coupon.PromotionID, coupon.BatchID, coupon.Code = uuid.New(), req.PromotionID, &batchID, code
+				batch = append(batch, coupon)
+			}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&batch, 500)
+			if result.Error != nil {
+				return result.Error
+			}
+			created += int(result.RowsAffected)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"batch_id": batchID, "promotion_id": req.PromotionID, "count": created})
+}
+
+// ListCoupons retrieves a page of coupons, optionally filtered by promotion,
+// batch or code (owners and admins only)
+func ListCoupons(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	req, err := paging.FromRequest(c, couponSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query, ok := couponFilters(c, database.Scoped(c.Request.Context()).Model(&models.Coupon{}))
+	if !ok {
+		return
+	}
+	if code := c.Query("code"); code != "" {
+		query = query.Where("code = ?", coupons.Normalize(code))
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	var list []models.Coupon
+	if result := req.Apply(query).Find(&list); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	page, err := paging.NewPage(database.DB, req, list, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetCoupon retrieves a coupon by ID (owners and admins only)
+func GetCoupon(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	coupon, ok := loadCoupon(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, coupon)
+}
+
+// UpdateCoupon replaces a coupon's redemption terms (owners and admins only).
+// Its code, promotion and redemption count are kept.
+func UpdateCoupon(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	coupon, ok := loadCoupon(c)
+	if !ok {
+		return
+	}
+
+	var req couponTerms
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.apply(coupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := database.Scoped(c.Request.Context()).Model(coupon).
+		Select("usage_limit", "per_user_limit", "min_order_value_amount", "min_order_value_currency", "expires_at", "active").
+		Updates(coupon)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, coupon)
+}
+
+// DeleteCoupon deletes a coupon by ID (owners and admins only). Its redemptions
+// are kept for reporting.
+func DeleteCoupon(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	coupon, ok := loadCoupon(c)
+	if !ok {
+		return
+	}
+
+	if result := database.Scoped(c.Request.Context()).Delete(coupon); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Coupon deleted successfully"})
+}
+
+// ListCouponRedemptions retrieves a page of coupon redemptions, optionally
+// filtered by coupon, promotion, batch, user, code and time (owners and admins only)
+func ListCouponRedemptions(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	req, err := paging.FromRequest(c, redemptionSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query, ok := redemptionFilters(c)
+	if !ok {
+		return
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	var list []models.CouponRedemption
+	if result := req.Apply(query).Find(&list); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	page, err := paging.NewPage(database.DB, req, list, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetCouponRedemptionSummary totals the redemptions matching the same filters
+// as ListCouponRedemptions, per currency (owners and admins only)
+func GetCouponRedemptionSummary(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	query, ok := redemptionFilters(c)
+	if !ok {
+		return
+	}
+
+	var rows []struct {
+		Currency    string
+		Discount    money.Amount
+		Redemptions int64
+		Users       int64
+		Coupons     int64
+	}
+	result := query.Select("discount_currency AS currency, SUM(discount_amount) AS discount, COUNT(*) AS redemptions, " +
+		"COUNT(DISTINCT user_id) AS users, COUNT(DISTINCT coupon_id) AS coupons").
+		Group("discount_currency").Order("discount_currency").Scan(&rows)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	totals := make([]gin.H, len(rows))
+	for i, row := range rows {
+		totals[i] = gin.H{
+			"discount":    money.Money{Amount: row.Discount, Currency: row.Currency},
+			"redemptions": row.Redemptions,
+			"users":       row.Users,
+			"coupons":     row.Coupons,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"totals": totals})
+}
+
+// loadCoupon loads the coupon from the :id path parameter
+func loadCoupon(c *gin.Context) (*models.Coupon, bool) {
+	couponID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon ID"})
+		return nil, false
+	}
+
+	var coupon models.Coupon
+	if result := database.Scoped(c.Request.Context()).First(&coupon, "id = ?", couponID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+		return nil, false
+	}
+	return &coupon, true
+}
+
+// checkCouponPromotion makes sure coupons can be issued for the promotion
+func checkCouponPromotion(c *gin.Context, promotionID uuid.UUID) bool {
+	var promotion models.Promotion
+	if result := database.Scoped(c.Request.Context()).First(&promotion, "id = ?", promotionID); result.Error != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Promotion not found"})
+		return false
+	}
+	if !promotion.RequiresCoupon {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Coupons can only be issued for promotions that require a coupon"})
+		return false
+	}
+	return true
+}
+
+// couponFilters narrows a coupon or redemption query by the promotion_id and
+// batch_id query parameters
+func couponFilters(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	for _, column := range []string{"promotion_id", "batch_id"} {
+		if value := c.Query(column); value != "" {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + column})
+				return nil, false
+			}
+			query = query.Where(column+" = ?", id)
+		}
+	}
+	return query, true
+}
+
+// redemptionFilters builds a redemption query from the coupon_id, promotion_id,
+// batch_id, user_id, code, from and to query parameters
+func redemptionFilters(c *gin.Context) (*gorm.DB, bool) {
+	query, ok := couponFilters(c, database.Scoped(c.Request.Context()).Model(&models.CouponRedemption{}))
+	if !ok {
+		return nil, false
+	}
+	for _, column := range []string{"coupon_id", "user_id"} {
+		if value := c.Query(column); value != "" {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + column})
+				return nil, false
+			}
+			query = query.Where(column+" = ?", id)
+		}
+	}
+	if code := c.Query("code"); code != "" {
+		query = query.Where("code = ?", coupons.Normalize(code))
+	}
+	for param, condition := range map[string]string{"from": "created_at >= ?", "to": "created_at < ?"} {
+		if value := c.Query(param); value != "" {
+			at, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + "; use RFC 3339"})
+				return nil, false
+			}
+			query = query.Where(condition, at)
+		}
+	}
+	return query, true
+}
+
+// writeCouponError responds to the reasons a coupon can't be redeemed, and
+// reports whether err was one of them
+func writeCouponError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, coupons.ErrNotFound),
+		errors.Is(err, coupons.ErrExpired),
+		errors.Is(err, coupons.ErrUserLimit),
+		errors.Is(err, coupons.ErrMinimumValue),
+		errors.Is(err, coupons.ErrNotApplicable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, coupons.ErrExhausted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
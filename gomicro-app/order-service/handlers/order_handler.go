@@ -1,63 +1,305 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/common/paging"
+	"github.com/ozturkeniss/gomicro-app/common/tenant"
+	"github.com/ozturkeniss/gomicro-app/order-service/clients"
+	"github.com/ozturkeniss/gomicro-app/order-service/coupons"
 	"github.com/ozturkeniss/gomicro-app/order-service/database"
 	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"github.com/ozturkeniss/gomicro-app/order-service/promotions"
+	"github.com/ozturkeniss/gomicro-app/order-service/shipping"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// CreateOrder handles the creation of a new order
+// CreateOrder handles the creation of a new order for the calling user
 func CreateOrder(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
 	var order models.Order
 	if err := c.ShouldBindJSON(&order); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Orders are placed for the caller whatever the body says; reviews, coupon
+	// and promotion limits trust the order's user. Only cart checkout links
+	// orders to a cart.
+	order.UserID = userID
+	order.CartID = nil
+	if err := placeOrder(c.Request.Context(), c.GetHeader("Authorization"), &order); err != nil {
+		writeOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, order)
+}
+
+// orderError is a reason an order can't be placed, with the status to respond with
+type orderError struct {
+	status  int
+	message string
+}
+
+func (e orderError) Error() string { return e.message }
+
+// placeOrder validates, prices and creates the order for the item, quantity and
+// shipping address it names, fetching the address from user-service with the
+// caller's authorization. It reserves the item's stock, applies promotions and
+// the coupon, taxes the order and charges its shipping.
+func placeOrder(ctx context.Context, authorization string, order *models.Order) error {
 	// Validate UserID and ProductID
 	if order.UserID == uuid.Nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "UserID is required"})
-		return
+		return orderError{http.StatusBadRequest, "UserID is required"}
 	}
-	if order.ProductID == uuid.Nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ProductID is required"})
-		return
+	if order.ProductID == uuid.Nil && order.SKU == "" {
+		return orderError{http.StatusBadRequest, "ProductID or SKU is required"}
+	}
+	if order.Quantity <= 0 {
+		return orderError{http.StatusBadRequest, "Quantity must be positive"}
+	}
+	if order.ShippingAddressID == nil {
+		return orderError{http.StatusBadRequest, "ShippingAddressID is required"}
+	}
+
+	// Snapshot the chosen address so later address book edits don't change the order
+	address, err := clients.GetAddress(ctx, authorization, *order.ShippingAddressID)
+	if errors.Is(err, clients.ErrNotFound) {
+		return orderError{http.StatusBadRequest, "Shipping address not found"}
+	}
+	if err != nil {
+		return orderError{http.StatusBadGateway, "Failed to fetch shipping address"}
+	}
+	order.ShippingAddress = address.Snapshot()
+
+	// Resolve the SKU to its variant, or to a product without variants that has a
+	// SKU of its own; products with variants can only be ordered by variant SKU
+	var variant *models.Variant
+	if order.SKU != "" {
+		variant = &models.Variant{}
+		skuProductID := uuid.Nil
+		if result := database.Scoped(ctx).First(variant, "sku = ?", order.SKU); result.Error == nil {
+			skuProductID = variant.ProductID
+			order.VariantID = &variant.ID
+		} else {
+			variant = nil
+			var skuProduct models.Product
+			if result := database.Scoped(ctx).First(&skuProduct, "sku = ?", order.SKU); result.Error != nil {
+				return orderError{http.StatusNotFound, "SKU not found"}
+			}
+			skuProductID = skuProduct.ID
+		}
+		if order.ProductID != uuid.Nil && order.ProductID != skuProductID {
+			return orderError{http.StatusBadRequest, "SKU does not belong to the product"}
+		}
+		order.ProductID = skuProductID
 	}
 
 	// Fetch product details to calculate total price and check stock
 	var product models.Product
-	result := database.DB.First(&product, "id = ?", order.ProductID)
+	result := database.Scoped(ctx).First(&product, "id = ?", order.ProductID)
 	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
-		return
+		return orderError{http.StatusNotFound, "Product not found"}
+	}
+	basePrice, stock := product.Price, product.Stock
+	if variant != nil {
+		stock = variant.Stock
+		if variant.PriceOverride != nil {
+			basePrice.Amount = *variant.PriceOverride
+		}
+	} else {
+		var variants int64
+		if result := database.Scoped(ctx).Model(&models.Variant{}).Where("product_id = ?", product.ID).Count(&variants); result.Error != nil {
+			return result.Error
+		}
+		if variants > 0 {
+			return orderError{http.StatusBadRequest, "SKU is required for products with variants"}
+		}
 	}
 
 	// Check if stock is sufficient
-	if product.Stock < order.Quantity {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient stock"})
-		return
+	if stock < order.Quantity {
+		return orderError{http.StatusBadRequest, "Insufficient stock"}
 	}
 
-	// Calculate total price
-	order.TotalPrice = product.Price * float64(order.Quantity)
+	// Price the order in the requested currency, defaulting to the product's own,
+	// and record the rate used so the total can be reproduced later
+	currency := strings.ToUpper(order.Currency)
+	if currency == "" {
+		currency = basePrice.Currency
+	}
+	if !money.ValidCurrency(currency) {
+		return orderError{http.StatusBadRequest, "Unsupported currency"}
+	}
+	rates, err := database.LoadRateTable(database.DB)
+	if err != nil {
+		return err
+	}
+	rate, err := rates.Rate(basePrice.Currency, currency)
+	if errors.Is(err, money.ErrNoRate) {
+		return orderError{http.StatusBadRequest, "No exchange rate from " + basePrice.Currency + " to " + currency}
+	}
+	if err != nil {
+		return err
+	}
+
+	// Convert the unit price first so the total matches the price shown to the customer
+	unitPrice, err := basePrice.Convert(currency, rate)
+	if err == nil {
+		order.Subtotal, err = unitPrice.Mul(int64(order.Quantity))
+	}
+	if err != nil {
+		return orderError{http.StatusBadRequest, err.Error()}
+	}
+	order.TotalPrice = order.Subtotal
+	order.Adjustments, order.TaxLines, order.Shipments, order.Payments = nil, nil, nil, nil
+	order.Currency = currency
+	order.BaseCurrency = basePrice.Currency
+	order.ExchangeRate = money.FormatRate(rate)
 
 	order.ID = uuid.New()
-	result = database.DB.Create(&order)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
-		return
+	order.Status = models.OrderStatusPending
+
+	// Reserve the stock in the warehouse product-service picks for the destination
+	allocation, err := clients.AllocateStock(ctx, clients.AllocationRequest{
+		TenantID:  product.TenantID,
+		OrderID:   order.ID,
+		ProductID: order.ProductID,
+		VariantID: order.VariantID,
+		Quantity:  order.Quantity,
+		Country:   order.ShippingAddress.Country,
+		Region:    order.ShippingAddress.Region,
+	})
+	if errors.Is(err, clients.ErrInsufficientStock) {
+		return orderError{http.StatusBadRequest, "Insufficient stock"}
+	}
+	if err != nil {
+		return orderError{http.StatusBadGateway, "Failed to allocate stock"}
+	}
+	if allocation != nil {
+		order.WarehouseID = &allocation.WarehouseID
 	}
 
-	c.JSON(http.StatusCreated, order)
+	// Discount the order with the promotions that apply, recording each as an
+	// adjustment, in the same transaction that counts their use and redeems the
+	// coupon, so nothing is used up by an order that fails
+	line := promotions.Line{
+		UserID:    order.UserID,
+		ProductID: order.ProductID,
+		Quantity:  order.Quantity,
+		UnitPrice: unitPrice,
+		Subtotal:  order.Subtotal,
+	}
+	convert := func(amount money.Money) (money.Money, error) {
+		rate, err := rates.Rate(amount.Currency, currency)
+		if err != nil {
+			return money.Money{}, err
+		}
+		return amount.Convert(currency, rate)
+	}
+	err = database.Scoped(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var coupon *models.Coupon
+		if order.CouponCode != "" {
+			var err error
+			if coupon, err = coupons.Lock(tx, order.CouponCode, order.UserID, order.Subtotal, convert, now); err != nil {
+				return err
+			}
+			order.CouponCode = coupon.Code
+			line.CouponPromotionID = coupon.PromotionID
+		}
+
+		discounts, err := promotions.Apply(tx, line, convert, now)
+		if err != nil {
+			return err
+		}
+		var couponDiscount *money.Money
+		for _, discount := range discounts {
+			adjustment := models.OrderAdjustment{
+				ID:          uuid.New(),
+				OrderID:     order.ID,
+				Type:        models.AdjustmentPromotion,
+				PromotionID: &discount.Promotion.ID,
+				Description: discount.Promotion.Name,
+				Amount:      discount.Amount.Neg(),
+			}
+			if coupon != nil && discount.Promotion.ID == coupon.PromotionID {
+				adjustment.Type = models.AdjustmentCoupon
+				adjustment.CouponID = &coupon.ID
+				adjustment.Description = "Coupon " + coupon.Code + ": " + discount.Promotion.Name
+				couponDiscount = &discount.Amount
+			}
+			order.Adjustments = append(order.Adjustments, adjustment)
+			if order.TotalPrice, err = order.TotalPrice.Sub(discount.Amount); err != nil {
+				return err
+			}
+		}
+		if coupon != nil && couponDiscount == nil {
+			return coupons.ErrNotApplicable
+		}
+
+		// Tax what the customer pays after discounts, then charge shipping on it
+		goods := order.TotalPrice
+		if err := taxOrder(ctx, tx, order, &product); err != nil {
+			return err
+		}
+		if err := shipOrder(tx, order, &product, goods, convert); err != nil {
+			return err
+		}
+
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		if coupon != nil {
+			return coupons.Redeem(tx, coupon, order, *couponDiscount)
+		}
+		return nil
+	})
+	if err != nil {
+		if allocation != nil {
+			if err := clients.ReleaseAllocation(context.Background(), order.ID); err != nil {
+				log.Printf("Failed to release stock for order %s: %v", order.ID, err)
+			}
+		}
+		return err
+	}
+	return nil
 }
 
-// GetOrder retrieves an order by ID with user and product details
+// writeOrderError responds with the status code for a placeOrder error
+func writeOrderError(c *gin.Context, err error) {
+	var invalid orderError
+	switch {
+	case errors.As(err, &invalid):
+		c.JSON(invalid.status, gin.H{"error": invalid.message})
+	case errors.Is(err, shipping.ErrUnknownMethod), errors.Is(err, shipping.ErrNoRate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, promotions.ErrUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		if !writeCouponError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// GetOrder retrieves an order by ID with its adjustments, tax lines, shipments
+// and payments
 func GetOrder(c *gin.Context) {
 	id := c.Param("id")
 	orderID, err := uuid.Parse(id)
@@ -67,8 +309,8 @@ func GetOrder(c *gin.Context) {
 	}
 
 	var order models.Order
-	result := database.DB.Preload("User").Preload("Product").First(&order, "id = ?", orderID)
-	if result.Error != nil {
+	result := database.Scoped(c.Request.Context()).Preload("Adjustments").Preload("TaxLines").Preload("Shipments").Preload("Payments").First(&order, "id = ?", orderID)
+	if result.Error != nil || !canAccessOrder(c, &order) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
@@ -91,8 +333,41 @@ func UpdateOrder(c *gin.Context) {
 		return
 	}
 
+	// Make sure the order exists for this tenant and the caller may change it before overwriting it
+	var existing models.Order
+	if result := database.Scoped(c.Request.Context()).First(&existing, "id = ?", orderID); result.Error != nil || !canAccessOrder(c, &existing) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	// The customer, item, quantity, shipping address and total are fixed at
+	// checkout and can't be rewritten
+	order.UserID = existing.UserID
+	order.ProductID = existing.ProductID
+	order.VariantID = existing.VariantID
+	order.SKU = existing.SKU
+	order.Quantity = existing.Quantity
+	order.ShippingAddressID = existing.ShippingAddressID
+	order.ShippingAddress = existing.ShippingAddress
+	order.CouponCode = existing.CouponCode
+	order.Subtotal = existing.Subtotal
+	order.TotalPrice = existing.TotalPrice
+	order.TaxTotal = existing.TaxTotal
+	order.ShippingMethodID = existing.ShippingMethodID
+	order.ShippingCost = existing.ShippingCost
+	order.PricesIncludeTax = existing.PricesIncludeTax
+	order.Adjustments, order.TaxLines, order.Shipments, order.Payments = nil, nil, nil, nil
+	order.Currency = existing.Currency
+	order.BaseCurrency = existing.BaseCurrency
+	order.ExchangeRate = existing.ExchangeRate
+	order.WarehouseID = existing.WarehouseID
+	order.CartID = existing.CartID
+
+	// Status changes move reserved stock, so they go through UpdateOrderStatus
+	order.Status = existing.Status
+
 	order.ID = orderID
-	result := database.DB.Save(&order)
+	result := database.Scoped(c.Request.Context()).Save(&order)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
@@ -110,7 +385,21 @@ func DeleteOrder(c *gin.Context) {
 		return
 	}
 
-	result := database.DB.Delete(&models.Order{}, "id = ?", orderID)
+	var order models.Order
+	if result := database.Scoped(c.Request.Context()).First(&order, "id = ?", orderID); result.Error != nil || !canAccessOrder(c, &order) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	// Stock reserved for an order that never shipped goes back to the warehouse
+	if order.WarehouseID != nil && !order.StockShipped() && !order.Closed() {
+		if err := clients.ReleaseAllocation(c.Request.Context(), order.ID); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to release stock"})
+			return
+		}
+	}
+
+	result := database.Scoped(c.Request.Context()).Delete(&order)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
@@ -119,20 +408,76 @@ func DeleteOrder(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Order deleted successfully"})
 }
 
-// ListOrders retrieves a list of orders with user and product details
+// orderSort lists the fields order lists can be sorted by
+var orderSort = paging.Sort{
+	Fields: map[string]string{
+		"created_at": "created_at",
+		"total":      "total_price_amount",
+		"status":     "status",
+	},
+	Default: "-created_at",
+}
+
+// ListOrders retrieves a page of orders, optionally filtered by status, user or product
 func ListOrders(c *gin.Context) {
+	req, err := paging.FromRequest(c, orderSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.Scoped(c.Request.Context()).Model(&models.Order{})
+
+	// Customers only see their own orders, whatever user_id they ask for
+	if !tenant.IsManager(c) {
+		userID, ok := currentUser(c)
+		if !ok {
+			return
+		}
+		query = query.Where("user_id = ?", userID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	for _, column := range []string{"user_id", "product_id"} {
+		if value := c.Query(column); value != "" {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + column})
+				return
+			}
+			query = query.Where(column+" = ?", id)
+		}
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
 	var orders []models.Order
-	result := database.DB.Preload("User").Preload("Product").Find(&orders)
-	if result.Error != nil {
+	if result := req.Apply(query).Find(&orders); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, orders)
+	page, err := paging.NewPage(database.DB, req, orders, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
 }
 
-// UpdateOrderStatus updates the status of an order
+// UpdateOrderStatus updates the status of an order (owners and admins only;
+// shipments move orders along by themselves)
 func UpdateOrderStatus(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
 	id := c.Param("id")
 	orderID, err := uuid.Parse(id)
 	if err != nil {
@@ -149,26 +494,120 @@ func UpdateOrderStatus(c *gin.Context) {
 	}
 
 	var order models.Order
-	result := database.DB.First(&order, "id = ?", orderID)
+	result := database.Scoped(c.Request.Context()).First(&order, "id = ?", orderID)
 	if result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
 
-	order.Status = statusUpdate.Status
-	result = database.DB.Save(&order)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	if err := setOrderStatus(c.Request.Context(), &order, statusUpdate.Status); err != nil {
+		writeOrderStatusError(c, err)
 		return
 	}
 
+	c.JSON(http.StatusOK, order)
+}
+
+// errOrderClosed is returned when a status change would reopen a cancelled or
+// refunded order
+var errOrderClosed = errors.New("cancelled and refunded orders can't be reopened")
+
+// errStockUpdate is returned when the order's reserved stock couldn't be moved
+var errStockUpdate = errors.New("failed to update stock")
+
+// setOrderStatus moves the order to status and saves it. Shipping takes the
+// reserved stock off hand, and cancelling or refunding an order that hasn't
+// shipped returns it. Cancelling also gives back its coupon and promotion uses.
+func setOrderStatus(ctx context.Context, order *models.Order, status string) error {
+	if order.Closed() && status != order.Status {
+		return errOrderClosed
+	}
+	if order.WarehouseID != nil && !order.StockShipped() {
+		var err error
+		switch {
+		case status == models.OrderStatusShipped, status == models.OrderStatusDelivered:
+			err = clients.CommitAllocation(ctx, order.ID)
+		case (status == models.OrderStatusCancelled || status == models.OrderStatusRefunded) && !order.Closed():
+			err = clients.ReleaseAllocation(ctx, order.ID)
+		}
+		if err != nil {
+			log.Printf("Failed to update stock for order %s: %v", order.ID, err)
+			return errStockUpdate
+		}
+	}
+
+	// Cancelling gives back the coupon and promotion uses the order made. The order
+	// is locked so two cancellations can't both give them back.
+	err := database.Scoped(ctx).Transaction(func(tx *gorm.DB) error {
+		var current models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("status").Take(&current, "id = ?", order.ID).Error; err != nil {
+			return err
+		}
+		if status == models.OrderStatusCancelled && !current.Closed() {
+			if err := coupons.Release(tx, order.ID); err != nil {
+				return err
+			}
+			if err := promotions.Release(tx, order.ID); err != nil {
+				return err
+			}
+		}
+		order.Status = status
+		return tx.Save(order).Error
+	})
+	if err != nil {
+		return err
+	}
+
 	// Log the status update
-	logMessage := fmt.Sprintf("Order %s status updated to %s", orderID, statusUpdate.Status)
+	logMessage := fmt.Sprintf("Order %s status updated to %s", order.ID, status)
 	if err := logToFile(logMessage); err != nil {
 		log.Printf("Failed to log status update: %v", err)
 	}
+	return nil
+}
 
-	c.JSON(http.StatusOK, order)
+// writeOrderStatusError responds with the status code for a setOrderStatus error
+func writeOrderStatusError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errOrderClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Cancelled and refunded orders can't be reopened"})
+	case errors.Is(err, errStockUpdate):
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to update stock"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// canAccessOrder reports whether the caller placed the order or manages the tenant.
+// Other callers are told the order doesn't exist.
+func canAccessOrder(c *gin.Context, order *models.Order) bool {
+	return order.UserID.String() == c.GetString("userID") || tenant.IsManager(c)
+}
+
+// loadAccessibleOrder loads the order if the caller may access it, as decided by
+// canAccessOrder. Otherwise it writes a 404 with the notFound message and returns false.
+func loadAccessibleOrder(c *gin.Context, orderID uuid.UUID, notFound string) (*models.Order, bool) {
+	var order models.Order
+	result := database.Scoped(c.Request.Context()).First(&order, "id = ?", orderID)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) || (result.Error == nil && !canAccessOrder(c, &order)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		return nil, false
+	}
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return nil, false
+	}
+	return &order, true
+}
+
+// currentUser returns the ID of the user making the request
+func currentUser(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token does not identify a user"})
+		return uuid.Nil, false
+	}
+	return userID, true
 }
 
 // logToFile logs a message to the order status log file
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/common/tenant"
+	"github.com/ozturkeniss/gomicro-app/order-service/database"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"github.com/ozturkeniss/gomicro-app/order-service/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxWebhookBody is the largest webhook body accepted
+const maxWebhookBody = 1 << 20
+
+// CreatePayment authorizes payment of an order's total from a tokenized
+// payment source, capturing it straight away if capture is set. Only the
+// customer who placed the order and owners and admins can pay it. Declined
+// payments are recorded and answered with 402.
+func CreatePayment(c *gin.Context) {
+	if !requirePayments(c) {
+		return
+	}
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	var req struct {
+		Source  string `json:"source" binding:"required"`
+		Capture bool   `json:"capture"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The order is locked while the check runs, so two payments can't be taken for it at once
+	payment, err := payments.Authorize(c.Request.Context(), database.Scoped(c.Request.Context()), orderID, req.Source, req.Capture, func(tx *gorm.DB, order *models.Order) error {
+		if !canAccessOrder(c, order) {
+			return gorm.ErrRecordNotFound
+		}
+		if order.Status != models.OrderStatusPending {
+			return errOrderNotPayable
+		}
+		var live int64
+		err := tx.Model(&models.Payment{}).
+			Where("order_id = ? AND (status IN ? OR (status = ? AND created_at > ?))", order.ID,
+				[]string{models.PaymentAuthorized, models.PaymentCaptured, models.PaymentPartiallyRefunded},
+				models.PaymentPending, time.Now().Add(-payments.PendingTimeout)).
+			Count(&live).Error
+		if err != nil {
+			return err
+		}
+		if live > 0 {
+			return errOrderNotPayable
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		writePaymentError(c, err)
+		return
+	}
+
+	respondPayment(c, http.StatusCreated, payment)
+}
+
+// ListOrderPayments retrieves the payments of an order with their transactions,
+// for the customer who placed it or owners and admins
+func ListOrderPayments(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	if _, ok := loadAccessibleOrder(c, orderID, "Order not found"); !ok {
+		return
+	}
+
+	var list []models.Payment
+	result := database.Scoped(c.Request.Context()).
+		Preload("Transactions", orderPaymentTransactions).
+		Where("order_id = ?", orderID).Order("created_at").
+		Find(&list)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// GetPayment retrieves a payment with its transactions, for the customer who
+// placed its order or owners and admins
+func GetPayment(c *gin.Context) {
+	paymentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	var payment models.Payment
+	result := database.Scoped(c.Request.Context()).Preload("Transactions", orderPaymentTransactions).First(&payment, "id = ?", paymentID)
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+	if _, ok := loadAccessibleOrder(c, payment.OrderID, "Payment not found"); !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// CapturePayment captures an authorized payment, in full or the given amount
+// (owners and admins only)
+func CapturePayment(c *gin.Context) {
+	amount, ok := paymentAmount(c)
+	if !ok {
+		return
+	}
+	operatePayment(c, func(ctx context.Context, db *gorm.DB, payment *models.Payment) error {
+		return payments.Capture(ctx, db, payment, amount)
+	})
+}
+
+// VoidPayment cancels an authorization nothing was captured of (owners and
+// admins only)
+func VoidPayment(c *gin.Context) {
+	operatePayment(c, payments.Void)
+}
+
+// RefundPayment refunds a captured payment, in full or the given amount
+// (owners and admins only)
+func RefundPayment(c *gin.Context) {
+	amount, ok := paymentAmount(c)
+	if !ok {
+		return
+	}
+	operatePayment(c, func(ctx context.Context, db *gorm.DB, payment *models.Payment) error {
+		return payments.Refund(ctx, db, payment, amount)
+	})
+}
+
+// HandlePaymentWebhook applies an event the payment provider pushes about a
+// payment. The provider's signature is verified, redelivered events are
+// ignored and events about unknown payments are acknowledged without effect.
+func HandlePaymentWebhook(c *gin.Context) {
+	if !payments.Enabled() || c.Param("provider") != payments.Provider {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown payment provider"})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	event, err := payments.Default.ParseWebhook(c.Request.Header, body)
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil || event.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook event"})
+		return
+	}
+
+	// Webhooks aren't tenant scoped; the payment tells which tenant the event is for
+	var payment models.Payment
+	result := database.DB.Where("provider = ? AND reference = ?", payments.Provider, event.PaymentReference).Limit(1).Find(&payment)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+	ctx := tenant.WithTenant(c.Request.Context(), payment.TenantID)
+
+	duplicate := false
+	err = database.Scoped(ctx).Transaction(func(tx *gorm.DB) error {
+		received := models.PaymentWebhookEvent{Provider: payments.Provider, EventID: event.ID, Type: event.Type, ReceivedAt: time.Now()}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&received)
+		if result.Error != nil || result.RowsAffected == 0 {
+			duplicate = result.Error == nil
+			return result.Error
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, "id = ?", payment.ID).Error; err != nil {
+			return err
+		}
+		return payments.ApplyEvent(tx, &payment, event)
+	})
+	if errors.Is(err, payments.ErrInvalidAmount) || errors.Is(err, money.ErrCurrencyMismatch) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Event amount exceeds what the payment allows"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if duplicate {
+		c.JSON(http.StatusOK, gin.H{"status": "duplicate"})
+		return
+	}
+
+	if err := syncOrderPayment(ctx, &payment); err != nil {
+		writeOrderStatusError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "processed"})
+}
+
+// errOrderNotPayable is returned when an order isn't pending or already has a
+// payment that hasn't failed or been voided
+var errOrderNotPayable = errors.New("order is not awaiting payment")
+
+// operatePayment runs the operation on the payment from the :id path
+// parameter, moves its order along and responds with it
+func operatePayment(c *gin.Context, operation func(context.Context, *gorm.DB, *models.Payment) error) {
+	if !requireManager(c) || !requirePayments(c) {
+		return
+	}
+	paymentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	payment := models.Payment{ID: paymentID}
+	err = operation(c.Request.Context(), database.Scoped(c.Request.Context()), &payment)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+	if err != nil {
+		writePaymentError(c, err)
+		return
+	}
+
+	respondPayment(c, http.StatusOK, &payment)
+}
+
+// requirePayments answers 503 while no payment gateway is configured
+func requirePayments(c *gin.Context) bool {
+	if !payments.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payments are not configured"})
+		return false
+	}
+	return true
+}
+
+// respondPayment moves the payment's order along and responds with the
+// payment and its transactions, with 402 if its latest transaction was declined
+func respondPayment(c *gin.Context, status int, payment *models.Payment) {
+	if err := syncOrderPayment(c.Request.Context(), payment); err != nil {
+		writeOrderStatusError(c, err)
+		return
+	}
+	if err := database.Scoped(c.Request.Context()).Preload("Transactions", orderPaymentTransactions).First(payment, "id = ?", payment.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if n := len(payment.Transactions); n > 0 && !payment.Transactions[n-1].Succeeded {
+		status = http.StatusPaymentRequired
+	}
+	c.JSON(status, payment)
+}
+
+// syncOrderPayment moves the payment's order along: capturing pays a pending
+// order and refunding all that was captured refunds it, returning its stock
+// if it hasn't shipped
+func syncOrderPayment(ctx context.Context, payment *models.Payment) error {
+	var order models.Order
+	if err := database.Scoped(ctx).First(&order, "id = ?", payment.OrderID).Error; err != nil {
+		return err
+	}
+
+	switch {
+	case (payment.Status == models.PaymentCaptured || payment.Status == models.PaymentPartiallyRefunded) && order.Status == models.OrderStatusPending:
+		return setOrderStatus(ctx, &order, models.OrderStatusPaid)
+	case payment.Status == models.PaymentRefunded && !order.Closed():
+		return setOrderStatus(ctx, &order, models.OrderStatusRefunded)
+	}
+	return nil
+}
+
+// paymentAmount reads the optional amount of a capture or refund. It writes an
+// error response and returns false if the body is invalid.
+func paymentAmount(c *gin.Context) (*money.Money, bool) {
+	var req struct {
+		Amount *money.Money `json:"amount"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+	return req.Amount, true
+}
+
+// orderPaymentTransactions lists payment transactions oldest first
+func orderPaymentTransactions(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at")
+}
+
+// writePaymentError responds with the status code for a payment error
+func writePaymentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errOrderNotPayable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order is not awaiting payment"})
+	case errors.Is(err, payments.ErrInvalidState):
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment does not allow this operation"})
+	case errors.Is(err, payments.ErrBusy):
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment has an operation in progress"})
+	case errors.Is(err, payments.ErrInvalidAmount), errors.Is(err, money.ErrCurrencyMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment amount"})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/order-service/database"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"gorm.io/gorm"
+)
+
+// ListUserOrders returns every order placed by a user across all tenants,
+// including deleted ones, for data-subject export requests (internal only)
+func ListUserOrders(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var orders []models.Order
+	result := database.DB.Unscoped().Where("user_id = ?", userID).Order("created_at").Find(&orders)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+// AnonymizeUserData strips personal data from a user's orders, carts and
+// payments across all tenants while keeping quantities, totals and tax
+// jurisdiction intact (internal only)
+func AnonymizeUserData(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var orders, carts, transactions int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Country and region are kept as the tax jurisdiction of the sale
+		result := tx.Unscoped().Model(&models.Order{}).
+			Where("user_id = ? AND anonymized_at IS NULL", userID).
+			Updates(map[string]interface{}{
+				"shipping_recipient_name": "",
+				"shipping_line1":          "",
+				"shipping_line2":          "",
+				"shipping_city":           "",
+				"shipping_postal_code":    "",
+				"shipping_phone":          "",
+				"anonymized_at":           time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		orders = result.RowsAffected
+
+		// Carts are unlinked from the user; an active one is expired so it
+		// can't be picked up as an anonymous cart
+		err := tx.Model(&models.Cart{}).
+			Where("user_id = ? AND status = ?", userID, models.CartActive).
+			Update("status", models.CartExpired).Error
+		if err != nil {
+			return err
+		}
+		result = tx.Model(&models.Cart{}).Where("user_id = ?", userID).Update("user_id", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+		carts = result.RowsAffected
+
+		// Payments keep their amounts and provider references for bookkeeping;
+		// the provider's messages may name the cardholder
+		userPayments := tx.Model(&models.Payment{}).Select("payments.id").
+			Joins("JOIN orders ON orders.id = payments.order_id").
+			Where("orders.user_id = ?", userID)
+		result = tx.Model(&models.PaymentTransaction{}).
+			Where("message <> '' AND payment_id IN (?)", userPayments).
+			Update("message", "")
+		if result.Error != nil {
+			return result.Error
+		}
+		transactions = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"anonymized": orders, "carts": carts, "payment_transactions": transactions})
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/common/paging"
+	"github.com/ozturkeniss/gomicro-app/common/tenant"
+	"github.com/ozturkeniss/gomicro-app/order-service/database"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"github.com/ozturkeniss/gomicro-app/order-service/promotions"
+	"gorm.io/gorm"
+)
+
+// promotionSort lists the fields promotion lists can be sorted by
+var promotionSort = paging.Sort{
+	Fields: map[string]string{
+		"created_at": "created_at",
+		"name":       "name",
+		"usage":      "usage_count",
+	},
+	Default: "-created_at",
+}
+
+// CreatePromotion creates a promotion (owners and admins only)
+func CreatePromotion(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	var promotion models.Promotion
+	if err := c.ShouldBindJSON(&promotion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePromotion(&promotion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Usage is counted by checkout
+	promotion.ID = uuid.New()
+	promotion.UsageCount = 0
+	if result := database.Scoped(c.Request.Context()).Create(&promotion); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, promotion)
+}
+
+// ListPromotions retrieves a page of promotions, optionally only active ones
+func ListPromotions(c *gin.Context) {
+	req, err := paging.FromRequest(c, promotionSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.Scoped(c.Request.Context()).Model(&models.Promotion{})
+	if value := c.Query("active"); value != "" {
+		query = query.Where("active = ?", value == "true")
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	var list []models.Promotion
+	if result := req.Apply(query).Find(&list); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	page, err := paging.NewPage(database.DB, req, list, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetPromotion retrieves a promotion by ID
+func GetPromotion(c *gin.Context) {
+	promotion, ok := loadPromotion(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, promotion)
+}
+
+// UpdatePromotion updates a promotion by ID (owners and admins only). Its usage
+// count is kept.
+func UpdatePromotion(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	existing, ok := loadPromotion(c)
+	if !ok {
+		return
+	}
+
+	var promotion models.Promotion
+	if err := c.ShouldBindJSON(&promotion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePromotion(&promotion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion.ID = existing.ID
+	promotion.CreatedAt = existing.CreatedAt
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		// Take the latest count, checkout may have used the promotion meanwhile
+		if err := tx.Model(&models.Promotion{}).Where("id = ?", existing.ID).Pluck("usage_count", &promotion.UsageCount).Error; err != nil {
+			return err
+		}
+		return tx.Save(&promotion).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, promotion)
+}
+
+// DeletePromotion deletes a promotion by ID (owners and admins only). Adjustments
+// already recorded on orders keep its name.
+func DeletePromotion(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	promotion, ok := loadPromotion(c)
+	if !ok {
+		return
+	}
+
+	if result := database.Scoped(c.Request.Context()).Delete(promotion); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Promotion deleted successfully"})
+}
+
+// loadPromotion loads the promotion from the :id path parameter
+func loadPromotion(c *gin.Context) (*models.Promotion, bool) {
+	promotionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion ID"})
+		return nil, false
+	}
+
+	var promotion models.Promotion
+	if result := database.Scoped(c.Request.Context()).First(&promotion, "id = ?", promotionID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+		return nil, false
+	}
+	return &promotion, true
+}
+
+// requireManager rejects callers who aren't owners or admins of the tenant
+func requireManager(c *gin.Context) bool {
+	if !tenant.IsManager(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only organization owners and admins can change store settings"})
+		return false
+	}
+	return true
+}
+
+// validatePromotion checks the promotion's type and the fields its type uses,
+// clearing the ones it doesn't
+func validatePromotion(promotion *models.Promotion) error {
+	promotion.Name = strings.TrimSpace(promotion.Name)
+	if promotion.Name == "" {
+		return errors.New("Name is required")
+	}
+
+	switch promotion.Type {
+	case models.PromotionPercentage:
+		if _, err := promotions.Percentage(promotion.Percentage); err != nil {
+			return errors.New("Percentage must be above 0 and at most 100")
+		}
+		promotion.AmountOff = money.Money{}
+		promotion.BuyQuantity, promotion.GetQuantity = 0, 0
+	case models.PromotionFixed:
+		promotion.AmountOff.Currency = strings.ToUpper(promotion.AmountOff.Currency)
+		if err := promotion.AmountOff.Validate(); err != nil {
+			return err
+		}
+		if !promotion.AmountOff.IsPositive() {
+			return errors.New("Amount off must be greater than zero")
+		}
+		promotion.Percentage = "0"
+		promotion.BuyQuantity, promotion.GetQuantity = 0, 0
+	case models.PromotionBuyXGetY:
+		if promotion.BuyQuantity < 1 || promotion.GetQuantity < 1 {
+			return errors.New("Buy and get quantities must be at least 1")
+		}
+		promotion.Percentage = "0"
+		promotion.AmountOff = money.Money{}
+	default:
+		return errors.New("Type must be percentage, fixed or buy_x_get_y")
+	}
+
+	if promotion.MinQuantity < 0 {
+		return errors.New("Minimum quantity cannot be negative")
+	}
+	if promotion.StartsAt != nil && promotion.EndsAt != nil && !promotion.EndsAt.After(*promotion.StartsAt) {
+		return errors.New("Promotion must end after it starts")
+	}
+	if (promotion.UsageLimit != nil && *promotion.UsageLimit < 1) || (promotion.PerUserLimit != nil && *promotion.PerUserLimit < 1) {
+		return errors.New("Usage limits must be at least 1")
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/order-service/database"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"gorm.io/gorm"
+)
+
+// GetDeliveredPurchase returns the user's earliest delivered order of a product in
+// the tenant, so product-service can verify purchases before accepting a review
+// (internal only). It responds 404 when the user has no such order.
+func GetDeliveredPurchase(c *gin.Context) {
+	var ids [3]uuid.UUID
+	for i, param := range []string{"tenantId", "userId", "productId"} {
+		id, err := uuid.Parse(c.Param(param))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+			return
+		}
+		ids[i] = id
+	}
+
+	var order models.Order
+	result := database.DB.
+		Where("tenant_id = ? AND user_id = ? AND product_id = ? AND status = ?", ids[0], ids[1], ids[2], models.OrderStatusDelivered).
+		Order("created_at").
+		First(&order)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No delivered order of this product"})
+		return
+	}
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order_id": order.ID, "delivered_at": order.UpdatedAt})
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/tenant"
+	"github.com/ozturkeniss/gomicro-app/order-service/database"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// shipmentEventRequest is a status update of a shipment
+type shipmentEventRequest struct {
+	Status     string     `json:"status" binding:"required"`
+	Location   string     `json:"location"`
+	Note       string     `json:"note"`
+	OccurredAt *time.Time `json:"occurred_at"`
+}
+
+// event validates the request and builds the event it records
+func (r shipmentEventRequest) event(c *gin.Context) (models.ShipmentEvent, bool) {
+	event := models.ShipmentEvent{
+		ID:         uuid.New(),
+		Status:     strings.ToLower(strings.TrimSpace(r.Status)),
+		Location:   strings.TrimSpace(r.Location),
+		Note:       strings.TrimSpace(r.Note),
+		OccurredAt: time.Now(),
+	}
+	if !models.ValidShipmentStatus(event.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be label_created, in_transit, out_for_delivery, delivered or exception"})
+		return event, false
+	}
+	if len(event.Location) > 255 || len(event.Note) > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location and note must be at most 255 and 1000 characters"})
+		return event, false
+	}
+	if r.OccurredAt != nil {
+		event.OccurredAt = *r.OccurredAt
+	}
+	return event, true
+}
+
+// CreateShipment records a parcel sent for an order with its carrier and
+// tracking number (owners and admins only). A shipment created in transit
+// ships the order.
+func CreateShipment(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	var req struct {
+		MethodID       *uuid.UUID `json:"method_id"`
+		Carrier        string     `json:"carrier"`
+		TrackingNumber string     `json:"tracking_number" binding:"required"`
+		Status         string     `json:"status"`
+		Location       string     `json:"location"`
+		Note           string     `json:"note"`
+		OccurredAt     *time.Time `json:"occurred_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Status == "" {
+		req.Status = models.ShipmentLabelCreated
+	}
+	event, ok := shipmentEventRequest{Status: req.Status, Location: req.Location, Note: req.Note, OccurredAt: req.OccurredAt}.event(c)
+	if !ok {
+		return
+	}
+
+	var order models.Order
+	if result := database.Scoped(c.Request.Context()).First(&order, "id = ?", orderID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if order.Closed() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cancelled and refunded orders can't be shipped"})
+		return
+	}
+
+	// The method defaults to the one chosen at checkout and supplies the carrier
+	shipment := models.Shipment{
+		ID:             uuid.New(),
+		OrderID:        order.ID,
+		MethodID:       order.ShippingMethodID,
+		Carrier:        strings.ToLower(strings.TrimSpace(req.Carrier)),
+		TrackingNumber: strings.TrimSpace(req.TrackingNumber),
+		Status:         models.ShipmentLabelCreated,
+	}
+	if req.MethodID != nil {
+		shipment.MethodID = req.MethodID
+	}
+	if shipment.Carrier == "" && shipment.MethodID != nil {
+		var method models.ShippingMethod
+		if result := database.Scoped(c.Request.Context()).First(&method, "id = ?", *shipment.MethodID); result.Error != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Shipping method not found"})
+			return
+		}
+		shipment.Carrier = strings.ToLower(method.Carrier)
+	}
+	if shipment.Carrier == "" || len(shipment.Carrier) > 64 || shipment.TrackingNumber == "" || len(shipment.TrackingNumber) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Carrier and tracking number are required and must be at most 64 and 100 characters"})
+		return
+	}
+
+	result := database.Scoped(c.Request.Context()).Clauses(clause.OnConflict{DoNothing: true}).Create(&shipment)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A shipment with this carrier and tracking number already exists"})
+		return
+	}
+
+	if err := recordShipmentEvent(c.Request.Context(), &shipment, event); err != nil {
+		writeOrderStatusError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, shipment)
+}
+
+// ListOrderShipments retrieves the shipments of an order, for the customer who
+// placed it or owners and admins
+func ListOrderShipments(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	if _, ok := loadAccessibleOrder(c, orderID, "Order not found"); !ok {
+		return
+	}
+
+	var shipments []models.Shipment
+	result := database.Scoped(c.Request.Context()).Where("order_id = ?", orderID).Order("created_at").Find(&shipments)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, shipments)
+}
+
+// GetShipment retrieves a shipment with its tracking history, for the customer
+// who placed its order or owners and admins
+func GetShipment(c *gin.Context) {
+	shipment, ok := loadShipment(c)
+	if !ok {
+		return
+	}
+	if _, ok := loadAccessibleOrder(c, shipment.OrderID, "Shipment not found"); !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, shipment)
+}
+
+// UpdateShipmentStatus records a status update of a shipment (owners and
+// admins only) and moves its order along
+func UpdateShipmentStatus(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	shipment, ok := loadShipment(c)
+	if !ok {
+		return
+	}
+	var req shipmentEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	event, ok := req.event(c)
+	if !ok {
+		return
+	}
+
+	if err := recordShipmentEvent(c.Request.Context(), shipment, event); err != nil {
+		writeOrderStatusError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, shipment)
+}
+
+// RecordCarrierEvent records a tracking update pushed by a carrier integration,
+// finding the shipment by carrier and tracking number (internal only)
+func RecordCarrierEvent(c *gin.Context) {
+	var req struct {
+		shipmentEventRequest
+		Carrier        string `json:"carrier" binding:"required"`
+		TrackingNumber string `json:"tracking_number" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	event, ok := req.event(c)
+	if !ok {
+		return
+	}
+
+	var shipment models.Shipment
+	result := database.DB.
+		Where("carrier = ? AND tracking_number = ?", strings.ToLower(strings.TrimSpace(req.Carrier)), strings.TrimSpace(req.TrackingNumber)).
+		First(&shipment)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	ctx := tenant.WithTenant(c.Request.Context(), shipment.TenantID)
+	if err := recordShipmentEvent(ctx, &shipment, event); err != nil {
+		writeOrderStatusError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, shipment)
+}
+
+// loadShipment loads the shipment and its events from the :id path parameter
+func loadShipment(c *gin.Context) (*models.Shipment, bool) {
+	shipmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shipment ID"})
+		return nil, false
+	}
+
+	var shipment models.Shipment
+	result := database.Scoped(c.Request.Context()).
+		Preload("Events", func(db *gorm.DB) *gorm.DB { return db.Order("occurred_at") }).
+		First(&shipment, "id = ?", shipmentID)
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return nil, false
+	}
+	return &shipment, true
+}
+
+// recordShipmentEvent records a status update of the shipment and moves its
+// order along. Delivered is final, so late updates from the carrier only add
+// to the history of delivered shipments.
+func recordShipmentEvent(ctx context.Context, shipment *models.Shipment, event models.ShipmentEvent) error {
+	event.ShipmentID = shipment.ID
+	err := database.Scoped(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		if shipment.Status != models.ShipmentDelivered {
+			shipment.Status = event.Status
+		}
+		switch event.Status {
+		case models.ShipmentInTransit, models.ShipmentOutForDelivery, models.ShipmentDelivered:
+			if shipment.ShippedAt == nil {
+				shipment.ShippedAt = &event.OccurredAt
+			}
+		}
+		if event.Status == models.ShipmentDelivered && shipment.DeliveredAt == nil {
+			shipment.DeliveredAt = &event.OccurredAt
+		}
+		return tx.Omit(clause.Associations).Save(shipment).Error
+	})
+	if err != nil {
+		return err
+	}
+	shipment.Events = append(shipment.Events, event)
+
+	return advanceOrder(ctx, shipment.OrderID)
+}
+
+// advanceOrder moves the order to shipped once any of its shipments has left,
+// and to delivered once all of them have arrived. Orders never move back.
+func advanceOrder(ctx context.Context, orderID uuid.UUID) error {
+	var order models.Order
+	if err := database.Scoped(ctx).First(&order, "id = ?", orderID).Error; err != nil {
+		return err
+	}
+	if order.Closed() || order.Status == models.OrderStatusDelivered {
+		return nil
+	}
+
+	var shipments []models.Shipment
+	if err := database.Scoped(ctx).Where("order_id = ?", orderID).Find(&shipments).Error; err != nil {
+		return err
+	}
+	shipped, delivered := false, len(shipments) > 0
+	for _, shipment := range shipments {
+		if shipment.ShippedAt != nil {
+			shipped = true
+		}
+		if shipment.Status != models.ShipmentDelivered {
+			delivered = false
+		}
+	}
+
+	switch {
+	case delivered:
+		return setOrderStatus(ctx, &order, models.OrderStatusDelivered)
+	case shipped && (order.Status == models.OrderStatusPending || order.Status == models.OrderStatusPaid):
+		return setOrderStatus(ctx, &order, models.OrderStatusShipped)
+	}
+	return nil
+}
@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/common/paging"
+	"github.com/ozturkeniss/gomicro-app/order-service/clients"
+	"github.com/ozturkeniss/gomicro-app/order-service/database"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"github.com/ozturkeniss/gomicro-app/order-service/shipping"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// shippingMethodSort lists the fields shipping method lists can be sorted by
+var shippingMethodSort = paging.Sort{
+	Fields: map[string]string{
+		"name":       "name",
+		"code":       "code",
+		"created_at": "created_at",
+	},
+	Default: "name",
+}
+
+// shippingMethodRequest is the body of shipping method writes. Rates replace
+// the method's whole rate table.
+type shippingMethodRequest struct {
+	Code    string                `json:"code" binding:"required"`
+	Name    string                `json:"name" binding:"required"`
+	Carrier string                `json:"carrier"`
+	Active  *bool                 `json:"active"`
+	Rates   []models.ShippingRate `json:"rates"`
+}
+
+// apply validates the request and sets it on the method
+func (r shippingMethodRequest) apply(c *gin.Context, method *models.ShippingMethod) bool {
+	method.Code = strings.ToLower(strings.TrimSpace(r.Code))
+	method.Name = strings.TrimSpace(r.Name)
+	method.Carrier = strings.TrimSpace(r.Carrier)
+	if method.Code == "" || len(method.Code) > 32 || method.Name == "" || len(method.Name) > 255 || len(method.Carrier) > 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code and name are required; code, name and carrier must be at most 32, 255 and 64 characters"})
+		return false
+	}
+	method.Active = r.Active == nil || *r.Active
+
+	method.Rates = make([]models.ShippingRate, len(r.Rates))
+	for i, rate := range r.Rates {
+		if err := validateShippingRate(&rate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		rate.ID, rate.MethodID = uuid.New(), method.ID
+		method.Rates[i] = rate
+	}
+	return true
+}
+
+// CreateShippingMethod adds a shipping method with its rate table (owners and
+// admins only)
+func CreateShippingMethod(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	var req shippingMethodRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	method := models.ShippingMethod{ID: uuid.New()}
+	if !req.apply(c, &method) {
+		return
+	}
+
+	taken := false
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(&method)
+		if result.Error != nil || result.RowsAffected == 0 {
+			taken = result.Error == nil
+			return result.Error
+		}
+		if len(method.Rates) == 0 {
+			return nil
+		}
+		return tx.Create(&method.Rates).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "A shipping method with this code already exists"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, method)
+}
+
+// ListShippingMethods retrieves a page of shipping methods with their rates,
+// optionally only the active ones
+func ListShippingMethods(c *gin.Context) {
+	req, err := paging.FromRequest(c, shippingMethodSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.Scoped(c.Request.Context()).Model(&models.ShippingMethod{})
+	if c.Query("active") == "true" {
+		query = query.Where("active = ?", true)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	var methods []models.ShippingMethod
+	if result := req.Apply(query).Preload("Rates").Find(&methods); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	page, err := paging.NewPage(database.DB, req, methods, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetShippingMethod retrieves a shipping method with its rates
+func GetShippingMethod(c *gin.Context) {
+	method, ok := loadShippingMethod(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, method)
+}
+
+// UpdateShippingMethod replaces a shipping method and its rate table (owners
+// and admins only). Orders keep the shipping cost they were charged.
+func UpdateShippingMethod(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	method, ok := loadShippingMethod(c)
+	if !ok {
+		return
+	}
+	var req shippingMethodRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.apply(c, method) {
+		return
+	}
+
+	var taken int64
+	err := database.Scoped(c.Request.Context()).Model(&models.ShippingMethod{}).
+		Where("code = ? AND id <> ?", method.Code, method.ID).
+		Count(&taken).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if taken > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A shipping method with this code already exists"})
+		return
+	}
+
+	err = database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(method).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("method_id = ?", method.ID).Delete(&models.ShippingRate{}).Error; err != nil {
+			return err
+		}
+		if len(method.Rates) == 0 {
+			return nil
+		}
+		return tx.Create(&method.Rates).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, method)
+}
+
+// DeleteShippingMethod deletes a shipping method and its rates (owners and
+// admins only). Orders and shipments keep referring to it by ID.
+func DeleteShippingMethod(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	method, ok := loadShippingMethod(c)
+	if !ok {
+		return
+	}
+
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("method_id = ?", method.ID).Delete(&models.ShippingRate{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(method).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Shipping method deleted successfully"})
+}
+
+// QuoteShipping prices shipping an item to a destination with every active
+// shipping method that delivers it, cheapest first. Checkout shows these and
+// sends the chosen method's ID as the order's ShippingMethodID. Quotes are
+// based on the price before discounts, so a discounted order can cross a
+// rate's order value threshold and be charged differently.
+func QuoteShipping(c *gin.Context) {
+	var req struct {
+		ProductID         uuid.UUID  `json:"product_id"`
+		SKU               string     `json:"sku"`
+		Quantity          int        `json:"quantity" binding:"required,min=1"`
+		ShippingAddressID *uuid.UUID `json:"shipping_address_id"`
+		Country           string     `json:"country"`
+		Region            string     `json:"region"`
+		Currency          string     `json:"currency"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Ship to the saved address, or to a country and region before one is chosen
+	parcel := shipping.Parcel{Country: strings.ToUpper(strings.TrimSpace(req.Country)), Region: strings.TrimSpace(req.Region)}
+	if req.ShippingAddressID != nil {
+		address, err := clients.GetAddress(c.Request.Context(), c.GetHeader("Authorization"), *req.ShippingAddressID)
+		if errors.Is(err, clients.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Shipping address not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch shipping address"})
+			return
+		}
+		parcel.Country, parcel.Region = strings.ToUpper(address.Country), address.Region
+	}
+	if len(parcel.Country) != 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ShippingAddressID or a two-letter country is required"})
+		return
+	}
+
+	// Find the item by variant SKU, product SKU or product ID
+	var product models.Product
+	var override *money.Amount
+	db := database.Scoped(c.Request.Context())
+	if req.SKU != "" {
+		var variant models.Variant
+		if result := db.First(&variant, "sku = ?", req.SKU); result.Error == nil {
+			req.ProductID, override = variant.ProductID, variant.PriceOverride
+		} else if result := db.First(&product, "sku = ?", req.SKU); result.Error == nil {
+			req.ProductID = product.ID
+		} else {
+			c.JSON(http.StatusNotFound, gin.H{"error": "SKU not found"})
+			return
+		}
+	}
+	if result := db.First(&product, "id = ?", req.ProductID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	price := product.Price
+	if override != nil {
+		price.Amount = *override
+	}
+
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = price.Currency
+	}
+	if !money.ValidCurrency(currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return
+	}
+	rates, err := database.LoadRateTable(database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	convert := func(amount money.Money) (money.Money, error) {
+		rate, err := rates.Rate(amount.Currency, currency)
+		if err != nil {
+			return money.Money{}, err
+		}
+		return amount.Convert(currency, rate)
+	}
+
+	unitPrice, err := convert(price)
+	if err == nil {
+		parcel.Value, err = unitPrice.Mul(int64(req.Quantity))
+	}
+	if errors.Is(err, money.ErrNoRate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No exchange rate from " + price.Currency + " to " + currency})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	parcel.WeightGrams = product.WeightGrams * req.Quantity
+
+	quotes, err := shipping.Quotes(db, parcel, convert)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	options := make([]gin.H, len(quotes))
+	for i, quote := range quotes {
+		options[i] = gin.H{
+			"method_id": quote.Method.ID,
+			"code":      quote.Method.Code,
+			"name":      quote.Method.Name,
+			"carrier":   quote.Method.Carrier,
+			"price":     quote.Price,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"weight_grams": parcel.WeightGrams, "order_value": parcel.Value, "quotes": options})
+}
+
+// loadShippingMethod loads the shipping method and its rates from the :id path
+// parameter
+func loadShippingMethod(c *gin.Context) (*models.ShippingMethod, bool) {
+	methodID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shipping method ID"})
+		return nil, false
+	}
+
+	var method models.ShippingMethod
+	if result := database.Scoped(c.Request.Context()).Preload("Rates").First(&method, "id = ?", methodID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipping method not found"})
+		return nil, false
+	}
+	return &method, true
+}
+
+// validateShippingRate normalizes the rate's destination and checks its ranges
+// and price
+func validateShippingRate(rate *models.ShippingRate) error {
+	rate.Country = strings.ToUpper(strings.TrimSpace(rate.Country))
+	rate.Region = strings.TrimSpace(rate.Region)
+	if rate.Country != "" && len(rate.Country) != 2 {
+		return errors.New("Rate country must be a two-letter ISO code")
+	}
+	if rate.Region != "" && (rate.Country == "" || len(rate.Region) > 100) {
+		return errors.New("Rate regions need a country and must be at most 100 characters")
+	}
+	if rate.MinWeightGrams < 0 || (rate.MaxWeightGrams != nil && *rate.MaxWeightGrams != 0 && *rate.MaxWeightGrams <= rate.MinWeightGrams) {
+		return errors.New("Rate weight range must be non-negative with the maximum above the minimum")
+	}
+	if rate.MaxWeightGrams != nil && *rate.MaxWeightGrams == 0 {
+		rate.MaxWeightGrams = nil
+	}
+	for _, amount := range []money.Money{rate.MinOrderValue, rate.MaxOrderValue, rate.Price} {
+		if amount.IsNegative() {
+			return errors.New("Rate prices and order values cannot be negative")
+		}
+	}
+	if rate.Price.Currency == "" {
+		return errors.New("Rate price is required")
+	}
+	if err := rate.Price.Validate(); err != nil {
+		return err
+	}
+	if rate.MinOrderValue.IsPositive() && rate.MaxOrderValue.IsPositive() {
+		if cmp, err := rate.MaxOrderValue.Cmp(rate.MinOrderValue); err != nil || cmp <= 0 {
+			return errors.New("Rate order value range must be in one currency with the maximum above the minimum")
+		}
+	}
+	return nil
+}
+
+// shipOrder charges shipping the order's goods, worth value after discounts,
+// with the order's shipping method, adding the cost to the total as an
+// adjustment. Orders without a shipping method ship for free.
+func shipOrder(tx *gorm.DB, order *models.Order, product *models.Product, value money.Money, convert shipping.Converter) error {
+	order.ShippingCost = money.Zero(order.TotalPrice.Currency)
+	if order.ShippingMethodID == nil {
+		return nil
+	}
+	method, err := shipping.Method(tx, *order.ShippingMethodID)
+	if err != nil {
+		return err
+	}
+	quote, err := shipping.Price(*method, shipping.Parcel{
+		Country:     strings.ToUpper(order.ShippingAddress.Country),
+		Region:      order.ShippingAddress.Region,
+		WeightGrams: product.WeightGrams * order.Quantity,
+		Value:       value,
+	}, convert)
+	if err != nil {
+		return err
+	}
+
+	order.ShippingCost = quote.Price
+	if !quote.Price.IsPositive() {
+		return nil
+	}
+	order.Adjustments = append(order.Adjustments, models.OrderAdjustment{
+		ID:          uuid.New(),
+		OrderID:     order.ID,
+		Type:        models.AdjustmentShipping,
+		Description: "Shipping: " + method.Name,
+		Amount:      quote.Price,
+	})
+	order.TotalPrice, err = order.TotalPrice.Add(quote.Price)
+	return err
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/common/paging"
+	"github.com/ozturkeniss/gomicro-app/order-service/database"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"github.com/ozturkeniss/gomicro-app/order-service/tax"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// taxRateSort lists the fields tax rate lists can be sorted by
+var taxRateSort = paging.Sort{
+	Fields: map[string]string{
+		"country":    "country",
+		"created_at": "created_at",
+	},
+	Default: "country",
+}
+
+// taxRateRequest is the body of tax rate writes
+type taxRateRequest struct {
+	Country  string `json:"country" binding:"required"`
+	Region   string `json:"region"`
+	TaxClass string `json:"tax_class"`
+	Name     string `json:"name" binding:"required"`
+	Rate     string `json:"rate" binding:"required"`
+}
+
+// apply validates the request and sets it on the rate
+func (r taxRateRequest) apply(c *gin.Context, rate *models.TaxRate) bool {
+	rate.Country = strings.ToUpper(strings.TrimSpace(r.Country))
+	rate.Region = strings.TrimSpace(r.Region)
+	rate.TaxClass = strings.ToLower(strings.TrimSpace(r.TaxClass))
+	rate.Name = strings.TrimSpace(r.Name)
+	if len(rate.Country) != 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Country must be a two-letter ISO code"})
+		return false
+	}
+	if len(rate.Region) > 100 || len(rate.TaxClass) > 32 || rate.Name == "" || len(rate.Name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required; region, tax class and name must be at most 100, 32 and 100 characters"})
+		return false
+	}
+	parsed, err := tax.ParseRate(r.Rate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rate must be a percentage between 0 and 100"})
+		return false
+	}
+	rate.Rate = tax.FormatRate(parsed)
+	return true
+}
+
+// CreateTaxRate adds a tax rate (owners and admins only)
+func CreateTaxRate(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	var req taxRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rate := models.TaxRate{ID: uuid.New()}
+	if !req.apply(c, &rate) {
+		return
+	}
+
+	result := database.Scoped(c.Request.Context()).Clauses(clause.OnConflict{DoNothing: true}).Create(&rate)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A rate for this country, region and tax class already exists"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rate)
+}
+
+// ListTaxRates retrieves a page of tax rates, optionally for one country
+func ListTaxRates(c *gin.Context) {
+	req, err := paging.FromRequest(c, taxRateSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.Scoped(c.Request.Context()).Model(&models.TaxRate{})
+	if country := c.Query("country"); country != "" {
+		query = query.Where("country = ?", strings.ToUpper(country))
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	var rates []models.TaxRate
+	if result := req.Apply(query).Find(&rates); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	page, err := paging.NewPage(database.DB, req, rates, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// UpdateTaxRate replaces a tax rate (owners and admins only)
+func UpdateTaxRate(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	rate, ok := loadTaxRate(c)
+	if !ok {
+		return
+	}
+	var req taxRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.apply(c, rate) {
+		return
+	}
+
+	var taken int64
+	err := database.Scoped(c.Request.Context()).Model(&models.TaxRate{}).
+		Where("country = ? AND region = ? AND tax_class = ? AND id <> ?", rate.Country, rate.Region, rate.TaxClass, rate.ID).
+		Count(&taken).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if taken > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A rate for this country, region and tax class already exists"})
+		return
+	}
+	if result := database.Scoped(c.Request.Context()).Save(rate); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+// DeleteTaxRate deletes a tax rate (owners and admins only). Orders keep the
+// tax they were charged.
+func DeleteTaxRate(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	rate, ok := loadTaxRate(c)
+	if !ok {
+		return
+	}
+
+	if result := database.Scoped(c.Request.Context()).Delete(rate); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tax rate deleted successfully"})
+}
+
+// GetTaxSettings returns the tenant's tax settings and the active tax provider
+func GetTaxSettings(c *gin.Context) {
+	settings, err := loadTaxSettings(database.Scoped(c.Request.Context()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"prices_include_tax": settings.PricesIncludeTax, "provider": tax.Provider})
+}
+
+// UpdateTaxSettings sets whether the tenant's prices include tax (owners and
+// admins only). Existing orders keep the mode they were priced with.
+func UpdateTaxSettings(c *gin.Context) {
+	if !requireManager(c) {
+		return
+	}
+	var req struct {
+		PricesIncludeTax *bool `json:"prices_include_tax" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tenantID, err := uuid.Parse(c.GetString("tenantID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant"})
+		return
+	}
+
+	settings := models.TaxSettings{TenantID: tenantID, PricesIncludeTax: *req.PricesIncludeTax}
+	if result := database.Scoped(c.Request.Context()).Save(&settings); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"prices_include_tax": settings.PricesIncludeTax, "provider": tax.Provider})
+}
+
+// loadTaxRate loads the tax rate from the :id path parameter
+func loadTaxRate(c *gin.Context) (*models.TaxRate, bool) {
+	rateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tax rate ID"})
+		return nil, false
+	}
+
+	var rate models.TaxRate
+	if result := database.Scoped(c.Request.Context()).First(&rate, "id = ?", rateID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tax rate not found"})
+		return nil, false
+	}
+	return &rate, true
+}
+
+// loadTaxSettings returns the tenant's tax settings, or the defaults
+func loadTaxSettings(db *gorm.DB) (models.TaxSettings, error) {
+	var settings models.TaxSettings
+	err := db.Limit(1).Find(&settings).Error
+	return settings, err
+}
+
+// taxOrder taxes the order's line at its discounted amount with the configured
+// provider and records the tax on the order. Tax on prices that exclude it is
+// added to the total as an adjustment.
+func taxOrder(ctx context.Context, tx *gorm.DB, order *models.Order, product *models.Product) error {
+	settings, err := loadTaxSettings(tx)
+	if err != nil {
+		return err
+	}
+	lines, err := tax.Default.Calculate(ctx, tax.Request{
+		TenantID:         product.TenantID,
+		Country:          strings.ToUpper(order.ShippingAddress.Country),
+		Region:           order.ShippingAddress.Region,
+		PostalCode:       order.ShippingAddress.PostalCode,
+		PricesIncludeTax: settings.PricesIncludeTax,
+		Lines: []tax.Line{{
+			ProductID: order.ProductID,
+			TaxClass:  taxClass(product),
+			Quantity:  order.Quantity,
+			Amount:    order.TotalPrice,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	order.PricesIncludeTax = settings.PricesIncludeTax
+	order.TaxTotal = money.Zero(order.TotalPrice.Currency)
+	for _, line := range lines {
+		order.TaxLines = append(order.TaxLines, models.OrderTaxLine{
+			ID:           uuid.New(),
+			OrderID:      order.ID,
+			ProductID:    order.ProductID,
+			TaxClass:     taxClass(product),
+			Name:         line.Name,
+			Jurisdiction: line.Jurisdiction,
+			Rate:         tax.FormatRate(line.Rate),
+			Taxable:      line.Taxable,
+			Tax:          line.Tax,
+			Inclusive:    settings.PricesIncludeTax,
+			Provider:     tax.Provider,
+		})
+		if order.TaxTotal, err = order.TaxTotal.Add(line.Tax); err != nil {
+			return err
+		}
+	}
+
+	if settings.PricesIncludeTax || !order.TaxTotal.IsPositive() {
+		return nil
+	}
+	order.Adjustments = append(order.Adjustments, models.OrderAdjustment{
+		ID:          uuid.New(),
+		OrderID:     order.ID,
+		Type:        models.AdjustmentTax,
+		Description: "Tax",
+		Amount:      order.TaxTotal,
+	})
+	order.TotalPrice, err = order.TotalPrice.Add(order.TaxTotal)
+	return err
+}
+
+// taxClass returns the product's tax class, which older products may lack
+func taxClass(product *models.Product) string {
+	if product.TaxClass == "" {
+		return models.DefaultTaxClass
+	}
+	return product.TaxClass
+}
@@ -5,10 +5,19 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/micro/go-micro/v2"
 	"github.com/micro/go-micro/v2/server"
+	"github.com/ozturkeniss/gomicro-app/common/serviceauth"
+	"github.com/ozturkeniss/gomicro-app/common/tenant"
+	"github.com/ozturkeniss/gomicro-app/order-service/carts"
+	"github.com/ozturkeniss/gomicro-app/order-service/database"
+	"github.com/ozturkeniss/gomicro-app/order-service/handlers"
+	"github.com/ozturkeniss/gomicro-app/order-service/payments"
+	"github.com/ozturkeniss/gomicro-app/order-service/tax"
 )
 
 type OrderService struct{}
@@ -28,6 +37,14 @@ func HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 }
 
+// intervalFromEnv parses a duration such as "1h" from the environment
+func intervalFromEnv(name string, fallback time.Duration) time.Duration {
+	if interval, err := time.ParseDuration(os.Getenv(name)); err == nil && interval > 0 {
+		return interval
+	}
+	return fallback
+}
+
 func main() {
 	// Create a new service
 	service := micro.NewService(
@@ -45,15 +62,131 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// Initialize database
+	database.InitDB()
+
+	// Initialize the tax provider selected by TAX_PROVIDER
+	tax.Init(database.DB)
+
+	// Initialize the payment gateway selected by PAYMENT_PROVIDER
+	payments.Init()
+
+	// Expire carts left alone for longer than CART_TTL
+	carts.StartExpiryWorker(database.DB, intervalFromEnv("CART_EXPIRY_INTERVAL", 10*time.Minute))
+
+	// Create Gin router
+	r := gin.Default()
+
 	// Define routes
 	api := r.Group("/api")
 	{
 		// Health check endpoint
 		api.GET("/health", HealthCheck)
 
-		// ... existing routes ...
+		// OAuth clients need the orders scopes
+		readOrders, writeOrders := tenant.RequireScope("orders:read"), tenant.RequireScope("orders:write")
+
+		orders := api.Group("/orders", tenant.Middleware())
+		{
+			orders.POST("/", writeOrders, handlers.CreateOrder)
+			orders.GET("/:id", readOrders, handlers.GetOrder)
+			orders.PUT("/:id", writeOrders, handlers.UpdateOrder)
+			orders.DELETE("/:id", writeOrders, handlers.DeleteOrder)
+			orders.GET("/", readOrders, handlers.ListOrders)
+			orders.PUT("/:id/status", writeOrders, handlers.UpdateOrderStatus)
+			orders.POST("/:id/shipments", writeOrders, handlers.CreateShipment)
+			orders.GET("/:id/shipments", readOrders, handlers.ListOrderShipments)
+			orders.POST("/:id/payments", writeOrders, handlers.CreatePayment)
+			orders.GET("/:id/payments", readOrders, handlers.ListOrderPayments)
+		}
+
+		cartRoutes := api.Group("/carts", tenant.Middleware())
+		{
+			cartRoutes.POST("/", writeOrders, handlers.CreateCart)
+			cartRoutes.GET("/users/:userId", readOrders, handlers.GetUserCart)
+			cartRoutes.GET("/:id", readOrders, handlers.GetCart)
+			cartRoutes.POST("/:id/lines", writeOrders, handlers.AddCartLine)
+			cartRoutes.PUT("/:id/lines/:lineId", writeOrders, handlers.UpdateCartLine)
+			cartRoutes.DELETE("/:id/lines/:lineId", writeOrders, handlers.DeleteCartLine)
+			cartRoutes.POST("/:id/merge", writeOrders, handlers.MergeCart)
+			cartRoutes.POST("/:id/checkout", writeOrders, handlers.CheckoutCart)
+		}
+
+		shipments := api.Group("/shipments", tenant.Middleware())
+		{
+			shipments.GET("/:id", readOrders, handlers.GetShipment)
+			shipments.POST("/:id/events", writeOrders, handlers.UpdateShipmentStatus)
+		}
+
+		paymentRoutes := api.Group("/payments", tenant.Middleware())
+		{
+			paymentRoutes.GET("/:id", readOrders, handlers.GetPayment)
+			paymentRoutes.POST("/:id/capture", writeOrders, handlers.CapturePayment)
+			paymentRoutes.POST("/:id/void", writeOrders, handlers.VoidPayment)
+			paymentRoutes.POST("/:id/refund", writeOrders, handlers.RefundPayment)
+		}
+
+		shippingMethods := api.Group("/shipping", tenant.Middleware())
+		{
+			shippingMethods.POST("/quote", readOrders, handlers.QuoteShipping)
+			shippingMethods.GET("/methods", readOrders, handlers.ListShippingMethods)
+			shippingMethods.POST("/methods", writeOrders, handlers.CreateShippingMethod)
+			shippingMethods.GET("/methods/:id", readOrders, handlers.GetShippingMethod)
+			shippingMethods.PUT("/methods/:id", writeOrders, handlers.UpdateShippingMethod)
+			shippingMethods.DELETE("/methods/:id", writeOrders, handlers.DeleteShippingMethod)
+		}
+
+		promotions := api.Group("/promotions", tenant.Middleware())
+		{
+			promotions.POST("/", writeOrders, handlers.CreatePromotion)
+			promotions.GET("/", readOrders, handlers.ListPromotions)
+			promotions.GET("/:id", readOrders, handlers.GetPromotion)
+			promotions.PUT("/:id", writeOrders, handlers.UpdatePromotion)
+			promotions.DELETE("/:id", writeOrders, handlers.DeletePromotion)
+		}
+
+		coupons := api.Group("/coupons", tenant.Middleware())
+		{
+			coupons.POST("/", writeOrders, handlers.CreateCoupon)
+			coupons.POST("/batch", writeOrders, handlers.CreateCouponBatch)
+			coupons.GET("/", readOrders, handlers.ListCoupons)
+			coupons.GET("/redemptions", readOrders, handlers.ListCouponRedemptions)
+			coupons.GET("/redemptions/summary", readOrders, handlers.GetCouponRedemptionSummary)
+			coupons.GET("/:id", readOrders, handlers.GetCoupon)
+			coupons.PUT("/:id", writeOrders, handlers.UpdateCoupon)
+			coupons.DELETE("/:id", writeOrders, handlers.DeleteCoupon)
+		}
+
+		taxes := api.Group("/tax", tenant.Middleware())
+		{
+			taxes.GET("/rates", readOrders, handlers.ListTaxRates)
+			taxes.POST("/rates", writeOrders, handlers.CreateTaxRate)
+			taxes.PUT("/rates/:id", writeOrders, handlers.UpdateTaxRate)
+			taxes.DELETE("/rates/:id", writeOrders, handlers.DeleteTaxRate)
+			taxes.GET("/settings", readOrders, handlers.GetTaxSettings)
+			taxes.PUT("/settings", writeOrders, handlers.UpdateTaxSettings)
+		}
 	}
 
+	// Service-to-service endpoints; these are not tenant scoped
+	internal := r.Group("/internal", serviceauth.Middleware())
+	{
+		internal.GET("/users/:userId/orders", handlers.ListUserOrders)
+		internal.POST("/users/:userId/anonymize", handlers.AnonymizeUserData)
+		internal.GET("/tenants/:tenantId/users/:userId/purchases/:productId", handlers.GetDeliveredPurchase)
+		internal.POST("/shipments/events", handlers.RecordCarrierEvent)
+	}
+
+	// Payment provider webhooks; these are verified by the provider's signature
+	r.POST("/webhooks/payments/:provider", handlers.HandlePaymentWebhook)
+
+	// Serve the HTTP API alongside the micro service
+	go func() {
+		if err := r.Run(":8080"); err != nil {
+			log.Fatal("Failed to start server: ", err)
+		}
+	}()
+
 	// Run the service
 	if err := service.Run(); err != nil {
 		log.Fatal(err)
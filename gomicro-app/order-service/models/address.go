@@ -0,0 +1,14 @@
+package models
+
+// AddressSnapshot is a copy of an address-book entry taken when an order is placed,
+// so later edits to the user's address book don't rewrite historical orders
+type AddressSnapshot struct {
+	RecipientName string `gorm:"size:255"`
+	Line1         string `gorm:"size:255"`
+	Line2         string `gorm:"size:255"`
+	City          string `gorm:"size:100"`
+	Region        string `gorm:"size:100"`
+	PostalCode    string `gorm:"size:20"`
+	Country       string `gorm:"size:2"`
+	Phone         string `gorm:"size:30"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+)
+
+// Cart statuses. Only active carts can be changed.
+const (
+	CartActive    = "active"
+	CartMerged    = "merged"
+	CartConverted = "converted"
+	CartExpired   = "expired"
+)
+
+// Cart collects items before checkout. Anonymous carts have no UserID; a user
+// has at most one active cart.
+type Cart struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	UserID    *uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_carts_active_user,where:status = 'active'" json:"user_id"`
+	Currency  string     `gorm:"type:char(3);not null;default:''" json:"currency"`
+	Status    string     `gorm:"size:20;not null;index" json:"status"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	Lines     []CartLine `gorm:"foreignKey:CartID" json:"lines"`
+	CreatedAt time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"not null" json:"updated_at"`
+
+	// MergedInto is the cart a merged cart's lines moved to
+	MergedInto *uuid.UUID `gorm:"type:uuid" json:"merged_into,omitempty"`
+
+	// OrderIDs are the orders a converted cart was checked out as
+	OrderIDs []uuid.UUID `gorm:"serializer:json;type:jsonb" json:"order_ids,omitempty"`
+
+	// Subtotal and Valid are filled in when the cart is priced; they are not stored
+	Subtotal *money.Money `gorm:"-" json:"subtotal,omitempty"`
+	Valid    bool         `gorm:"-" json:"valid"`
+}
+
+// TableName specifies the table name for the Cart model
+func (Cart) TableName() string {
+	return "carts"
+}
+
+// CartLine is an item in a cart. UnitPrice is the price when the item was
+// added, in the cart's currency.
+type CartLine struct {
+	ID        uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID  uuid.UUID   `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	CartID    uuid.UUID   `gorm:"type:uuid;not null;index" json:"cart_id"`
+	ProductID uuid.UUID   `gorm:"type:uuid;not null" json:"product_id"`
+	VariantID *uuid.UUID  `gorm:"type:uuid" json:"variant_id,omitempty"`
+	SKU       string      `gorm:"size:64;not null;default:''" json:"sku"`
+	Quantity  int         `gorm:"not null" json:"quantity"`
+	UnitPrice money.Money `gorm:"embedded;embeddedPrefix:unit_price_" json:"unit_price"`
+	CreatedAt time.Time   `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time   `gorm:"not null" json:"updated_at"`
+
+	// The live price and stock check, filled in when the cart is priced
+	Name         string       `gorm:"-" json:"name,omitempty"`
+	CurrentPrice *money.Money `gorm:"-" json:"current_price,omitempty"`
+	Total        *money.Money `gorm:"-" json:"total,omitempty"`
+	PriceChanged bool         `gorm:"-" json:"price_changed"`
+	Problem      string       `gorm:"-" json:"problem,omitempty"`
+}
+
+// TableName specifies the table name for the CartLine model
+func (CartLine) TableName() string {
+	return "cart_lines"
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+)
+
+// Coupon is a code that unlocks a promotion at checkout. Codes are stored in
+// upper case and matched case-insensitively.
+type Coupon struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID    uuid.UUID  `gorm:"type:uuid;not null;index;uniqueIndex:idx_coupons_tenant_code" json:"tenant_id"`
+	PromotionID uuid.UUID  `gorm:"type:uuid;not null;index" json:"promotion_id"`
+	BatchID     *uuid.UUID `gorm:"type:uuid;index" json:"batch_id,omitempty"`
+	Code        string     `gorm:"size:64;not null;uniqueIndex:idx_coupons_tenant_code" json:"code"`
+
+	// UsageLimit caps how many orders can redeem the code, PerUserLimit how many
+	// orders of one user can; nil means unlimited
+	UsageLimit      *int `json:"usage_limit"`
+	PerUserLimit    *int `json:"per_user_limit"`
+	RedemptionCount int  `gorm:"not null;default:0" json:"redemption_count"`
+
+	// MinOrderValue is the subtotal an order needs to redeem the code, converted
+	// to the order's currency; a zero amount means no minimum
+	MinOrderValue money.Money `gorm:"embedded;embeddedPrefix:min_order_value_" json:"min_order_value"`
+	ExpiresAt     *time.Time  `json:"expires_at"`
+	Active        bool        `gorm:"not null;default:true" json:"active"`
+	CreatedAt     time.Time   `gorm:"not null" json:"created_at"`
+	UpdatedAt     time.Time   `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the Coupon model
+func (Coupon) TableName() string {
+	return "coupons"
+}
+
+// CouponRedemption records an order that redeemed a coupon and the discount it got
+type CouponRedemption struct {
+	ID          uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID    uuid.UUID   `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	CouponID    uuid.UUID   `gorm:"type:uuid;not null;index" json:"coupon_id"`
+	Code        string      `gorm:"size:64;not null" json:"code"`
+	PromotionID uuid.UUID   `gorm:"type:uuid;not null;index" json:"promotion_id"`
+	BatchID     *uuid.UUID  `gorm:"type:uuid;index" json:"batch_id,omitempty"`
+	OrderID     uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex" json:"order_id"`
+	UserID      uuid.UUID   `gorm:"type:uuid;not null;index" json:"user_id"`
+	Discount    money.Money `gorm:"embedded;embeddedPrefix:discount_" json:"discount"`
+	CreatedAt   time.Time   `gorm:"not null;index" json:"created_at"`
+}
+
+// TableName specifies the table name for the CouponRedemption model
+func (CouponRedemption) TableName() string {
+	return "coupon_redemptions"
+}
@@ -0,0 +1,14 @@
+package models
+
+// ExchangeRate is a read-only view of the exchange_rates table owned by
+// product-service, used to price orders in the customer's currency
+type ExchangeRate struct {
+	Base  string
+	Quote string
+	Rate  string
+}
+
+// TableName specifies the table name for the ExchangeRate model
+func (ExchangeRate) TableName() string {
+	return "exchange_rates"
+}
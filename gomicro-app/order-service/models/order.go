@@ -4,22 +4,74 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
 	"gorm.io/gorm"
 )
 
+// Order statuses
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+)
+
 // Order represents the order model
 type Order struct {
-	ID         uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
-	UserID     uuid.UUID      `gorm:"type:uuid;not null"`
-	ProductID  uuid.UUID      `gorm:"type:uuid;not null"`
-	Quantity   int            `gorm:"not null"`
-	TotalPrice float64        `gorm:"not null"`
-	CreatedAt  time.Time      `gorm:"not null"`
-	UpdatedAt  time.Time      `gorm:"not null"`
-	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	ID                uuid.UUID       `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	TenantID          uuid.UUID       `gorm:"type:uuid;not null;index"`
+	UserID            uuid.UUID       `gorm:"type:uuid;not null"`
+	ProductID         uuid.UUID       `gorm:"type:uuid;not null"`
+	VariantID         *uuid.UUID      `gorm:"type:uuid;index"`
+	SKU               string          `gorm:"size:64"`
+	Quantity          int             `gorm:"not null"`
+	CouponCode        string          `gorm:"size:64;not null;default:''"`
+	Subtotal          money.Money     `gorm:"embedded;embeddedPrefix:subtotal_"`
+	TotalPrice        money.Money     `gorm:"embedded;embeddedPrefix:total_price_"`
+	TaxTotal          money.Money     `gorm:"embedded;embeddedPrefix:tax_total_"`
+	ShippingMethodID  *uuid.UUID      `gorm:"type:uuid"`
+	ShippingCost      money.Money     `gorm:"embedded;embeddedPrefix:shipping_cost_"`
+	PricesIncludeTax  bool            `gorm:"not null;default:false"`
+	Currency          string          `gorm:"type:char(3);not null;default:''"`
+	BaseCurrency      string          `gorm:"type:char(3);not null;default:''"`
+	ExchangeRate      string          `gorm:"type:numeric(24,10);not null;default:1"`
+	WarehouseID       *uuid.UUID      `gorm:"type:uuid;index"`
+	CartID            *uuid.UUID      `gorm:"type:uuid;index"`
+	Status            string          `gorm:"size:20;not null;default:pending"`
+	ShippingAddressID *uuid.UUID      `gorm:"type:uuid"`
+	ShippingAddress   AddressSnapshot `gorm:"embedded;embeddedPrefix:shipping_"`
+	AnonymizedAt      *time.Time
+	CreatedAt         time.Time      `gorm:"not null"`
+	UpdatedAt         time.Time      `gorm:"not null"`
+	DeletedAt         gorm.DeletedAt `gorm:"index"`
+
+	// Adjustments take the subtotal to the total; they are recorded at checkout
+	Adjustments []OrderAdjustment `gorm:"foreignKey:OrderID" json:",omitempty"`
+
+	// TaxLines break TaxTotal down per line; they are recorded at checkout
+	TaxLines []OrderTaxLine `gorm:"foreignKey:OrderID" json:",omitempty"`
+
+	// Shipments are the parcels the order was sent in
+	Shipments []Shipment `gorm:"foreignKey:OrderID" json:",omitempty"`
+
+	// Payments are the attempts to pay for the order
+	Payments []Payment `gorm:"foreignKey:OrderID" json:",omitempty"`
 }
 
 // TableName specifies the table name for the Order model
 func (Order) TableName() string {
 	return "orders"
-} 
\ No newline at end of file
+}
+
+// StockShipped reports whether the order's stock has left the warehouse
+func (o Order) StockShipped() bool {
+	return o.Status == OrderStatusShipped || o.Status == OrderStatusDelivered
+}
+
+// Closed reports whether the order was cancelled or refunded, after which its
+// status can't change
+func (o Order) Closed() bool {
+	return o.Status == OrderStatusCancelled || o.Status == OrderStatusRefunded
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+)
+
+// Payment statuses. A payment is pending while its authorization awaits the
+// gateway.
+const (
+	PaymentPending           = "pending"
+	PaymentAuthorized        = "authorized"
+	PaymentCaptured          = "captured"
+	PaymentPartiallyRefunded = "partially_refunded"
+	PaymentRefunded          = "refunded"
+	PaymentVoided            = "voided"
+	PaymentFailed            = "failed"
+)
+
+// Payment transaction types
+const (
+	TransactionAuthorize = "authorize"
+	TransactionCapture   = "capture"
+	TransactionVoid      = "void"
+	TransactionRefund    = "refund"
+)
+
+// Payment is an order's payment with a payment provider. Amount is what was
+// authorized; Captured and Refunded add up the transactions so far.
+type Payment struct {
+	ID           uuid.UUID            `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID     uuid.UUID            `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	OrderID      uuid.UUID            `gorm:"type:uuid;not null;index" json:"order_id"`
+	Provider     string               `gorm:"size:32;not null;uniqueIndex:idx_payments_provider_reference,where:reference <> ''" json:"provider"`
+	Reference    string               `gorm:"size:255;not null;default:'';uniqueIndex:idx_payments_provider_reference,where:reference <> ''" json:"reference"`
+	Status       string               `gorm:"size:20;not null;index" json:"status"`
+	Amount       money.Money          `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
+	Captured     money.Money          `gorm:"embedded;embeddedPrefix:captured_" json:"captured"`
+	Refunded     money.Money          `gorm:"embedded;embeddedPrefix:refunded_" json:"refunded"`
+	Transactions []PaymentTransaction `gorm:"foreignKey:PaymentID" json:"transactions,omitempty"`
+	CreatedAt    time.Time            `gorm:"not null" json:"created_at"`
+	UpdatedAt    time.Time            `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the Payment model
+func (Payment) TableName() string {
+	return "payments"
+}
+
+// PaymentTransaction is one operation on a payment, made through the API or
+// reported by the provider's webhook. Reference is the provider's ID of the
+// operation, which keeps webhooks from recording it twice. Operations are
+// recorded as pending before the gateway is called; one left pending means
+// its answer couldn't be recorded and needs reconciling with the provider.
+type PaymentTransaction struct {
+	ID        uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID  uuid.UUID   `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	PaymentID uuid.UUID   `gorm:"type:uuid;not null;index;uniqueIndex:idx_payment_transactions_reference,where:reference <> ''" json:"payment_id"`
+	Type      string      `gorm:"size:20;not null" json:"type"`
+	Amount    money.Money `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
+	Succeeded bool        `gorm:"not null" json:"succeeded"`
+	Pending   bool        `gorm:"not null;default:false" json:"pending,omitempty"`
+	Reference string      `gorm:"size:255;not null;default:'';uniqueIndex:idx_payment_transactions_reference,where:reference <> ''" json:"reference"`
+	Message   string      `gorm:"size:1000;not null;default:''" json:"message"`
+	Source    string      `gorm:"size:20;not null" json:"source"`
+	CreatedAt time.Time   `gorm:"not null" json:"created_at"`
+}
+
+// TableName specifies the table name for the PaymentTransaction model
+func (PaymentTransaction) TableName() string {
+	return "payment_transactions"
+}
+
+// PaymentWebhookEvent records a provider webhook event once it was handled,
+// so redelivered events are ignored
+type PaymentWebhookEvent struct {
+	Provider   string    `gorm:"size:32;primary_key" json:"provider"`
+	EventID    string    `gorm:"size:255;primary_key" json:"event_id"`
+	Type       string    `gorm:"size:64;not null" json:"type"`
+	ReceivedAt time.Time `gorm:"not null" json:"received_at"`
+}
+
+// TableName specifies the table name for the PaymentWebhookEvent model
+func (PaymentWebhookEvent) TableName() string {
+	return "payment_webhook_events"
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"gorm.io/gorm"
+)
+
+// Product is a read-only view of the products table owned by product-service,
+// used to price orders and check stock. It is not migrated by order-service.
+type Product struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key"`
+	TenantID    uuid.UUID `gorm:"type:uuid"`
+	SKU         string
+	Name        string
+	Price       money.Money `gorm:"embedded;embeddedPrefix:price_"`
+	Stock       int
+	TaxClass    string
+	WeightGrams int
+	DeletedAt   gorm.DeletedAt
+}
+
+// TableName specifies the table name for the Product model
+func (Product) TableName() string {
+	return "products"
+}
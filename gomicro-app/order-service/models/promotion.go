@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+)
+
+// Promotion types. Percentage promotions take Percentage off the line,
+// fixed ones take AmountOff off it and buy-X-get-Y ones make GetQuantity of
+// every BuyQuantity + GetQuantity units free.
+const (
+	PromotionPercentage = "percentage"
+	PromotionFixed      = "fixed"
+	PromotionBuyXGetY   = "buy_x_get_y"
+)
+
+// Promotion is a discount rule evaluated when an order is priced. It applies to
+// the listed products and to products in the listed categories or their
+// subcategories; with neither listed it applies to every product.
+type Promotion struct {
+	ID          uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID    uuid.UUID   `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	Name        string      `gorm:"size:255;not null" json:"name"`
+	Description string      `gorm:"size:1000;not null;default:''" json:"description"`
+	Type        string      `gorm:"size:20;not null" json:"type"`
+	Percentage  string      `gorm:"type:numeric(5,2);not null;default:0" json:"percentage,omitempty"`
+	AmountOff   money.Money `gorm:"embedded;embeddedPrefix:amount_off_" json:"amount_off"`
+	BuyQuantity int         `gorm:"not null;default:0" json:"buy_quantity,omitempty"`
+	GetQuantity int         `gorm:"not null;default:0" json:"get_quantity,omitempty"`
+	ProductIDs  []uuid.UUID `gorm:"serializer:json;type:jsonb" json:"product_ids"`
+	CategoryIDs []uuid.UUID `gorm:"serializer:json;type:jsonb" json:"category_ids"`
+	MinQuantity int         `gorm:"not null;default:0" json:"min_quantity"`
+	StartsAt    *time.Time  `json:"starts_at"`
+	EndsAt      *time.Time  `json:"ends_at"`
+
+	// UsageLimit caps how many orders can use the promotion, PerUserLimit how
+	// many orders of one user can; nil means unlimited
+	UsageLimit   *int `json:"usage_limit"`
+	PerUserLimit *int `json:"per_user_limit"`
+	UsageCount   int  `gorm:"not null;default:0" json:"usage_count"`
+
+	// Exclusive promotions don't combine with others; an order gets either the
+	// best exclusive promotion or every other one that applies, whichever saves more
+	Exclusive bool `gorm:"not null;default:false" json:"exclusive"`
+
+	// RequiresCoupon promotions only apply to orders redeeming one of their coupons
+	RequiresCoupon bool      `gorm:"not null;default:false" json:"requires_coupon"`
+	Active         bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt      time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the Promotion model
+func (Promotion) TableName() string {
+	return "promotions"
+}
+
+// Adjustment types
+const (
+	AdjustmentPromotion = "promotion"
+	AdjustmentCoupon    = "coupon"
+	AdjustmentTax       = "tax"
+	AdjustmentShipping  = "shipping"
+)
+
+// OrderAdjustment records a change to an order's subtotal made when it was
+// priced, such as a discount or tax. Discounts have negative amounts.
+type OrderAdjustment struct {
+	ID          uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID    uuid.UUID   `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	OrderID     uuid.UUID   `gorm:"type:uuid;not null;index" json:"order_id"`
+	Type        string      `gorm:"size:20;not null" json:"type"`
+	PromotionID *uuid.UUID  `gorm:"type:uuid;index" json:"promotion_id,omitempty"`
+	CouponID    *uuid.UUID  `gorm:"type:uuid;index" json:"coupon_id,omitempty"`
+	Description string      `gorm:"size:255;not null" json:"description"`
+	Amount      money.Money `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
+	CreatedAt   time.Time   `gorm:"not null" json:"created_at"`
+}
+
+// TableName specifies the table name for the OrderAdjustment model
+func (OrderAdjustment) TableName() string {
+	return "order_adjustments"
+}
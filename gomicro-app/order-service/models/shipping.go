@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+)
+
+// Shipment statuses. In-transit shipments mark their order shipped and
+// delivered ones mark it delivered.
+const (
+	ShipmentLabelCreated   = "label_created"
+	ShipmentInTransit      = "in_transit"
+	ShipmentOutForDelivery = "out_for_delivery"
+	ShipmentDelivered      = "delivered"
+	ShipmentException      = "exception"
+)
+
+// ValidShipmentStatus reports whether status is a known shipment status
+func ValidShipmentStatus(status string) bool {
+	switch status {
+	case ShipmentLabelCreated, ShipmentInTransit, ShipmentOutForDelivery, ShipmentDelivered, ShipmentException:
+		return true
+	}
+	return false
+}
+
+// ShippingMethod is a way the tenant ships orders, priced by its rate table
+type ShippingMethod struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID  uuid.UUID      `gorm:"type:uuid;not null;index;uniqueIndex:idx_shipping_methods_tenant_code" json:"tenant_id"`
+	Code      string         `gorm:"size:32;not null;uniqueIndex:idx_shipping_methods_tenant_code" json:"code"`
+	Name      string         `gorm:"size:255;not null" json:"name"`
+	Carrier   string         `gorm:"size:64;not null;default:''" json:"carrier"`
+	Active    bool           `gorm:"not null" json:"active"`
+	Rates     []ShippingRate `gorm:"foreignKey:MethodID" json:"rates"`
+	CreatedAt time.Time      `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the ShippingMethod model
+func (ShippingMethod) TableName() string {
+	return "shipping_methods"
+}
+
+// ShippingRate is one row of a shipping method's rate table. It prices parcels
+// shipped to its destination (an empty Country matches everywhere, an empty
+// Region the whole country) whose weight and order value fall in its ranges.
+// Minimums are inclusive and maximums exclusive; a nil or zero maximum is open.
+type ShippingRate struct {
+	ID             uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID       uuid.UUID   `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	MethodID       uuid.UUID   `gorm:"type:uuid;not null;index" json:"method_id"`
+	Country        string      `gorm:"size:2;not null;default:''" json:"country"`
+	Region         string      `gorm:"size:100;not null;default:''" json:"region"`
+	MinWeightGrams int         `gorm:"not null;default:0" json:"min_weight_grams"`
+	MaxWeightGrams *int        `json:"max_weight_grams"`
+	MinOrderValue  money.Money `gorm:"embedded;embeddedPrefix:min_order_value_" json:"min_order_value"`
+	MaxOrderValue  money.Money `gorm:"embedded;embeddedPrefix:max_order_value_" json:"max_order_value"`
+	Price          money.Money `gorm:"embedded;embeddedPrefix:price_" json:"price"`
+}
+
+// TableName specifies the table name for the ShippingRate model
+func (ShippingRate) TableName() string {
+	return "shipping_rates"
+}
+
+// Shipment is a parcel sent for an order
+type Shipment struct {
+	ID             uuid.UUID       `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID       uuid.UUID       `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	OrderID        uuid.UUID       `gorm:"type:uuid;not null;index" json:"order_id"`
+	MethodID       *uuid.UUID      `gorm:"type:uuid" json:"method_id,omitempty"`
+	Carrier        string          `gorm:"size:64;not null;uniqueIndex:idx_shipments_tracking" json:"carrier"`
+	TrackingNumber string          `gorm:"size:100;not null;uniqueIndex:idx_shipments_tracking" json:"tracking_number"`
+	Status         string          `gorm:"size:20;not null" json:"status"`
+	ShippedAt      *time.Time      `json:"shipped_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	Events         []ShipmentEvent `gorm:"foreignKey:ShipmentID" json:"events,omitempty"`
+	CreatedAt      time.Time       `gorm:"not null" json:"created_at"`
+	UpdatedAt      time.Time       `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the Shipment model
+func (Shipment) TableName() string {
+	return "shipments"
+}
+
+// ShipmentEvent is a status update of a shipment, from staff or the carrier
+type ShipmentEvent struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID   uuid.UUID `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	ShipmentID uuid.UUID `gorm:"type:uuid;not null;index" json:"shipment_id"`
+	Status     string    `gorm:"size:20;not null" json:"status"`
+	Location   string    `gorm:"size:255;not null;default:''" json:"location"`
+	Note       string    `gorm:"size:1000;not null;default:''" json:"note"`
+	OccurredAt time.Time `gorm:"not null" json:"occurred_at"`
+	CreatedAt  time.Time `gorm:"not null" json:"created_at"`
+}
+
+// TableName specifies the table name for the ShipmentEvent model
+func (ShipmentEvent) TableName() string {
+	return "shipment_events"
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+)
+
+// DefaultTaxClass is the tax class of products that don't name one
+const DefaultTaxClass = "standard"
+
+// TaxRate is the percentage of tax charged on products of a tax class shipped to
+// a country, or to a region of it. An empty Region covers the whole country and
+// an empty TaxClass covers every class; the most specific rate wins.
+type TaxRate struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID  uuid.UUID `gorm:"type:uuid;not null;index;uniqueIndex:idx_tax_rates_scope" json:"tenant_id"`
+	Country   string    `gorm:"size:2;not null;uniqueIndex:idx_tax_rates_scope" json:"country"`
+	Region    string    `gorm:"size:100;not null;default:'';uniqueIndex:idx_tax_rates_scope" json:"region"`
+	TaxClass  string    `gorm:"size:32;not null;default:'';uniqueIndex:idx_tax_rates_scope" json:"tax_class"`
+	Name      string    `gorm:"size:100;not null" json:"name"`
+	Rate      string    `gorm:"type:numeric(7,4);not null" json:"rate"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the TaxRate model
+func (TaxRate) TableName() string {
+	return "tax_rates"
+}
+
+// TaxSettings holds a tenant's tax configuration. Tenants without settings
+// price exclusive of tax.
+type TaxSettings struct {
+	TenantID uuid.UUID `gorm:"type:uuid;primary_key" json:"tenant_id"`
+
+	// PricesIncludeTax means product prices already contain tax, which is then
+	// shown on orders without being added to the total
+	PricesIncludeTax bool      `gorm:"not null;default:false" json:"prices_include_tax"`
+	UpdatedAt        time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the TaxSettings model
+func (TaxSettings) TableName() string {
+	return "tax_settings"
+}
+
+// OrderTaxLine is the tax charged on one line of an order. Taxable is the
+// line's amount net of tax, after discounts.
+type OrderTaxLine struct {
+	ID           uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID     uuid.UUID   `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	OrderID      uuid.UUID   `gorm:"type:uuid;not null;index" json:"order_id"`
+	ProductID    uuid.UUID   `gorm:"type:uuid;not null" json:"product_id"`
+	TaxClass     string      `gorm:"size:32;not null" json:"tax_class"`
+	Name         string      `gorm:"size:100;not null" json:"name"`
+	Jurisdiction string      `gorm:"size:100;not null" json:"jurisdiction"`
+	Rate         string      `gorm:"type:numeric(7,4);not null" json:"rate"`
+	Taxable      money.Money `gorm:"embedded;embeddedPrefix:taxable_" json:"taxable"`
+	Tax          money.Money `gorm:"embedded;embeddedPrefix:tax_" json:"tax"`
+	Inclusive    bool        `gorm:"not null" json:"inclusive"`
+	Provider     string      `gorm:"size:32;not null" json:"provider"`
+	CreatedAt    time.Time   `gorm:"not null" json:"created_at"`
+}
+
+// TableName specifies the table name for the OrderTaxLine model
+func (OrderTaxLine) TableName() string {
+	return "order_tax_lines"
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"gorm.io/gorm"
+)
+
+// Variant is a read-only view of the variants table owned by product-service,
+// used to price and stock-check orders placed by SKU
+type Variant struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key"`
+	TenantID      uuid.UUID `gorm:"type:uuid"`
+	ProductID     uuid.UUID `gorm:"type:uuid"`
+	SKU           string
+	PriceOverride *money.Amount
+	Stock         int
+	DeletedAt     gorm.DeletedAt
+}
+
+// TableName specifies the table name for the Variant model
+func (Variant) TableName() string {
+	return "variants"
+}
@@ -0,0 +1,120 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+)
+
+// SignatureHeader is the header carrying the fake gateway's webhook signature
+const SignatureHeader = "X-Fake-Signature"
+
+// Fake stands in for a payment provider in development and tests. It answers
+// deterministically: authorizations from the sources "tok_declined" and
+// "tok_insufficient_funds" are declined and all others approved, and
+// references are derived from the request keys. Webhooks are signed with an
+// HMAC-SHA256 of the body keyed with FAKE_PAYMENT_WEBHOOK_SECRET.
+type Fake struct {
+	Secret string
+}
+
+// newFake builds the fake gateway from FAKE_PAYMENT_WEBHOOK_SECRET, which
+// must be set so webhooks can't be forged with a well-known key
+func newFake() (Gateway, error) {
+	secret := os.Getenv("FAKE_PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		return nil, errors.New("FAKE_PAYMENT_WEBHOOK_SECRET is not set")
+	}
+	return Fake{Secret: secret}, nil
+}
+
+// Authorize implements Gateway
+func (f Fake) Authorize(ctx context.Context, req Request) (Result, error) {
+	result := Result{PaymentReference: reference("fake_pay_", req.Key), Reference: reference("fake_txn_", req.Key)}
+	switch req.Source {
+	case "":
+		result.Declined, result.Message = true, "payment source is required"
+	case "tok_declined":
+		result.Declined, result.Message = true, "card declined"
+	case "tok_insufficient_funds":
+		result.Declined, result.Message = true, "insufficient funds"
+	default:
+		result.Message = "approved"
+	}
+	return result, nil
+}
+
+// Capture implements Gateway
+func (f Fake) Capture(ctx context.Context, req Request) (Result, error) {
+	return f.operate(req), nil
+}
+
+// Void implements Gateway
+func (f Fake) Void(ctx context.Context, req Request) (Result, error) {
+	return f.operate(req), nil
+}
+
+// Refund implements Gateway
+func (f Fake) Refund(ctx context.Context, req Request) (Result, error) {
+	return f.operate(req), nil
+}
+
+// ParseWebhook implements Gateway. The body is a JSON event such as
+// {"id":"evt_1","type":"payment.captured","payment_reference":"fake_pay_...",
+// "reference":"fake_txn_...","amount":{"amount":"10.00","currency":"USD"}}.
+func (f Fake) ParseWebhook(header http.Header, body []byte) (*Event, error) {
+	signature, err := hex.DecodeString(header.Get(SignatureHeader))
+	if err != nil || !hmac.Equal(signature, f.sign(body)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var payload struct {
+		ID               string      `json:"id"`
+		Type             string      `json:"type"`
+		PaymentReference string      `json:"payment_reference"`
+		Reference        string      `json:"reference"`
+		Amount           money.Money `json:"amount"`
+		Message          string      `json:"message"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	event := Event(payload)
+	return &event, nil
+}
+
+// Sign returns the signature header value for a webhook body, for tools that
+// simulate the provider
+func (f Fake) Sign(body []byte) string {
+	return hex.EncodeToString(f.sign(body))
+}
+
+func (f Fake) sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(f.Secret))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// operate approves operations on payments the fake authorized
+func (f Fake) operate(req Request) Result {
+	result := Result{Reference: reference("fake_txn_", req.Key), Message: "approved"}
+	if !strings.HasPrefix(req.Reference, "fake_pay_") {
+		result.Declined, result.Message = true, "unknown payment"
+	}
+	return result
+}
+
+// reference derives a gateway reference from a request key
+func reference(prefix string, key uuid.UUID) string {
+	sum := sha256.Sum256([]byte(prefix + key.String()))
+	return prefix + hex.EncodeToString(sum[:12])
+}
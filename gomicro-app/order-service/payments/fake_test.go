@@ -0,0 +1,108 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFakeParseWebhook(t *testing.T) {
+	fake := Fake{Secret: "whsec_test"}
+	body := []byte(`{"id":"evt_1","type":"payment.captured","payment_reference":"fake_pay_1","reference":"fake_txn_1","amount":{"amount":"10.00","currency":"USD"}}`)
+
+	tests := []struct {
+		name      string
+		signature string
+		body      []byte
+		wantErr   error
+	}{
+		{name: "signed", signature: fake.Sign(body), body: body},
+		{name: "unsigned", signature: "", body: body, wantErr: ErrInvalidSignature},
+		{name: "not hex", signature: "not-a-signature", body: body, wantErr: ErrInvalidSignature},
+		{name: "other secret", signature: Fake{Secret: "other"}.Sign(body), body: body, wantErr: ErrInvalidSignature},
+		{name: "tampered body", signature: fake.Sign(body), body: []byte(strings.Replace(string(body), "10.00", "99.00", 1)), wantErr: ErrInvalidSignature},
+		{name: "truncated signature", signature: fake.Sign(body)[:32], body: body, wantErr: ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.signature != "" {
+			header.Set(SignatureHeader, tt.signature)
+		}
+		event, err := fake.ParseWebhook(header, tt.body)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: ParseWebhook error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ParseWebhook error = %v", tt.name, err)
+			continue
+		}
+		if event.ID != "evt_1" || event.Type != EventCaptured || event.PaymentReference != "fake_pay_1" ||
+			event.Reference != "fake_txn_1" || event.Amount.String() != "10.00 USD" {
+			t.Errorf("%s: ParseWebhook = %+v", tt.name, event)
+		}
+	}
+
+	// A signed body that isn't an event is still rejected, but not as forged
+	invalid := []byte("not json")
+	header := http.Header{SignatureHeader: {fake.Sign(invalid)}}
+	if _, err := fake.ParseWebhook(header, invalid); err == nil || errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("ParseWebhook of a signed invalid body error = %v, want a decoding error", err)
+	}
+}
+
+func TestFakeAuthorize(t *testing.T) {
+	tests := []struct {
+		source       string
+		wantDeclined bool
+	}{
+		{source: "tok_visa"},
+		{source: "", wantDeclined: true},
+		{source: "tok_declined", wantDeclined: true},
+		{source: "tok_insufficient_funds", wantDeclined: true},
+	}
+	for _, tt := range tests {
+		key := uuid.New()
+		result, err := Fake{}.Authorize(context.Background(), Request{Key: key, Source: tt.source})
+		if err != nil {
+			t.Errorf("Authorize(%q) error = %v", tt.source, err)
+			continue
+		}
+		if result.Declined != tt.wantDeclined {
+			t.Errorf("Authorize(%q) declined = %v, want %v", tt.source, result.Declined, tt.wantDeclined)
+		}
+		if again, _ := (Fake{}).Authorize(context.Background(), Request{Key: key, Source: tt.source}); again != result {
+			t.Errorf("Authorize(%q) retried = %+v, want %+v", tt.source, again, result)
+		}
+	}
+}
+
+func TestFakeOperate(t *testing.T) {
+	authorized, err := Fake{}.Authorize(context.Background(), Request{Key: uuid.New(), Source: "tok_visa"})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if result, err := (Fake{}).Capture(context.Background(), Request{Key: uuid.New(), Reference: authorized.PaymentReference}); err != nil || result.Declined {
+		t.Errorf("Capture of an authorized payment = %+v, %v; want approved", result, err)
+	}
+	if result, err := (Fake{}).Refund(context.Background(), Request{Key: uuid.New(), Reference: "pay_elsewhere"}); err != nil || !result.Declined {
+		t.Errorf("Refund of an unknown payment = %+v, %v; want declined", result, err)
+	}
+}
+
+func TestNewFake(t *testing.T) {
+	t.Setenv("FAKE_PAYMENT_WEBHOOK_SECRET", "")
+	if _, err := newFake(); err == nil {
+		t.Error("newFake without FAKE_PAYMENT_WEBHOOK_SECRET succeeded, want an error")
+	}
+	t.Setenv("FAKE_PAYMENT_WEBHOOK_SECRET", "whsec_test")
+	if gateway, err := newFake(); err != nil || gateway.(Fake).Secret != "whsec_test" {
+		t.Errorf("newFake = %+v, %v; want the secret from the environment", gateway, err)
+	}
+}
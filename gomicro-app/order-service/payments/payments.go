@@ -0,0 +1,491 @@
+// Package payments takes payment for orders through a payment gateway. Gateways
+// are registered by name and selected with PAYMENT_PROVIDER; the "fake" gateway
+// runs in-process for development, and real providers plug in with Register.
+// Without PAYMENT_PROVIDER payments are disabled.
+package payments
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Webhook event types
+const (
+	EventAuthorized = "payment.authorized"
+	EventCaptured   = "payment.captured"
+	EventVoided     = "payment.voided"
+	EventRefunded   = "payment.refunded"
+	EventFailed     = "payment.failed"
+)
+
+var (
+	// ErrInvalidSignature is returned for webhooks whose signature doesn't verify
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+
+	// ErrInvalidState is returned for operations the payment's status doesn't allow
+	ErrInvalidState = errors.New("payment does not allow this operation")
+
+	// ErrInvalidAmount is returned for amounts that are not positive or exceed
+	// what is left to capture or refund
+	ErrInvalidAmount = errors.New("invalid payment amount")
+
+	// ErrBusy is returned while another operation on the payment awaits the gateway
+	ErrBusy = errors.New("payment has an operation in progress")
+)
+
+// PendingTimeout is how long an operation sent to the gateway blocks others on
+// its payment while its answer isn't recorded
+const PendingTimeout = 5 * time.Minute
+
+// Request asks the gateway to operate on a payment. Key identifies the
+// operation, so gateways can make retries idempotent.
+type Request struct {
+	Key       uuid.UUID
+	Reference string
+	Amount    money.Money
+
+	// Source is the tokenized payment method, only used to authorize
+	Source string
+}
+
+// Result is the gateway's answer. Declined operations are not errors; errors
+// mean the gateway couldn't be asked.
+type Result struct {
+	// PaymentReference is the gateway's ID of the payment, set by Authorize
+	PaymentReference string
+	// Reference is the gateway's ID of the operation
+	Reference string
+	Declined  bool
+	Message   string
+}
+
+// Event is a webhook event from the gateway
+type Event struct {
+	ID               string
+	Type             string
+	PaymentReference string
+	Reference        string
+	Amount           money.Money
+	Message          string
+}
+
+// Gateway takes payments with a payment provider
+type Gateway interface {
+	Authorize(ctx context.Context, req Request) (Result, error)
+	Capture(ctx context.Context, req Request) (Result, error)
+	Void(ctx context.Context, req Request) (Result, error)
+	Refund(ctx context.Context, req Request) (Result, error)
+
+	// ParseWebhook verifies the signature of a webhook request and returns its event
+	ParseWebhook(header http.Header, body []byte) (*Event, error)
+}
+
+// Factory builds a gateway from the environment
+type Factory func() (Gateway, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{
+		"fake": newFake,
+	}
+)
+
+// Default is the gateway built by Init, and Provider its name. Default is nil
+// while payments are disabled.
+var (
+	Default  Gateway
+	Provider string
+)
+
+// Register makes a gateway available under name for PAYMENT_PROVIDER
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = factory
+}
+
+// Init builds Default from PAYMENT_PROVIDER. Payments stay disabled when it
+// is not set, so no gateway is picked by accident.
+func Init() {
+	name := os.Getenv("PAYMENT_PROVIDER")
+	if name == "" {
+		log.Println("PAYMENT_PROVIDER is not set; payments are disabled")
+		return
+	}
+
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		log.Fatalf("Unknown payment provider %q in PAYMENT_PROVIDER", name)
+	}
+	gateway, err := factory()
+	if err != nil {
+		log.Fatalf("Failed to initialize %s payment provider: %v", name, err)
+	}
+	Default, Provider = gateway, name
+	log.Printf("Payment provider initialized: %s", name)
+}
+
+// Enabled reports whether a gateway was configured
+func Enabled() bool {
+	return Default != nil
+}
+
+// Authorize pays the order's total from source and records the payment and
+// its authorization, declined or not, capturing it straight away if capture is
+// set. check runs with the order locked, in the transaction that creates the
+// payment, and decides whether the order can be paid. The gateway is only
+// called once that transaction has committed.
+func Authorize(ctx context.Context, db *gorm.DB, orderID uuid.UUID, source string, capture bool, check func(*gorm.DB, *models.Order) error) (*models.Payment, error) {
+	payment := &models.Payment{ID: uuid.New(), OrderID: orderID, Provider: Provider, Status: models.PaymentPending}
+	var pending *models.PaymentTransaction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, "id = ?", orderID).Error; err != nil {
+			return err
+		}
+		if err := check(tx, &order); err != nil {
+			return err
+		}
+		if !order.TotalPrice.IsPositive() {
+			return ErrInvalidAmount
+		}
+		payment.Amount = order.TotalPrice
+		payment.Captured = money.Zero(order.TotalPrice.Currency)
+		payment.Refunded = money.Zero(order.TotalPrice.Currency)
+		if err := tx.Create(payment).Error; err != nil {
+			return err
+		}
+		var err error
+		pending, err = begin(tx, payment, models.TransactionAuthorize, payment.Amount)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, callErr := Default.Authorize(ctx, Request{Key: pending.ID, Amount: payment.Amount, Source: source})
+	if err := finish(ctx, db, payment, pending, result, callErr); err != nil {
+		return nil, err
+	}
+	if capture && payment.Status == models.PaymentAuthorized {
+		return payment, Capture(ctx, db, payment, nil)
+	}
+	return payment, nil
+}
+
+// Capture captures amount of the authorized payment, or all that is left to
+// capture when amount is nil
+func Capture(ctx context.Context, db *gorm.DB, payment *models.Payment, amount *money.Money) error {
+	return operate(ctx, db, payment, models.TransactionCapture, func(payment *models.Payment) (money.Money, error) {
+		return captureAmount(payment, amount)
+	}, Default.Capture)
+}
+
+// Void cancels the authorization of a payment nothing was captured of
+func Void(ctx context.Context, db *gorm.DB, payment *models.Payment) error {
+	return operate(ctx, db, payment, models.TransactionVoid, voidAmount, Default.Void)
+}
+
+// Refund refunds amount of what was captured, or all of what is left to
+// refund when amount is nil
+func Refund(ctx context.Context, db *gorm.DB, payment *models.Payment, amount *money.Money) error {
+	return operate(ctx, db, payment, models.TransactionRefund, func(payment *models.Payment) (money.Money, error) {
+		return refundAmount(payment, amount)
+	}, Default.Refund)
+}
+
+// captureAmount checks the payment can be captured and returns the amount to
+// capture: amount, or all that is left when amount is nil
+func captureAmount(payment *models.Payment, amount *money.Money) (money.Money, error) {
+	if payment.Status != models.PaymentAuthorized && payment.Status != models.PaymentCaptured {
+		return money.Money{}, ErrInvalidState
+	}
+	left, err := payment.Amount.Sub(payment.Captured)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return orLeft(amount, left)
+}
+
+// voidAmount checks the payment can be voided and returns its amount
+func voidAmount(payment *models.Payment) (money.Money, error) {
+	if payment.Status != models.PaymentAuthorized {
+		return money.Money{}, ErrInvalidState
+	}
+	return payment.Amount, nil
+}
+
+// refundAmount checks the payment can be refunded and returns the amount to
+// refund: amount, or all that is left when amount is nil
+func refundAmount(payment *models.Payment, amount *money.Money) (money.Money, error) {
+	if payment.Status != models.PaymentCaptured && payment.Status != models.PaymentPartiallyRefunded {
+		return money.Money{}, ErrInvalidState
+	}
+	left, err := payment.Captured.Sub(payment.Refunded)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return orLeft(amount, left)
+}
+
+// eventTransactions maps webhook event types to the operation they report.
+// Only authorizations fail after the gateway answered, as with 3-D Secure.
+var eventTransactions = map[string]string{
+	EventAuthorized: models.TransactionAuthorize,
+	EventCaptured:   models.TransactionCapture,
+	EventVoided:     models.TransactionVoid,
+	EventRefunded:   models.TransactionRefund,
+	EventFailed:     models.TransactionAuthorize,
+}
+
+// ApplyEvent records what a webhook event reports about the payment, which tx
+// must have locked. Events about operations already recorded change nothing.
+// Captures beyond the authorized amount and refunds beyond what was captured
+// are rejected with ErrInvalidAmount.
+func ApplyEvent(tx *gorm.DB, payment *models.Payment, event *Event) error {
+	kind, ok := eventTransactions[event.Type]
+	if !ok {
+		return nil
+	}
+	if event.Reference != "" {
+		var known int64
+		err := tx.Model(&models.PaymentTransaction{}).
+			Where("payment_id = ? AND reference = ?", payment.ID, event.Reference).
+			Count(&known).Error
+		if err != nil || known > 0 {
+			return err
+		}
+	}
+
+	if err := checkEventAmount(payment, kind, event.Amount); err != nil {
+		return err
+	}
+
+	failed := event.Type == EventFailed
+	result := Result{Reference: event.Reference, Declined: failed, Message: event.Message}
+	if err := record(tx, payment, uuid.New(), kind, event.Amount, result, "webhook"); err != nil {
+		return err
+	}
+	if failed {
+		if payment.Status != models.PaymentAuthorized || !payment.Captured.IsZero() {
+			return nil
+		}
+		payment.Status = models.PaymentFailed
+	} else if err := apply(payment, kind, event.Amount); err != nil {
+		return err
+	}
+	return tx.Omit("Transactions").Save(payment).Error
+}
+
+// operate runs an operation on the payment from the ID it has. It locks the
+// payment, has prepare check the operation and work out its amount, and
+// records it as pending; then, with no transaction open, it calls the gateway
+// and records the answer.
+func operate(ctx context.Context, db *gorm.DB, payment *models.Payment, kind string, prepare func(*models.Payment) (money.Money, error), call func(context.Context, Request) (Result, error)) error {
+	var pending *models.PaymentTransaction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(payment, "id = ?", payment.ID).Error; err != nil {
+			return err
+		}
+		amount, err := prepare(payment)
+		if err != nil {
+			return err
+		}
+		pending, err = begin(tx, payment, kind, amount)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	result, callErr := call(ctx, Request{Key: pending.ID, Reference: payment.Reference, Amount: pending.Amount})
+	return finish(ctx, db, payment, pending, result, callErr)
+}
+
+// begin records an operation on the payment, which tx must have locked, as
+// pending. Its ID is the request key sent to the gateway. Only one operation
+// on a payment waits for the gateway at a time; one pending for longer than
+// PendingTimeout is presumed lost and no longer blocks others.
+func begin(tx *gorm.DB, payment *models.Payment, kind string, amount money.Money) (*models.PaymentTransaction, error) {
+	var busy int64
+	err := tx.Model(&models.PaymentTransaction{}).
+		Where("payment_id = ? AND pending AND created_at > ?", payment.ID, time.Now().Add(-PendingTimeout)).
+		Count(&busy).Error
+	if err != nil {
+		return nil, err
+	}
+	if busy > 0 {
+		return nil, ErrBusy
+	}
+
+	transaction := &models.PaymentTransaction{
+		ID:        uuid.New(),
+		PaymentID: payment.ID,
+		Type:      kind,
+		Amount:    amount,
+		Pending:   true,
+		Source:    "api",
+	}
+	if err := tx.Create(transaction).Error; err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// finish records the gateway's answer to the pending operation and, unless it
+// was declined, applies it to the payment. A gateway error counts as declined
+// and is returned. If the answer can't be recorded, the operation stays
+// pending for reconciliation, and an approved authorization is voided so the
+// customer isn't left with a hold nobody knows about.
+func finish(ctx context.Context, db *gorm.DB, payment *models.Payment, pending *models.PaymentTransaction, result Result, callErr error) error {
+	if callErr != nil {
+		result = Result{Declined: true, Message: callErr.Error()}
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(payment, "id = ?", payment.ID).Error; err != nil {
+			return err
+		}
+
+		// The provider's webhook may have reported the operation already
+		if result.Reference != "" {
+			var known int64
+			err := tx.Model(&models.PaymentTransaction{}).
+				Where("payment_id = ? AND reference = ?", payment.ID, result.Reference).
+				Count(&known).Error
+			if err != nil {
+				return err
+			}
+			if known > 0 {
+				return tx.Delete(pending).Error
+			}
+		}
+
+		pending.Pending = false
+		pending.Succeeded = !result.Declined
+		pending.Reference = result.Reference
+		pending.Message = result.Message
+		if err := tx.Save(pending).Error; err != nil {
+			return err
+		}
+
+		if pending.Type == models.TransactionAuthorize {
+			payment.Reference = result.PaymentReference
+			payment.Status = models.PaymentFailed
+		}
+		if !result.Declined {
+			if err := apply(payment, pending.Type, pending.Amount); err != nil {
+				return err
+			}
+		}
+		return tx.Omit("Transactions").Save(payment).Error
+	})
+	if err != nil {
+		log.Printf("Failed to record %s %s of payment %s (gateway reference %q), left pending for reconciliation: %v", pending.Type, pending.ID, payment.ID, result.Reference, err)
+		if pending.Type == models.TransactionAuthorize && !result.Declined {
+			void, err := Default.Void(ctx, Request{Key: uuid.New(), Reference: result.PaymentReference, Amount: pending.Amount})
+			if err == nil && void.Declined {
+				err = errors.New(void.Message)
+			}
+			if err != nil {
+				log.Printf("Failed to void unrecorded authorization %q of payment %s: %v", result.PaymentReference, payment.ID, err)
+			}
+		}
+		return err
+	}
+	return callErr
+}
+
+// apply updates the payment's totals and status for a successful operation
+func apply(payment *models.Payment, kind string, amount money.Money) error {
+	var err error
+	switch kind {
+	case models.TransactionAuthorize:
+		payment.Status = models.PaymentAuthorized
+	case models.TransactionCapture:
+		payment.Captured, err = payment.Captured.Add(amount)
+		payment.Status = models.PaymentCaptured
+	case models.TransactionVoid:
+		payment.Status = models.PaymentVoided
+	case models.TransactionRefund:
+		if payment.Refunded, err = payment.Refunded.Add(amount); err != nil {
+			return err
+		}
+		payment.Status = models.PaymentPartiallyRefunded
+		if cmp, err := payment.Refunded.Cmp(payment.Captured); err == nil && cmp >= 0 {
+			payment.Status = models.PaymentRefunded
+		}
+	}
+	return err
+}
+
+// record adds the operation to the payment's transactions
+func record(tx *gorm.DB, payment *models.Payment, key uuid.UUID, kind string, amount money.Money, result Result, source string) error {
+	transaction := models.PaymentTransaction{
+		ID:        key,
+		PaymentID: payment.ID,
+		Type:      kind,
+		Amount:    amount,
+		Succeeded: !result.Declined,
+		Reference: result.Reference,
+		Message:   result.Message,
+		Source:    source,
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return err
+	}
+	payment.Transactions = append(payment.Transactions, transaction)
+	return nil
+}
+
+// checkEventAmount makes sure a webhook's capture or refund fits what is left
+// of the payment to capture or refund
+func checkEventAmount(payment *models.Payment, kind string, amount money.Money) error {
+	var left money.Money
+	var err error
+	switch kind {
+	case models.TransactionCapture:
+		left, err = payment.Amount.Sub(payment.Captured)
+	case models.TransactionRefund:
+		left, err = payment.Captured.Sub(payment.Refunded)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return checkAmount(amount, left)
+}
+
+// orLeft returns amount if it is positive and at most left, or left when
+// amount is nil
+func orLeft(amount *money.Money, left money.Money) (money.Money, error) {
+	if amount == nil {
+		amount = &left
+	}
+	return *amount, checkAmount(*amount, left)
+}
+
+// checkAmount makes sure amount is positive and at most limit
+func checkAmount(amount, limit money.Money) error {
+	if !amount.IsPositive() {
+		return ErrInvalidAmount
+	}
+	cmp, err := amount.Cmp(limit)
+	if err != nil || cmp > 0 {
+		return ErrInvalidAmount
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package payments
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+)
+
+func usd(t *testing.T, amount string) money.Money {
+	t.Helper()
+	m, err := money.New(amount, "USD")
+	if err != nil {
+		t.Fatalf("money.New(%q): %v", amount, err)
+	}
+	return m
+}
+
+// payment returns a payment of 100 USD with captured and refunded so far
+func payment(t *testing.T, status, captured, refunded string) *models.Payment {
+	t.Helper()
+	return &models.Payment{
+		ID:        uuid.New(),
+		Status:    status,
+		Amount:    usd(t, "100"),
+		Captured:  usd(t, captured),
+		Refunded:  usd(t, refunded),
+		Reference: "fake_pay_test",
+	}
+}
+
+func dryRun(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return db
+}
+
+func TestCaptureAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		captured string
+		amount   string
+		want     string
+		wantErr  error
+	}{
+		{name: "all", status: models.PaymentAuthorized, captured: "0", want: "100.00 USD"},
+		{name: "part", status: models.PaymentAuthorized, captured: "0", amount: "40", want: "40.00 USD"},
+		{name: "rest", status: models.PaymentCaptured, captured: "40", want: "60.00 USD"},
+		{name: "exactly the rest", status: models.PaymentCaptured, captured: "40", amount: "60", want: "60.00 USD"},
+		{name: "over capture", status: models.PaymentCaptured, captured: "40", amount: "60.01", wantErr: ErrInvalidAmount},
+		{name: "zero", status: models.PaymentAuthorized, captured: "0", amount: "0", wantErr: ErrInvalidAmount},
+		{name: "negative", status: models.PaymentAuthorized, captured: "0", amount: "-1", wantErr: ErrInvalidAmount},
+		{name: "nothing left", status: models.PaymentCaptured, captured: "100", wantErr: ErrInvalidAmount},
+		{name: "pending", status: models.PaymentPending, captured: "0", wantErr: ErrInvalidState},
+		{name: "voided", status: models.PaymentVoided, captured: "0", wantErr: ErrInvalidState},
+		{name: "refunded", status: models.PaymentPartiallyRefunded, captured: "100", amount: "1", wantErr: ErrInvalidState},
+	}
+	for _, tt := range tests {
+		var amount *money.Money
+		if tt.amount != "" {
+			m := usd(t, tt.amount)
+			amount = &m
+		}
+		got, err := captureAmount(payment(t, tt.status, tt.captured, "0"), amount)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: captureAmount error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: captureAmount error = %v", tt.name, err)
+		} else if got.String() != tt.want {
+			t.Errorf("%s: captureAmount = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	eur, err := money.New("10", "EUR")
+	if err != nil {
+		t.Fatalf("money.New: %v", err)
+	}
+	if _, err := captureAmount(payment(t, models.PaymentAuthorized, "0", "0"), &eur); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("captureAmount in another currency error = %v, want %v", err, ErrInvalidAmount)
+	}
+}
+
+func TestVoidAmount(t *testing.T) {
+	if got, err := voidAmount(payment(t, models.PaymentAuthorized, "0", "0")); err != nil || got.String() != "100.00 USD" {
+		t.Errorf("voidAmount(authorized) = %s, %v; want 100.00 USD", got, err)
+	}
+	for _, status := range []string{models.PaymentPending, models.PaymentCaptured, models.PaymentVoided, models.PaymentFailed} {
+		if _, err := voidAmount(payment(t, status, "0", "0")); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("voidAmount(%s) error = %v, want %v", status, err, ErrInvalidState)
+		}
+	}
+}
+
+func TestRefundAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		captured string
+		refunded string
+		amount   string
+		want     string
+		wantErr  error
+	}{
+		{name: "all", status: models.PaymentCaptured, captured: "80", refunded: "0", want: "80.00 USD"},
+		{name: "part", status: models.PaymentCaptured, captured: "80", refunded: "0", amount: "30", want: "30.00 USD"},
+		{name: "rest", status: models.PaymentPartiallyRefunded, captured: "80", refunded: "30", want: "50.00 USD"},
+		{name: "beyond captured", status: models.PaymentPartiallyRefunded, captured: "80", refunded: "30", amount: "50.01", wantErr: ErrInvalidAmount},
+		{name: "beyond authorized", status: models.PaymentCaptured, captured: "80", refunded: "0", amount: "100", wantErr: ErrInvalidAmount},
+		{name: "zero", status: models.PaymentCaptured, captured: "80", refunded: "0", amount: "0", wantErr: ErrInvalidAmount},
+		{name: "authorized", status: models.PaymentAuthorized, captured: "0", refunded: "0", wantErr: ErrInvalidState},
+		{name: "fully refunded", status: models.PaymentRefunded, captured: "80", refunded: "80", wantErr: ErrInvalidState},
+	}
+	for _, tt := range tests {
+		var amount *money.Money
+		if tt.amount != "" {
+			m := usd(t, tt.amount)
+			amount = &m
+		}
+		got, err := refundAmount(payment(t, tt.status, tt.captured, tt.refunded), amount)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: refundAmount error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: refundAmount error = %v", tt.name, err)
+		} else if got.String() != tt.want {
+			t.Errorf("%s: refundAmount = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       string
+		captured     string
+		refunded     string
+		kind         string
+		amount       string
+		wantStatus   string
+		wantCaptured string
+		wantRefunded string
+	}{
+		{"authorize", models.PaymentPending, "0", "0", models.TransactionAuthorize, "100", models.PaymentAuthorized, "0.00 USD", "0.00 USD"},
+		{"partial capture", models.PaymentAuthorized, "0", "0", models.TransactionCapture, "40", models.PaymentCaptured, "40.00 USD", "0.00 USD"},
+		{"second capture", models.PaymentCaptured, "40", "0", models.TransactionCapture, "60", models.PaymentCaptured, "100.00 USD", "0.00 USD"},
+		{"void", models.PaymentAuthorized, "0", "0", models.TransactionVoid, "100", models.PaymentVoided, "0.00 USD", "0.00 USD"},
+		{"partial refund", models.PaymentCaptured, "100", "0", models.TransactionRefund, "25", models.PaymentPartiallyRefunded, "100.00 USD", "25.00 USD"},
+		{"full refund", models.PaymentPartiallyRefunded, "100", "25", models.TransactionRefund, "75", models.PaymentRefunded, "100.00 USD", "100.00 USD"},
+		{"refund of a partial capture", models.PaymentCaptured, "40", "0", models.TransactionRefund, "40", models.PaymentRefunded, "40.00 USD", "40.00 USD"},
+	}
+	for _, tt := range tests {
+		p := payment(t, tt.status, tt.captured, tt.refunded)
+		if err := apply(p, tt.kind, usd(t, tt.amount)); err != nil {
+			t.Errorf("%s: apply error = %v", tt.name, err)
+			continue
+		}
+		if p.Status != tt.wantStatus || p.Captured.String() != tt.wantCaptured || p.Refunded.String() != tt.wantRefunded {
+			t.Errorf("%s: apply = %s, captured %s, refunded %s; want %s, %s, %s", tt.name,
+				p.Status, p.Captured, p.Refunded, tt.wantStatus, tt.wantCaptured, tt.wantRefunded)
+		}
+	}
+}
+
+func TestApplyEvent(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       string
+		captured     string
+		refunded     string
+		event        string
+		amount       string
+		wantStatus   string
+		wantCaptured string
+		wantRefunded string
+		wantErr      error
+	}{
+		{name: "authorized", status: models.PaymentPending, captured: "0", refunded: "0", event: EventAuthorized, amount: "100",
+			wantStatus: models.PaymentAuthorized, wantCaptured: "0.00 USD", wantRefunded: "0.00 USD"},
+		{name: "captured", status: models.PaymentAuthorized, captured: "0", refunded: "0", event: EventCaptured, amount: "100",
+			wantStatus: models.PaymentCaptured, wantCaptured: "100.00 USD", wantRefunded: "0.00 USD"},
+		{name: "over capture", status: models.PaymentCaptured, captured: "60", refunded: "0", event: EventCaptured, amount: "40.01",
+			wantErr: ErrInvalidAmount},
+		{name: "capture in another currency", status: models.PaymentAuthorized, captured: "0", refunded: "0", event: EventCaptured,
+			wantErr: ErrInvalidAmount},
+		{name: "refunded", status: models.PaymentCaptured, captured: "100", refunded: "0", event: EventRefunded, amount: "30",
+			wantStatus: models.PaymentPartiallyRefunded, wantCaptured: "100.00 USD", wantRefunded: "30.00 USD"},
+		{name: "refunded in full", status: models.PaymentPartiallyRefunded, captured: "100", refunded: "30", event: EventRefunded, amount: "70",
+			wantStatus: models.PaymentRefunded, wantCaptured: "100.00 USD", wantRefunded: "100.00 USD"},
+		{name: "refund beyond captured", status: models.PaymentCaptured, captured: "50", refunded: "0", event: EventRefunded, amount: "50.01",
+			wantErr: ErrInvalidAmount},
+		{name: "zero refund", status: models.PaymentCaptured, captured: "50", refunded: "0", event: EventRefunded, amount: "0",
+			wantErr: ErrInvalidAmount},
+		{name: "voided", status: models.PaymentAuthorized, captured: "0", refunded: "0", event: EventVoided, amount: "100",
+			wantStatus: models.PaymentVoided, wantCaptured: "0.00 USD", wantRefunded: "0.00 USD"},
+		{name: "failed authorization", status: models.PaymentAuthorized, captured: "0", refunded: "0", event: EventFailed, amount: "100",
+			wantStatus: models.PaymentFailed, wantCaptured: "0.00 USD", wantRefunded: "0.00 USD"},
+		{name: "failure after capture", status: models.PaymentCaptured, captured: "100", refunded: "0", event: EventFailed, amount: "100",
+			wantStatus: models.PaymentCaptured, wantCaptured: "100.00 USD", wantRefunded: "0.00 USD"},
+		{name: "unknown event", status: models.PaymentAuthorized, captured: "0", refunded: "0", event: "payment.disputed", amount: "100",
+			wantStatus: models.PaymentAuthorized, wantCaptured: "0.00 USD", wantRefunded: "0.00 USD"},
+	}
+	db := dryRun(t)
+	for _, tt := range tests {
+		p := payment(t, tt.status, tt.captured, tt.refunded)
+		amount := money.Zero("EUR")
+		if tt.amount != "" {
+			amount = usd(t, tt.amount)
+		}
+		event := &Event{ID: "evt_" + tt.name, Type: tt.event, PaymentReference: p.Reference, Reference: "fake_txn_" + tt.name, Amount: amount}
+		err := ApplyEvent(db, p, event)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: ApplyEvent error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			if len(p.Transactions) != 0 {
+				t.Errorf("%s: ApplyEvent recorded a rejected event", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ApplyEvent error = %v", tt.name, err)
+			continue
+		}
+		if p.Status != tt.wantStatus || p.Captured.String() != tt.wantCaptured || p.Refunded.String() != tt.wantRefunded {
+			t.Errorf("%s: ApplyEvent = %s, captured %s, refunded %s; want %s, %s, %s", tt.name,
+				p.Status, p.Captured, p.Refunded, tt.wantStatus, tt.wantCaptured, tt.wantRefunded)
+		}
+	}
+}
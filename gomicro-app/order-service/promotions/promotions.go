@@ -0,0 +1,300 @@
+// Package promotions is the rules engine that decides which of a tenant's
+// promotions discount an order, and by how much
+package promotions
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"gorm.io/gorm"
+)
+
+// ErrUnavailable is returned when a promotion reached its usage limit while
+// the order was being priced; pricing the order again picks the next best offer
+var ErrUnavailable = errors.New("promotion is no longer available")
+
+// Line is the order line promotions are evaluated against. Prices are in the
+// order's currency.
+type Line struct {
+	UserID    uuid.UUID
+	ProductID uuid.UUID
+	Quantity  int
+	UnitPrice money.Money
+	Subtotal  money.Money
+
+	// CategoryIDs holds the product's categories and all of their ancestors
+	CategoryIDs map[uuid.UUID]bool
+
+	// CouponPromotionID is the promotion unlocked by the coupon the order
+	// redeems, or uuid.Nil
+	CouponPromotionID uuid.UUID
+}
+
+// Discount is the amount a promotion takes off a line
+type Discount struct {
+	Promotion models.Promotion
+	Amount    money.Money
+}
+
+// Converter converts an amount into the order's currency
+type Converter func(amount money.Money) (money.Money, error)
+
+// Apply evaluates the tenant's promotions against the line in tx and counts a
+// use of each one applied. Call it in the transaction that creates the order.
+func Apply(tx *gorm.DB, line Line, convert Converter, now time.Time) ([]Discount, error) {
+	var candidates []models.Promotion
+	err := tx.Where("active AND (starts_at IS NULL OR starts_at <= ?) AND (ends_at IS NULL OR ends_at > ?)", now, now).
+		Where("NOT requires_coupon OR id = ?", line.CouponPromotionID).
+		Order("created_at, id").Find(&candidates).Error
+	if err != nil || len(candidates) == 0 {
+		return nil, err
+	}
+
+	if line.CategoryIDs == nil {
+		if line.CategoryIDs, err = categoryIDs(tx, line.ProductID); err != nil {
+			return nil, err
+		}
+	}
+	if candidates, err = withinUserLimits(tx, candidates, line.UserID); err != nil {
+		return nil, err
+	}
+
+	discounts, err := Evaluate(candidates, line, convert, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, discount := range discounts {
+		result := tx.Model(&models.Promotion{}).
+			Where("id = ? AND (usage_limit IS NULL OR usage_count < usage_limit)", discount.Promotion.ID).
+			Update("usage_count", gorm.Expr("usage_count + 1"))
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrUnavailable, discount.Promotion.Name)
+		}
+	}
+	return discounts, nil
+}
+
+// Release gives back the uses a cancelled order made of its promotions, so they
+// count towards their usage limits again. The order keeps its adjustments as a
+// record of the discount. Call it in the transaction that cancels the order.
+func Release(tx *gorm.DB, orderID uuid.UUID) error {
+	var used []uuid.UUID
+	err := tx.Model(&models.OrderAdjustment{}).
+		Where("order_id = ? AND promotion_id IS NOT NULL", orderID).
+		Distinct("promotion_id").
+		Pluck("promotion_id", &used).Error
+	if err != nil || len(used) == 0 {
+		return err
+	}
+	return tx.Model(&models.Promotion{}).
+		Where("id IN ? AND usage_count > 0", used).
+		Update("usage_count", gorm.Expr("usage_count - 1")).Error
+}
+
+// Evaluate returns the discounts the promotions give the line, in the order the
+// promotions are given. Exclusive promotions compete with the combination of all
+// other applicable ones, and the option saving the most wins, except that the
+// promotion of a redeemed coupon always applies when its conditions hold.
+// Discounts never take the line below zero.
+func Evaluate(promotions []models.Promotion, line Line, convert Converter, now time.Time) ([]Discount, error) {
+	var stacked []Discount
+	stackedTotal := money.Zero(line.Subtotal.Currency)
+	var best, coupon *Discount
+	for i := range promotions {
+		promotion := &promotions[i]
+		if !Applies(promotion, line, now) {
+			continue
+		}
+		amount, err := discount(promotion, line, convert)
+		if errors.Is(err, money.ErrNoRate) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !amount.IsPositive() {
+			continue
+		}
+
+		d := Discount{Promotion: *promotion, Amount: amount}
+		if promotion.ID == line.CouponPromotionID {
+			coupon = &d
+		}
+		if promotion.Exclusive {
+			if best == nil || amount.Amount > best.Amount.Amount {
+				best = &d
+			}
+			continue
+		}
+		stacked = append(stacked, d)
+		if stackedTotal, err = stackedTotal.Add(amount); err != nil {
+			return nil, err
+		}
+	}
+
+	chosen := stacked
+	switch {
+	case coupon != nil && coupon.Promotion.Exclusive:
+		chosen = []Discount{*coupon}
+	case coupon != nil:
+		// Put the coupon first, so capping trims the other discounts rather than
+		// the one the customer entered
+		chosen = []Discount{*coupon}
+		for _, d := range stacked {
+			if d.Promotion.ID != coupon.Promotion.ID {
+				chosen = append(chosen, d)
+			}
+		}
+	case best != nil && best.Amount.Amount > stackedTotal.Amount:
+		chosen = []Discount{*best}
+	}
+	return capped(chosen, line.Subtotal), nil
+}
+
+// Applies reports whether the promotion's conditions hold for the line at now
+func Applies(promotion *models.Promotion, line Line, now time.Time) bool {
+	if !promotion.Active || line.Quantity < promotion.MinQuantity {
+		return false
+	}
+	if (promotion.StartsAt != nil && now.Before(*promotion.StartsAt)) || (promotion.EndsAt != nil && !now.Before(*promotion.EndsAt)) {
+		return false
+	}
+	if promotion.UsageLimit != nil && promotion.UsageCount >= *promotion.UsageLimit {
+		return false
+	}
+
+	if len(promotion.ProductIDs) == 0 && len(promotion.CategoryIDs) == 0 {
+		return true
+	}
+	for _, id := range promotion.ProductIDs {
+		if id == line.ProductID {
+			return true
+		}
+	}
+	for _, id := range promotion.CategoryIDs {
+		if line.CategoryIDs[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// Percentage parses a promotion's percentage, which must be above 0 and at most 100
+func Percentage(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || r.Sign() <= 0 || r.Cmp(big.NewRat(100, 1)) > 0 {
+		return nil, fmt.Errorf("invalid percentage %q", s)
+	}
+	return r, nil
+}
+
+// discount computes the amount the promotion takes off the line
+func discount(promotion *models.Promotion, line Line, convert Converter) (money.Money, error) {
+	switch promotion.Type {
+	case models.PromotionPercentage:
+		percentage, err := Percentage(promotion.Percentage)
+		if err != nil {
+			return money.Money{}, err
+		}
+		return line.Subtotal.MulRat(percentage.Quo(percentage, big.NewRat(100, 1)))
+	case models.PromotionFixed:
+		return convert(promotion.AmountOff)
+	case models.PromotionBuyXGetY:
+		if promotion.BuyQuantity <= 0 || promotion.GetQuantity <= 0 {
+			return money.Zero(line.Subtotal.Currency), nil
+		}
+		free := line.Quantity / (promotion.BuyQuantity + promotion.GetQuantity) * promotion.GetQuantity
+		return line.UnitPrice.Mul(int64(free))
+	}
+	return money.Money{}, fmt.Errorf("unknown promotion type %q", promotion.Type)
+}
+
+// capped trims the discounts, last ones first, so together they don't exceed
+// the subtotal
+func capped(discounts []Discount, subtotal money.Money) []Discount {
+	remaining := subtotal.Amount
+	var result []Discount
+	for _, d := range discounts {
+		if remaining <= 0 {
+			break
+		}
+		if d.Amount.Amount > remaining {
+			d.Amount.Amount = remaining
+		}
+		remaining -= d.Amount.Amount
+		result = append(result, d)
+	}
+	return result
+}
+
+// withinUserLimits drops promotions the user has already used on as many
+// orders as their per-user limit allows. Cancelled orders don't count.
+func withinUserLimits(tx *gorm.DB, promotions []models.Promotion, userID uuid.UUID) ([]models.Promotion, error) {
+	var limited []uuid.UUID
+	for _, promotion := range promotions {
+		if promotion.PerUserLimit != nil {
+			limited = append(limited, promotion.ID)
+		}
+	}
+	if len(limited) == 0 {
+		return promotions, nil
+	}
+
+	var usage []struct {
+		PromotionID uuid.UUID
+		Orders      int
+	}
+	err := tx.Model(&models.OrderAdjustment{}).
+		Select("order_adjustments.promotion_id, COUNT(DISTINCT order_adjustments.order_id) AS orders").
+		Joins("JOIN orders ON orders.id = order_adjustments.order_id AND orders.deleted_at IS NULL AND orders.status <> ?", models.OrderStatusCancelled).
+		Where("orders.user_id = ? AND order_adjustments.promotion_id IN ?", userID, limited).
+		Group("order_adjustments.promotion_id").
+		Scan(&usage).Error
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[uuid.UUID]int, len(usage))
+	for _, u := range usage {
+		used[u.PromotionID] = u.Orders
+	}
+
+	var result []models.Promotion
+	for _, promotion := range promotions {
+		if promotion.PerUserLimit == nil || used[promotion.ID] < *promotion.PerUserLimit {
+			result = append(result, promotion)
+		}
+	}
+	return result, nil
+}
+
+// categoryIDs returns the product's categories and their ancestors, read from
+// the category paths product-service keeps ("/<root>/<child>/")
+func categoryIDs(tx *gorm.DB, productID uuid.UUID) (map[uuid.UUID]bool, error) {
+	var paths []string
+	err := tx.Table("categories").
+		Joins("JOIN product_categories ON product_categories.category_id = categories.id").
+		Where("product_categories.product_id = ?", productID).
+		Pluck("categories.path", &paths).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[uuid.UUID]bool)
+	for _, path := range paths {
+		for _, part := range strings.Split(path, "/") {
+			if id, err := uuid.Parse(part); err == nil {
+				ids[id] = true
+			}
+		}
+	}
+	return ids, nil
+}
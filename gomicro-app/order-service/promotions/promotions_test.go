@@ -0,0 +1,226 @@
+package promotions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+)
+
+func usd(t *testing.T, amount string) money.Money {
+	t.Helper()
+	m, err := money.New(amount, "USD")
+	if err != nil {
+		t.Fatalf("money.New(%q): %v", amount, err)
+	}
+	return m
+}
+
+// sameCurrency converts nothing; every promotion in these tests is in USD
+func sameCurrency(amount money.Money) (money.Money, error) {
+	return amount, nil
+}
+
+func percentOff(name, percentage string) models.Promotion {
+	return models.Promotion{ID: uuid.New(), Name: name, Type: models.PromotionPercentage, Percentage: percentage, Active: true}
+}
+
+func amountOff(name string, amount money.Money) models.Promotion {
+	return models.Promotion{ID: uuid.New(), Name: name, Type: models.PromotionFixed, AmountOff: amount, Active: true}
+}
+
+func buyGet(name string, buy, get int) models.Promotion {
+	return models.Promotion{ID: uuid.New(), Name: name, Type: models.PromotionBuyXGetY, BuyQuantity: buy, GetQuantity: get, Active: true}
+}
+
+func exclusive(p models.Promotion) models.Promotion {
+	p.Exclusive = true
+	return p
+}
+
+func line(t *testing.T, quantity int, unitPrice string) Line {
+	t.Helper()
+	unit := usd(t, unitPrice)
+	subtotal, err := unit.Mul(int64(quantity))
+	if err != nil {
+		t.Fatalf("subtotal: %v", err)
+	}
+	return Line{ProductID: uuid.New(), Quantity: quantity, UnitPrice: unit, Subtotal: subtotal}
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Now()
+	tenOff := amountOff("ten off", usd(t, "10"))
+	bulk := percentOff("bulk", "10")
+	bulk.MinQuantity = 5
+	limit := 1
+	limited := percentOff("limited", "10")
+	limited.UsageLimit, limited.UsageCount = &limit, 1
+
+	tests := []struct {
+		name       string
+		promotions []models.Promotion
+		line       Line
+		want       []string
+	}{
+		{
+			name:       "percentage",
+			promotions: []models.Promotion{percentOff("fifteen", "15")},
+			line:       line(t, 1, "19.99"),
+			want:       []string{"fifteen 3.00 USD"},
+		},
+		{
+			name:       "percentage rounds half to even",
+			promotions: []models.Promotion{percentOff("half", "50")},
+			line:       line(t, 1, "0.05"),
+			want:       []string{"half 0.02 USD"},
+		},
+		{
+			name:       "fixed",
+			promotions: []models.Promotion{tenOff},
+			line:       line(t, 2, "25"),
+			want:       []string{"ten off 10.00 USD"},
+		},
+		{
+			name:       "buy two get one",
+			promotions: []models.Promotion{buyGet("3 for 2", 2, 1)},
+			line:       line(t, 7, "4.50"),
+			want:       []string{"3 for 2 9.00 USD"},
+		},
+		{
+			name:       "stacked",
+			promotions: []models.Promotion{percentOff("ten", "10"), tenOff},
+			line:       line(t, 1, "100"),
+			want:       []string{"ten 10.00 USD", "ten off 10.00 USD"},
+		},
+		{
+			name:       "exclusive beats the stack",
+			promotions: []models.Promotion{percentOff("ten", "10"), tenOff, exclusive(percentOff("quarter", "25"))},
+			line:       line(t, 1, "100"),
+			want:       []string{"quarter 25.00 USD"},
+		},
+		{
+			name:       "stack beats exclusive",
+			promotions: []models.Promotion{percentOff("ten", "10"), tenOff, exclusive(percentOff("fifteen", "15"))},
+			line:       line(t, 1, "100"),
+			want:       []string{"ten 10.00 USD", "ten off 10.00 USD"},
+		},
+		{
+			name:       "capped at the subtotal",
+			promotions: []models.Promotion{percentOff("ninety", "90"), tenOff},
+			line:       line(t, 1, "20"),
+			want:       []string{"ninety 18.00 USD", "ten off 2.00 USD"},
+		},
+		{
+			name:       "below minimum quantity",
+			promotions: []models.Promotion{bulk},
+			line:       line(t, 4, "10"),
+		},
+		{
+			name:       "usage limit reached",
+			promotions: []models.Promotion{limited},
+			line:       line(t, 1, "10"),
+		},
+	}
+	for _, tt := range tests {
+		discounts, err := Evaluate(tt.promotions, tt.line, sameCurrency, now)
+		if err != nil {
+			t.Errorf("%s: Evaluate error = %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, d := range discounts {
+			got = append(got, d.Promotion.Name+" "+d.Amount.String())
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: Evaluate = %q, want %q", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: Evaluate = %q, want %q", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestEvaluateCouponComesFirst(t *testing.T) {
+	coupon := percentOff("coupon", "50")
+	coupon.RequiresCoupon = true
+	l := line(t, 1, "20")
+	l.CouponPromotionID = coupon.ID
+
+	discounts, err := Evaluate([]models.Promotion{percentOff("sale", "60"), coupon}, l, sameCurrency, time.Now())
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if len(discounts) != 2 || discounts[0].Promotion.ID != coupon.ID ||
+		discounts[0].Amount.String() != "10.00 USD" || discounts[1].Amount.String() != "10.00 USD" {
+		t.Errorf("Evaluate = %+v, want the full coupon discount and the sale capped after it", discounts)
+	}
+
+	// An exclusive coupon applies alone even when another promotion saves more
+	coupon.Exclusive = true
+	discounts, err = Evaluate([]models.Promotion{percentOff("sale", "60"), coupon}, l, sameCurrency, time.Now())
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if len(discounts) != 1 || discounts[0].Promotion.ID != coupon.ID {
+		t.Errorf("Evaluate = %+v, want only the exclusive coupon", discounts)
+	}
+}
+
+func TestApplies(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+	category := uuid.New()
+	l := line(t, 1, "10")
+	l.CategoryIDs = map[uuid.UUID]bool{category: true}
+
+	tests := []struct {
+		name   string
+		modify func(*models.Promotion)
+		want   bool
+	}{
+		{name: "any product", modify: func(*models.Promotion) {}, want: true},
+		{name: "inactive", modify: func(p *models.Promotion) { p.Active = false }},
+		{name: "not started", modify: func(p *models.Promotion) { p.StartsAt = &after }},
+		{name: "ended", modify: func(p *models.Promotion) { p.EndsAt = &now }},
+		{name: "running", modify: func(p *models.Promotion) { p.StartsAt, p.EndsAt = &before, &after }, want: true},
+		{name: "listed product", modify: func(p *models.Promotion) { p.ProductIDs = []uuid.UUID{l.ProductID} }, want: true},
+		{name: "other product", modify: func(p *models.Promotion) { p.ProductIDs = []uuid.UUID{uuid.New()} }},
+		{name: "listed category", modify: func(p *models.Promotion) { p.CategoryIDs = []uuid.UUID{category} }, want: true},
+		{name: "other category", modify: func(p *models.Promotion) { p.CategoryIDs = []uuid.UUID{uuid.New()} }},
+	}
+	for _, tt := range tests {
+		p := percentOff(tt.name, "10")
+		tt.modify(&p)
+		if got := Applies(&p, l, now); got != tt.want {
+			t.Errorf("%s: Applies = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPercentage(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{in: "10"},
+		{in: "12.5"},
+		{in: "100"},
+		{in: "0", wantErr: true},
+		{in: "-5", wantErr: true},
+		{in: "100.01", wantErr: true},
+		{in: "ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := Percentage(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Percentage(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Package shipping prices parcels with the tenant's shipping methods and their
+// rate tables
+package shipping
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnknownMethod is returned for shipping methods that don't exist or are inactive
+	ErrUnknownMethod = errors.New("unknown shipping method")
+
+	// ErrNoRate is returned when no rate of a shipping method covers the parcel
+	ErrNoRate = errors.New("shipping method does not deliver this order")
+)
+
+// Parcel is what is being shipped and where to
+type Parcel struct {
+	Country     string
+	Region      string
+	WeightGrams int
+
+	// Value is what the customer pays for the goods after discounts, in the
+	// currency quotes are made in
+	Value money.Money
+}
+
+// Quote is the price of shipping a parcel with a method
+type Quote struct {
+	Method models.ShippingMethod
+	Rate   models.ShippingRate
+	Price  money.Money
+}
+
+// Converter converts an amount into the parcel's currency
+type Converter func(money.Money) (money.Money, error)
+
+// Quotes prices the parcel with every active shipping method that delivers it,
+// cheapest first. The tenant comes from db's context.
+func Quotes(db *gorm.DB, parcel Parcel, convert Converter) ([]Quote, error) {
+	var methods []models.ShippingMethod
+	if err := db.Preload("Rates").Where("active = ?", true).Order("name").Find(&methods).Error; err != nil {
+		return nil, err
+	}
+
+	quotes := []Quote{}
+	for _, method := range methods {
+		quote, err := Price(method, parcel, convert)
+		if errors.Is(err, ErrNoRate) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, *quote)
+	}
+	sort.SliceStable(quotes, func(i, j int) bool {
+		return quotes[i].Price.Amount < quotes[j].Price.Amount
+	})
+	return quotes, nil
+}
+
+// Method loads an active shipping method with its rates. The tenant comes from
+// db's context.
+func Method(db *gorm.DB, id uuid.UUID) (*models.ShippingMethod, error) {
+	var method models.ShippingMethod
+	err := db.Preload("Rates").Where("active = ?", true).First(&method, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnknownMethod
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &method, nil
+}
+
+// Price prices the parcel with a shipping method, whose Rates must be loaded.
+// The rate with the most specific destination wins: a regional rate beats a
+// country-wide one, which beats one for everywhere. Among equally specific
+// rates the cheapest wins.
+func Price(method models.ShippingMethod, parcel Parcel, convert Converter) (*Quote, error) {
+	var best *Quote
+	bestScore := -1
+	for _, rate := range method.Rates {
+		score, ok := destination(rate, parcel)
+		if !ok || score < bestScore || !inWeight(rate, parcel.WeightGrams) {
+			continue
+		}
+		ok, err := inValue(rate, parcel.Value, convert)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		price, err := convert(rate.Price)
+		if err != nil {
+			return nil, err
+		}
+		if score == bestScore && price.Amount >= best.Price.Amount {
+			continue
+		}
+		best = &Quote{Method: method, Rate: rate, Price: price}
+		bestScore = score
+	}
+	if best == nil {
+		return nil, ErrNoRate
+	}
+	best.Method.Rates = nil
+	return best, nil
+}
+
+// destination reports whether the rate ships to the parcel's destination and
+// how specific it is about it
+func destination(rate models.ShippingRate, parcel Parcel) (int, bool) {
+	switch {
+	case rate.Country == "":
+		return 0, true
+	case !strings.EqualFold(rate.Country, parcel.Country):
+		return 0, false
+	case rate.Region == "":
+		return 1, true
+	case strings.EqualFold(rate.Region, parcel.Region):
+		return 2, true
+	}
+	return 0, false
+}
+
+// inWeight reports whether the weight falls in the rate's weight range
+func inWeight(rate models.ShippingRate, grams int) bool {
+	if grams < rate.MinWeightGrams {
+		return false
+	}
+	return rate.MaxWeightGrams == nil || *rate.MaxWeightGrams == 0 || grams < *rate.MaxWeightGrams
+}
+
+// inValue reports whether the order value falls in the rate's value range
+func inValue(rate models.ShippingRate, value money.Money, convert Converter) (bool, error) {
+	if rate.MinOrderValue.IsPositive() {
+		minimum, err := convert(rate.MinOrderValue)
+		if err != nil {
+			return false, err
+		}
+		if value.Amount < minimum.Amount {
+			return false, nil
+		}
+	}
+	if rate.MaxOrderValue.IsPositive() {
+		maximum, err := convert(rate.MaxOrderValue)
+		if err != nil {
+			return false, err
+		}
+		if value.Amount >= maximum.Amount {
+			return false, nil
+		}
+	}
+	return true, nil
+}
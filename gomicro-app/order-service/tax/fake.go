@@ -0,0 +1,49 @@
+package tax
+
+import (
+	"context"
+	"os"
+
+	"gorm.io/gorm"
+)
+
+// Fake stands in for an external tax provider in development and tests. It
+// charges one flat rate everywhere, from FAKE_TAX_RATE (a percentage, default 10),
+// and reports the shipping address as the jurisdiction the way a real provider would.
+type Fake struct {
+	Rate string
+}
+
+// newFake builds the fake provider from FAKE_TAX_RATE
+func newFake(*gorm.DB) (Calculator, error) {
+	rate := os.Getenv("FAKE_TAX_RATE")
+	if rate == "" {
+		rate = "10"
+	}
+	if _, err := ParseRate(rate); err != nil {
+		return nil, err
+	}
+	return Fake{Rate: rate}, nil
+}
+
+// Calculate implements Calculator
+func (f Fake) Calculate(ctx context.Context, req Request) ([]LineTax, error) {
+	rate, err := ParseRate(f.Rate)
+	if err != nil {
+		return nil, err
+	}
+
+	jurisdiction := req.Country
+	if req.Region != "" {
+		jurisdiction += "/" + req.Region
+	}
+	result := make([]LineTax, len(req.Lines))
+	for i, line := range req.Lines {
+		taxable, tax, err := Split(line.Amount, rate, req.PricesIncludeTax)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = LineTax{Name: "Fake tax", Jurisdiction: jurisdiction, Rate: rate, Taxable: taxable, Tax: tax}
+	}
+	return result, nil
+}
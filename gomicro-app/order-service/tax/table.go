@@ -0,0 +1,73 @@
+package tax
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ozturkeniss/gomicro-app/common/tenant"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+	"gorm.io/gorm"
+)
+
+// Table taxes lines with the tenant's tax rates. Lines no rate covers are
+// taxed at zero.
+type Table struct {
+	DB *gorm.DB
+}
+
+// Calculate implements Calculator
+func (t Table) Calculate(ctx context.Context, req Request) ([]LineTax, error) {
+	var rates []models.TaxRate
+	err := t.DB.WithContext(tenant.WithTenant(ctx, req.TenantID)).
+		Where("country = ? AND region IN ?", req.Country, []string{"", req.Region}).
+		Find(&rates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]LineTax, len(req.Lines))
+	for i, line := range req.Lines {
+		rate := match(rates, line.TaxClass)
+		percentage := new(big.Rat)
+		lineTax := LineTax{Jurisdiction: req.Country}
+		if rate != nil {
+			if percentage, err = ParseRate(rate.Rate); err != nil {
+				return nil, err
+			}
+			lineTax.Name = rate.Name
+			if rate.Region != "" {
+				lineTax.Jurisdiction = rate.Country + "/" + rate.Region
+			}
+		}
+
+		lineTax.Rate = percentage
+		if lineTax.Taxable, lineTax.Tax, err = Split(line.Amount, percentage, req.PricesIncludeTax); err != nil {
+			return nil, err
+		}
+		result[i] = lineTax
+	}
+	return result, nil
+}
+
+// match returns the most specific rate for the tax class: a regional rate beats
+// a country-wide one, and a rate for the class beats one for every class
+func match(rates []models.TaxRate, taxClass string) *models.TaxRate {
+	var best *models.TaxRate
+	bestScore := -1
+	for i, rate := range rates {
+		if rate.TaxClass != "" && rate.TaxClass != taxClass {
+			continue
+		}
+		score := 0
+		if rate.Region != "" {
+			score += 2
+		}
+		if rate.TaxClass != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = &rates[i], score
+		}
+	}
+	return best
+}
@@ -0,0 +1,133 @@
+// Package tax calculates the tax on orders. Calculators are registered by name
+// and selected with TAX_PROVIDER; the default "table" calculator uses the
+// tenant's tax rates, and other providers plug in with Register.
+package tax
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"gorm.io/gorm"
+)
+
+// DefaultProvider is used when TAX_PROVIDER is not set
+const DefaultProvider = "table"
+
+// Line is an order line to tax
+type Line struct {
+	ProductID uuid.UUID
+	TaxClass  string
+	Quantity  int
+
+	// Amount is what the customer pays for the line before tax is added, after
+	// discounts. With tax-inclusive prices it already contains the tax.
+	Amount money.Money
+}
+
+// Request describes the order being taxed
+type Request struct {
+	TenantID         uuid.UUID
+	Country          string
+	Region           string
+	PostalCode       string
+	PricesIncludeTax bool
+	Lines            []Line
+}
+
+// LineTax is the tax on one line. Rate is a percentage.
+type LineTax struct {
+	Name         string
+	Jurisdiction string
+	Rate         *big.Rat
+	Taxable      money.Money
+	Tax          money.Money
+}
+
+// Calculator returns the tax on each of the request's lines, in order
+type Calculator interface {
+	Calculate(ctx context.Context, req Request) ([]LineTax, error)
+}
+
+// Factory builds a calculator from the environment
+type Factory func(db *gorm.DB) (Calculator, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{
+		"table": func(db *gorm.DB) (Calculator, error) { return Table{DB: db}, nil },
+		"fake":  newFake,
+	}
+)
+
+// Default is the calculator built by Init, and Provider its name
+var (
+	Default  Calculator
+	Provider string
+)
+
+// Register makes a calculator available under name for TAX_PROVIDER
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = factory
+}
+
+// Init builds Default from TAX_PROVIDER (default table)
+func Init(db *gorm.DB) {
+	name := os.Getenv("TAX_PROVIDER")
+	if name == "" {
+		name = DefaultProvider
+	}
+
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		log.Fatalf("Unknown tax provider %q in TAX_PROVIDER", name)
+	}
+	calculator, err := factory(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize %s tax provider: %v", name, err)
+	}
+	Default, Provider = calculator, name
+	log.Printf("Tax provider initialized: %s", name)
+}
+
+// ParseRate parses a tax rate percentage between 0 and 100
+func ParseRate(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || r.Sign() < 0 || r.Cmp(big.NewRat(100, 1)) > 0 {
+		return nil, fmt.Errorf("invalid tax rate %q", s)
+	}
+	return r, nil
+}
+
+// FormatRate formats a tax rate percentage as stored
+func FormatRate(r *big.Rat) string {
+	return r.FloatString(4)
+}
+
+// Split taxes amount at the rate percentage. With inclusive pricing the tax is
+// the part of amount it already contains; otherwise it comes on top of amount.
+// taxable is the amount net of tax.
+func Split(amount money.Money, rate *big.Rat, inclusive bool) (taxable, tax money.Money, err error) {
+	hundred := big.NewRat(100, 1)
+	if !inclusive {
+		tax, err = amount.MulRat(new(big.Rat).Quo(rate, hundred))
+		return amount, tax, err
+	}
+
+	tax, err = amount.MulRat(new(big.Rat).Quo(rate, new(big.Rat).Add(hundred, rate)))
+	if err != nil {
+		return money.Money{}, money.Money{}, err
+	}
+	taxable, err = amount.Sub(tax)
+	return taxable, tax, err
+}
@@ -0,0 +1,139 @@
+package tax
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/order-service/models"
+)
+
+func mustMoney(t *testing.T, amount, currency string) money.Money {
+	t.Helper()
+	m, err := money.New(amount, currency)
+	if err != nil {
+		t.Fatalf("money.New(%q, %q): %v", amount, currency, err)
+	}
+	return m
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		amount, currency string
+		rate             string
+		inclusive        bool
+		wantTaxable      string
+		wantTax          string
+	}{
+		{"100.00", "USD", "10", false, "100.00 USD", "10.00 USD"},
+		{"110.00", "USD", "10", true, "100.00 USD", "10.00 USD"},
+		{"19.99", "EUR", "19", false, "19.99 EUR", "3.80 EUR"},
+		{"19.99", "EUR", "19", true, "16.80 EUR", "3.19 EUR"},
+		{"0.25", "USD", "10", false, "0.25 USD", "0.02 USD"},
+		{"0.35", "USD", "10", false, "0.35 USD", "0.04 USD"},
+		{"1000", "JPY", "8", true, "926 JPY", "74 JPY"},
+		{"50.00", "USD", "0", true, "50.00 USD", "0.00 USD"},
+		{"50.00", "USD", "7.25", false, "50.00 USD", "3.62 USD"},
+	}
+	for _, tt := range tests {
+		rate, err := ParseRate(tt.rate)
+		if err != nil {
+			t.Fatalf("ParseRate(%q): %v", tt.rate, err)
+		}
+		taxable, tax, err := Split(mustMoney(t, tt.amount, tt.currency), rate, tt.inclusive)
+		if err != nil {
+			t.Errorf("Split(%s %s, %s%%, %v) error = %v", tt.amount, tt.currency, tt.rate, tt.inclusive, err)
+			continue
+		}
+		if taxable.String() != tt.wantTaxable || tax.String() != tt.wantTax {
+			t.Errorf("Split(%s %s, %s%%, %v) = %s + %s, want %s + %s", tt.amount, tt.currency, tt.rate, tt.inclusive, taxable, tax, tt.wantTaxable, tt.wantTax)
+		}
+		if tt.inclusive {
+			if sum, err := taxable.Add(tax); err != nil || sum.String() != mustMoney(t, tt.amount, tt.currency).String() {
+				t.Errorf("Split(%s %s, %s%%, inclusive) parts add up to %s, want the amount", tt.amount, tt.currency, tt.rate, sum)
+			}
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{in: "0"},
+		{in: "7.25"},
+		{in: " 20 "},
+		{in: "100"},
+		{in: "-1", wantErr: true},
+		{in: "100.5", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "vat", wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := ParseRate(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRate(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+		}
+	}
+	if got := FormatRate(big.NewRat(29, 4)); got != "7.2500" {
+		t.Errorf("FormatRate(29/4) = %s, want 7.2500", got)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	rates := []models.TaxRate{
+		{Name: "country", Country: "US", Rate: "5"},
+		{Name: "country food", Country: "US", TaxClass: "food", Rate: "1"},
+		{Name: "region", Country: "US", Region: "CA", Rate: "7.25"},
+		{Name: "region books", Country: "US", Region: "CA", TaxClass: "books", Rate: "0"},
+	}
+	tests := []struct {
+		rates    []models.TaxRate
+		taxClass string
+		want     string
+	}{
+		{rates: rates, taxClass: "", want: "region"},
+		{rates: rates, taxClass: "books", want: "region books"},
+		{rates: rates, taxClass: "food", want: "region"},
+		{rates: rates[:2], taxClass: "food", want: "country food"},
+		{rates: rates[:2], taxClass: "toys", want: "country"},
+		{rates: rates[1:2], taxClass: "toys"},
+		{rates: nil, taxClass: "food"},
+	}
+	for _, tt := range tests {
+		got := match(tt.rates, tt.taxClass)
+		name := ""
+		if got != nil {
+			name = got.Name
+		}
+		if name != tt.want {
+			t.Errorf("match(%d rates, %q) = %q, want %q", len(tt.rates), tt.taxClass, name, tt.want)
+		}
+	}
+}
+
+func TestFakeCalculate(t *testing.T) {
+	req := Request{
+		Country: "DE",
+		Region:  "BE",
+		Lines: []Line{
+			{Amount: mustMoney(t, "100.00", "EUR")},
+			{Amount: mustMoney(t, "0.05", "EUR")},
+		},
+	}
+	got, err := Fake{Rate: "10"}.Calculate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Calculate: %v", err)
+	}
+	want := []string{"10.00 EUR", "0.00 EUR"}
+	if len(got) != len(want) {
+		t.Fatalf("Calculate returned %d lines, want %d", len(got), len(want))
+	}
+	for i, line := range got {
+		if line.Tax.String() != want[i] || line.Jurisdiction != "DE/BE" {
+			t.Errorf("line %d = %s in %s, want %s in DE/BE", i, line.Tax, line.Jurisdiction, want[i])
+		}
+	}
+}
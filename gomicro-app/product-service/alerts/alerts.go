@@ -0,0 +1,128 @@
+// Package alerts records products crossing their reorder point and delivers the
+// resulting low-stock and restock alerts through the configured notifiers
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"github.com/ozturkeniss/gomicro-app/product-service/notify"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxAttempts is how often delivery of an alert is tried before giving up
+	maxAttempts = 10
+	// batchSize is how many alerts one dispatch run delivers at most
+	batchSize = 100
+)
+
+// Track runs change, which may create the product or alter its stock or reorder
+// point, and records an alert in tx when the product becomes low on stock or is
+// restocked above its reorder point
+func Track(tx *gorm.DB, productID uuid.UUID, change func() error) error {
+	var before models.Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "stock", "reorder_point").Take(&before, "id = ?", productID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+
+	var after models.Product
+	if err := tx.Select("id", "tenant_id", "sku", "name", "stock", "reorder_point").Take(&after, "id = ?", productID).Error; err != nil {
+		return err
+	}
+
+	alert := models.StockAlert{ID: uuid.New(), TenantID: after.TenantID, ProductID: after.ID, SKU: after.SKU, Name: after.Name, Stock: after.Stock}
+	switch {
+	case !before.LowOnStock() && after.LowOnStock():
+		alert.Type = models.StockAlertLow
+		alert.ReorderPoint = *after.ReorderPoint
+	case before.LowOnStock() && !after.LowOnStock() && after.ReorderPoint != nil:
+		alert.Type = models.StockAlertRestocked
+		alert.ReorderPoint = *after.ReorderPoint
+	default:
+		return nil
+	}
+	return tx.Create(&alert).Error
+}
+
+// Dispatch delivers undelivered alerts, oldest first, and returns how many were
+// sent. Alerts being delivered by another instance are skipped.
+func Dispatch(ctx context.Context, db *gorm.DB, notifier notify.Notifier) (int, error) {
+	sent := 0
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var pending []models.StockAlert
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("delivered_at IS NULL AND attempts < ?", maxAttempts).
+			Order("created_at").Limit(batchSize).
+			Find(&pending).Error
+		if err != nil {
+			return err
+		}
+
+		for _, alert := range pending {
+			updates := map[string]interface{}{"attempts": alert.Attempts + 1}
+			if err := notifier.Notify(ctx, event(alert)); err != nil {
+				log.Printf("[ERROR] Failed to deliver stock alert %s: %v", alert.ID, err)
+				updates["last_error"] = truncate(err.Error(), 1000)
+			} else {
+				updates["delivered_at"] = time.Now()
+				sent++
+			}
+			if err := tx.Model(&alert).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return sent, err
+}
+
+// StartDispatchWorker periodically delivers pending alerts through notify.Default
+func StartDispatchWorker(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := Dispatch(context.Background(), db, notify.Default); err != nil {
+				log.Printf("[ERROR] Stock alert dispatch failed: %v", err)
+			}
+		}
+	}()
+}
+
+// event describes the alert for notifiers
+func event(alert models.StockAlert) notify.Event {
+	name := alert.Name
+	if alert.SKU != "" {
+		name = fmt.Sprintf("%s (%s)", alert.Name, alert.SKU)
+	}
+	message := fmt.Sprintf("%s is low on stock: %d left, reorder point %d", name, alert.Stock, alert.ReorderPoint)
+	if alert.Type == models.StockAlertRestocked {
+		message = fmt.Sprintf("%s is back above its reorder point: %d in stock, reorder point %d", name, alert.Stock, alert.ReorderPoint)
+	}
+	return notify.Event{
+		ID:         alert.ID,
+		Type:       alert.Type,
+		TenantID:   alert.TenantID,
+		OccurredAt: alert.CreatedAt,
+		Message:    message,
+		Data:       alert,
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
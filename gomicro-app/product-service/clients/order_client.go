@@ -0,0 +1,51 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/serviceauth"
+)
+
+// ErrNoPurchase is returned when the user has no delivered order of the product
+var ErrNoPurchase = errors.New("no delivered order of this product")
+
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+// FindDeliveredOrder asks order-service for the user's delivered order of the
+// product in the tenant and returns its ID
+func FindDeliveredOrder(ctx context.Context, tenantID, userID, productID uuid.UUID) (uuid.UUID, error) {
+	url := fmt.Sprintf("%s/internal/tenants/%s/users/%s/purchases/%s", os.Getenv("ORDER_SERVICE_URL"), tenantID, userID, productID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	serviceauth.Authorize(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return uuid.Nil, ErrNoPurchase
+	case resp.StatusCode != http.StatusOK:
+		return uuid.Nil, fmt.Errorf("GET %s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	var purchase struct {
+		OrderID uuid.UUID `json:"order_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&purchase); err != nil {
+		return uuid.Nil, err
+	}
+	return purchase.OrderID, nil
+}
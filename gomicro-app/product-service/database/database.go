@@ -1,12 +1,15 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/common/tenant"
 	"github.com/ozturkeniss/gomicro-app/product-service/models"
 )
 
@@ -29,12 +32,149 @@ func InitDB() {
 
 	log.Println("Successfully connected to database")
 
+	// Scope every query on tenant-owned models to the tenant in the request context
+	if err := DB.Use(tenant.Plugin{}); err != nil {
+		log.Fatalf("Failed to register tenant plugin: %v", err)
+	}
+
+	// AutoMigrate the Category model before Product, which references it through product_categories
+	err = DB.AutoMigrate(&models.Category{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate Category model: %v", err)
+	}
+	log.Println("Category table auto migrated successfully")
+
+	// Products created before organizations existed are given to DEFAULT_TENANT_ID
+	defaultTenant, err := tenant.DefaultTenant()
+	if err != nil {
+		log.Fatalf("Invalid DEFAULT_TENANT_ID: %v", err)
+	}
+	if err := tenant.MigrateColumn(DB, &models.Product{}, defaultTenant); err != nil {
+		log.Fatalf("Failed to migrate products tenant_id column: %v", err)
+	}
+
 	// AutoMigrate the Product model
 	err = DB.AutoMigrate(&models.Product{})
 	if err != nil {
 		log.Fatalf("Failed to auto migrate Product model: %v", err)
 	}
 	log.Println("Product table auto migrated successfully")
+
+	// Move prices stored as floats before the money type into exact decimal columns
+	if err := money.MigrateFloatColumn(DB, &models.Product{}, "price", "price_", money.DefaultCurrency()); err != nil {
+		log.Fatalf("Failed to migrate price column: %v", err)
+	}
+
+	// Keep the full-text search vector up to date
+	if FullTextSearch(DB) {
+		if err := setupSearch(DB); err != nil {
+			log.Fatalf("Failed to set up product search: %v", err)
+		}
+		log.Println("Product search vector trigger installed")
+	}
+
+	// AutoMigrate the AttributeDefinition model
+	err = DB.AutoMigrate(&models.AttributeDefinition{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate AttributeDefinition model: %v", err)
+	}
+	log.Println("AttributeDefinition table auto migrated successfully")
+
+	// Deleted variants used to keep their SKU reserved; drop that index so
+	// AutoMigrate recreates it over live variants only
+	var staleIndexes int64
+	err = DB.Raw("SELECT COUNT(*) FROM pg_indexes WHERE indexname = ? AND indexdef NOT LIKE ?", "idx_variants_tenant_sku", "%WHERE%").Scan(&staleIndexes).Error
+	if err != nil {
+		log.Fatalf("Failed to inspect variant SKU index: %v", err)
+	}
+	if staleIndexes > 0 {
+		if err := DB.Migrator().DropIndex(&models.Variant{}, "idx_variants_tenant_sku"); err != nil {
+			log.Fatalf("Failed to drop variant SKU index: %v", err)
+		}
+	}
+
+	// AutoMigrate the Variant model
+	err = DB.AutoMigrate(&models.Variant{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate Variant model: %v", err)
+	}
+	log.Println("Variant table auto migrated successfully")
+
+	// AutoMigrate the ProductImage model
+	err = DB.AutoMigrate(&models.ProductImage{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate ProductImage model: %v", err)
+	}
+	log.Println("ProductImage table auto migrated successfully")
+
+	// AutoMigrate the inventory models
+	err = DB.AutoMigrate(&models.Warehouse{}, &models.StockLevel{}, &models.StockTransfer{}, &models.Allocation{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate inventory models: %v", err)
+	}
+	log.Println("Inventory tables auto migrated successfully")
+
+	// AutoMigrate the price history and scheduled price models
+	err = DB.AutoMigrate(&models.PriceChange{}, &models.ScheduledPrice{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate price models: %v", err)
+	}
+	log.Println("Price tables auto migrated successfully")
+
+	// Start the price history of products created before it was kept
+	err = DB.Exec(`INSERT INTO price_changes (id, tenant_id, product_id, price_amount, price_currency, source, effective_at, created_at)
+		SELECT uuid_generate_v4(), p.tenant_id, p.id, p.price_amount, p.price_currency, ?, p.created_at, NOW()
+		FROM products p
+		WHERE NOT EXISTS (SELECT 1 FROM price_changes c WHERE c.product_id = p.id)`, models.PriceSourceInitial).Error
+	if err != nil {
+		log.Fatalf("Failed to seed price history: %v", err)
+	}
+
+	// AutoMigrate the StockAlert model
+	err = DB.AutoMigrate(&models.StockAlert{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate StockAlert model: %v", err)
+	}
+	log.Println("StockAlert table auto migrated successfully")
+
+	// AutoMigrate the Review and ReviewVote models
+	err = DB.AutoMigrate(&models.Review{}, &models.ReviewVote{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate Review models: %v", err)
+	}
+	log.Println("Review tables auto migrated successfully")
+
+	// AutoMigrate the ImportJob model
+	err = DB.AutoMigrate(&models.ImportJob{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate ImportJob model: %v", err)
+	}
+	log.Println("ImportJob table auto migrated successfully")
+
+	// AutoMigrate the ExchangeRate model
+	err = DB.AutoMigrate(&models.ExchangeRate{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate ExchangeRate model: %v", err)
+	}
+	log.Println("ExchangeRate table auto migrated successfully")
+
+	// Seed exchange rates from EXCHANGE_RATES_FILE, overwriting rates with the same pair
+	if path := os.Getenv("EXCHANGE_RATES_FILE"); path != "" {
+		file, err := money.LoadRateFile(path)
+		if err != nil {
+			log.Fatalf("Failed to load exchange rates: %v", err)
+		}
+		if err := SaveExchangeRates(DB, file, models.RateSourceFile); err != nil {
+			log.Fatalf("Failed to save exchange rates: %v", err)
+		}
+		log.Printf("Loaded %d exchange rates from %s", len(file.Rates), path)
+	}
+}
+
+// Scoped returns a session bound to ctx, so queries on tenant-owned models are
+// restricted to the tenant carried by the request
+func Scoped(ctx context.Context) *gorm.DB {
+	return DB.WithContext(ctx)
 }
 
 // TestDatabaseConnection tries to connect to the database and logs the result
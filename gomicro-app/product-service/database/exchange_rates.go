@@ -0,0 +1,52 @@
+package database
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveExchangeRates upserts every rate in a validated rate file
+func SaveExchangeRates(db *gorm.DB, file *money.RateFile, source string) error {
+	if len(file.Rates) == 0 {
+		return nil
+	}
+	rates := make([]models.ExchangeRate, 0, len(file.Rates))
+	for quote, rate := range file.Rates {
+		rates = append(rates, models.ExchangeRate{
+			ID:     uuid.New(),
+			Base:   file.Base,
+			Quote:  quote,
+			Rate:   rate,
+			Source: source,
+		})
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "base"}, {Name: "quote"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).Create(&rates).Error
+}
+
+// LoadRateTable reads all exchange rates into a lookup table. Rates that no
+// longer parse, such as ones saved before rates were range checked and rounded
+// to zero, are logged and left out.
+func LoadRateTable(db *gorm.DB) (money.RateTable, error) {
+	var rates []models.ExchangeRate
+	if err := db.Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	table := make(money.RateTable, len(rates))
+	for _, r := range rates {
+		rate, err := money.ParseRate(r.Rate)
+		if err != nil {
+			log.Printf("Skipping exchange rate %s/%s: %v", r.Base, r.Quote, err)
+			continue
+		}
+		table.Set(r.Base, r.Quote, rate)
+	}
+	return table, nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+var searchConfigPattern = regexp.MustCompile(`^[a-z_]+$`)
+
+// SearchConfig returns the Postgres text search configuration used for products
+// (SEARCH_CONFIG, english by default)
+func SearchConfig() string {
+	if config := os.Getenv("SEARCH_CONFIG"); searchConfigPattern.MatchString(config) {
+		return config
+	}
+	return "english"
+}
+
+// FullTextSearch reports whether the database supports Postgres full-text search
+func FullTextSearch(db *gorm.DB) bool {
+	return db.Dialector.Name() == "postgres"
+}
+
+// setupSearch installs the trigger that keeps products.search_vector in sync with
+// the name (weight A) and description (weight B), indexes it, and fills it in for
+// rows written before the trigger existed
+func setupSearch(db *gorm.DB) error {
+	config := SearchConfig()
+	statements := []string{
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION products_search_vector_update() RETURNS trigger AS $$
+BEGIN
+	NEW.search_vector :=
+		setweight(to_tsvector('%[1]s', coalesce(NEW.name, '')), 'A') ||
+		setweight(to_tsvector('%[1]s', coalesce(NEW.description, '')), 'B');
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql`, config),
+		`DROP TRIGGER IF EXISTS products_search_vector_trigger ON products`,
+		`CREATE TRIGGER products_search_vector_trigger BEFORE INSERT OR UPDATE OF name, description ON products
+	FOR EACH ROW EXECUTE FUNCTION products_search_vector_update()`,
+		`CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector)`,
+		fmt.Sprintf(`UPDATE products SET search_vector =
+	setweight(to_tsvector('%[1]s', coalesce(name, '')), 'A') ||
+	setweight(to_tsvector('%[1]s', coalesce(description, '')), 'B')
+	WHERE search_vector IS NULL`, config),
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,7 +2,10 @@ module github.com/ozturkeniss/gomicro-app/product-service
 
 go 1.24.2
 
-require github.com/micro/go-micro/v2 v2.9.1
+require (
+	github.com/google/uuid v1.1.1
+	github.com/micro/go-micro/v2 v2.9.1
+)
 
 require (
 	github.com/BurntSushi/toml v0.3.1 // indirect
@@ -20,7 +23,6 @@ require (
 	github.com/go-git/go-git/v5 v5.1.0 // indirect
 	github.com/gogo/protobuf v1.2.1 // indirect
 	github.com/golang/protobuf v1.5.0 // indirect
-	github.com/google/uuid v1.1.1 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/hpcloud/tail v1.0.0 // indirect
 	github.com/imdario/mergo v0.3.9 // indirect
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/product-service/database"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"gorm.io/gorm"
+)
+
+var attributeKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// attributeRequest is the body for creating and updating attribute definitions
+type attributeRequest struct {
+	Key      string   `json:"key" binding:"required"`
+	Name     string   `json:"name" binding:"required"`
+	Type     string   `json:"type" binding:"required"`
+	Required bool     `json:"required"`
+	Unit     string   `json:"unit"`
+	Values   []string `json:"values"`
+	Min      *float64 `json:"min"`
+	Max      *float64 `json:"max"`
+}
+
+// CreateAttribute defines a new custom product attribute
+func CreateAttribute(c *gin.Context) {
+	var req attributeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	definition := models.AttributeDefinition{ID: uuid.New()}
+	applyAttributeRequest(&definition, &req)
+	if err := validateDefinition(&definition); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.Scoped(c.Request.Context())
+	var taken int64
+	if result := db.Model(&models.AttributeDefinition{}).Where("key = ?", definition.Key).Count(&taken); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if taken > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Attribute key is already in use"})
+		return
+	}
+
+	if result := db.Create(&definition); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, definition)
+}
+
+// ListAttributes lists the tenant's attribute definitions
+func ListAttributes(c *gin.Context) {
+	var definitions []models.AttributeDefinition
+	result := database.Scoped(c.Request.Context()).Order("key").Find(&definitions)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, definitions)
+}
+
+// GetAttribute retrieves an attribute definition
+func GetAttribute(c *gin.Context) {
+	definition, ok := loadAttribute(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, definition)
+}
+
+// UpdateAttribute updates an attribute definition. The key and type can't change,
+// since existing product values depend on them.
+func UpdateAttribute(c *gin.Context) {
+	var req attributeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	definition, ok := loadAttribute(c)
+	if !ok {
+		return
+	}
+	if req.Key != definition.Key || req.Type != definition.Type {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Attribute key and type can't be changed"})
+		return
+	}
+
+	applyAttributeRequest(definition, &req)
+	if err := validateDefinition(definition); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if result := database.Scoped(c.Request.Context()).Save(definition); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, definition)
+}
+
+// DeleteAttribute deletes an attribute definition no product has a value for
+func DeleteAttribute(c *gin.Context) {
+	definition, ok := loadAttribute(c)
+	if !ok {
+		return
+	}
+
+	db := database.Scoped(c.Request.Context())
+	var inUse int64
+	if result := db.Model(&models.Product{}).Where("jsonb_exists(attributes, ?)", definition.Key).Count(&inUse); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if inUse > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Attribute is set on %d products", inUse)})
+		return
+	}
+
+	if result := db.Delete(definition); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attribute deleted successfully"})
+}
+
+// loadAttribute loads the attribute definition from the :id path parameter
+func loadAttribute(c *gin.Context) (*models.AttributeDefinition, bool) {
+	definitionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attribute ID"})
+		return nil, false
+	}
+
+	var definition models.AttributeDefinition
+	if result := database.Scoped(c.Request.Context()).First(&definition, "id = ?", definitionID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attribute not found"})
+		return nil, false
+	}
+	return &definition, true
+}
+
+// applyAttributeRequest copies the request fields onto the definition
+func applyAttributeRequest(definition *models.AttributeDefinition, req *attributeRequest) {
+	definition.Key = req.Key
+	definition.Name = req.Name
+	definition.Type = req.Type
+	definition.Required = req.Required
+	definition.Unit = req.Unit
+	definition.Values = req.Values
+	definition.Min = req.Min
+	definition.Max = req.Max
+}
+
+// validateDefinition checks that the definition's settings fit its type
+func validateDefinition(definition *models.AttributeDefinition) error {
+	if !attributeKeyPattern.MatchString(definition.Key) {
+		return validationError("Key must start with a letter and contain only lowercase letters, digits and underscores")
+	}
+	if !models.ValidAttributeType(definition.Type) {
+		return validationError("Type must be one of string, number, boolean or enum")
+	}
+	if (definition.Type == models.AttributeTypeEnum) != (len(definition.Values) > 0) {
+		return validationError("Values are required for enum attributes and only allowed for them")
+	}
+	if definition.Type != models.AttributeTypeNumber && (definition.Min != nil || definition.Max != nil) {
+		return validationError("Min and max are only allowed for number attributes")
+	}
+	if definition.Min != nil && definition.Max != nil && *definition.Min > *definition.Max {
+		return validationError("Min can't be greater than max")
+	}
+	return nil
+}
+
+// loadDefinitions returns the tenant's attribute definitions keyed by key
+func loadDefinitions(db *gorm.DB) (map[string]models.AttributeDefinition, error) {
+	var definitions []models.AttributeDefinition
+	if err := db.Find(&definitions).Error; err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]models.AttributeDefinition, len(definitions))
+	for _, definition := range definitions {
+		byKey[definition.Key] = definition
+	}
+	return byKey, nil
+}
+
+// validateAttributes checks product attribute values against the tenant's definitions
+func validateAttributes(db *gorm.DB, attributes models.Attributes) error {
+	definitions, err := loadDefinitions(db)
+	if err != nil {
+		return err
+	}
+	return checkAttributes(definitions, attributes)
+}
+
+// checkAttributes checks attribute values against already loaded definitions
+func checkAttributes(definitions map[string]models.AttributeDefinition, attributes models.Attributes) error {
+	for key, value := range attributes {
+		definition, ok := definitions[key]
+		if !ok {
+			return validationError("Unknown attribute " + key)
+		}
+		if err := validateAttributeValue(&definition, value); err != nil {
+			return err
+		}
+	}
+	for key, definition := range definitions {
+		if _, ok := attributes[key]; definition.Required && !ok {
+			return validationError("Missing required attribute " + key)
+		}
+	}
+	return nil
+}
+
+// validateAttributeValue checks a single value against its definition
+func validateAttributeValue(definition *models.AttributeDefinition, value interface{}) error {
+	switch definition.Type {
+	case models.AttributeTypeString:
+		if _, ok := value.(string); !ok {
+			return validationError("Attribute " + definition.Key + " must be a string")
+		}
+	case models.AttributeTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return validationError("Attribute " + definition.Key + " must be a boolean")
+		}
+	case models.AttributeTypeEnum:
+		s, ok := value.(string)
+		if !ok || !containsString(definition.Values, s) {
+			return validationError("Attribute " + definition.Key + " must be one of " + strings.Join(definition.Values, ", "))
+		}
+	case models.AttributeTypeNumber:
+		n, ok := value.(float64)
+		if !ok {
+			return validationError("Attribute " + definition.Key + " must be a number")
+		}
+		if definition.Min != nil && n < *definition.Min {
+			return validationError(fmt.Sprintf("Attribute %s must be at least %g", definition.Key, *definition.Min))
+		}
+		if definition.Max != nil && n > *definition.Max {
+			return validationError(fmt.Sprintf("Attribute %s must be at most %g", definition.Key, *definition.Max))
+		}
+	}
+	return nil
+}
+
+// applyAttributeFilters adds the attr.<key>=value, attr.<key>.min and attr.<key>.max
+// query parameters to the query. Equality filters use JSONB containment so they
+// are served by the GIN index on products.attributes.
+func applyAttributeFilters(db, query *gorm.DB, params url.Values) (*gorm.DB, error) {
+	var names []string
+	for name := range params {
+		if strings.HasPrefix(name, "attr.") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return query, nil
+	}
+	sort.Strings(names)
+
+	definitions, err := loadDefinitions(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		key, bound := strings.TrimPrefix(name, "attr."), ""
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			key, bound = key[:i], key[i+1:]
+		}
+		definition, ok := definitions[key]
+		if !ok {
+			return nil, validationError("Unknown attribute " + key)
+		}
+		raw := params.Get(name)
+
+		switch bound {
+		case "min", "max":
+			if definition.Type != models.AttributeTypeNumber {
+				return nil, validationError("Range filters need a number attribute: " + key)
+			}
+			if _, err := strconv.ParseFloat(raw, 64); err != nil {
+				return nil, validationError("Invalid number for " + name)
+			}
+			op := ">="
+			if bound == "max" {
+				op = "<="
+			}
+			query = query.Where("(attributes->>?)::numeric "+op+" ?::numeric", key, raw)
+		case "":
+			value, err := parseAttributeValue(&definition, raw)
+			if err != nil {
+				return nil, err
+			}
+			containment, err := json.Marshal(map[string]interface{}{key: value})
+			if err != nil {
+				return nil, err
+			}
+			query = query.Where("attributes @> ?::jsonb", string(containment))
+		default:
+			return nil, validationError("Unknown attribute filter " + name)
+		}
+	}
+	return query, nil
+}
+
+// parseAttributeValue converts a query string value to the attribute's JSON type
+func parseAttributeValue(definition *models.AttributeDefinition, raw string) (interface{}, error) {
+	var value interface{} = raw
+	switch definition.Type {
+	case models.AttributeTypeNumber:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, validationError("Invalid number for attribute " + definition.Key)
+		}
+		value = n
+	case models.AttributeTypeBoolean:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, validationError("Invalid boolean for attribute " + definition.Key)
+		}
+		value = b
+	}
+	return value, nil
+}
+
+// writeRequestError responds 400 to validation failures and 500 to anything else
+func writeRequestError(c *gin.Context, err error) {
+	var invalid validationError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
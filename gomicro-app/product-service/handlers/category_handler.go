@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/paging"
+	"github.com/ozturkeniss/gomicro-app/product-service/database"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"gorm.io/gorm"
+)
+
+var (
+	slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+	errParentNotFound  = errors.New("parent category not found")
+	errCategoryCycle   = errors.New("a category can't be moved under itself or its descendants")
+	errSlugTaken       = errors.New("slug is already in use")
+	errCategoryInUse   = errors.New("category still has subcategories")
+	errUnknownCategory = errors.New("unknown category")
+)
+
+// categoryRequest is the body for creating and updating categories
+type categoryRequest struct {
+	Name     string     `json:"name" binding:"required"`
+	Slug     string     `json:"slug" binding:"required"`
+	ParentID *uuid.UUID `json:"parent_id"`
+	Position int        `json:"position"`
+}
+
+// CreateCategory adds a category, optionally under a parent
+func CreateCategory(c *gin.Context) {
+	var req categoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !slugPattern.MatchString(req.Slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug may only contain lowercase letters, digits and dashes"})
+		return
+	}
+
+	category := models.Category{ID: uuid.New()}
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := applyCategoryRequest(tx, &category, &req); err != nil {
+			return err
+		}
+		return tx.Create(&category).Error
+	})
+	if !writeCategoryError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// ListCategories returns the tenant's whole category tree
+func ListCategories(c *gin.Context) {
+	var categories []models.Category
+	result := database.Scoped(c.Request.Context()).Order("position, name").Find(&categories)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, buildTree(categories, uuid.Nil))
+}
+
+// GetCategory retrieves a category with its subtree and its ancestors, root first
+func GetCategory(c *gin.Context) {
+	category, ok := loadCategory(c)
+	if !ok {
+		return
+	}
+	db := database.Scoped(c.Request.Context())
+
+	var subtree []models.Category
+	if result := db.Where("path LIKE ? AND id <> ?", category.Path+"%", category.ID).Order("position, name").Find(&subtree); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	category.Children = buildTree(subtree, category.ID)
+
+	ancestorIDs := strings.Split(strings.Trim(category.Path, "/"), "/")
+	ancestorIDs = ancestorIDs[:len(ancestorIDs)-1]
+	ancestors := []models.Category{}
+	if len(ancestorIDs) > 0 {
+		if result := db.Where("id IN ?", ancestorIDs).Order("LENGTH(path)").Find(&ancestors); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"category": category, "ancestors": ancestors})
+}
+
+// UpdateCategory renames, reorders or moves a category; its subtree moves with it
+func UpdateCategory(c *gin.Context) {
+	var req categoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !slugPattern.MatchString(req.Slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug may only contain lowercase letters, digits and dashes"})
+		return
+	}
+
+	category, ok := loadCategory(c)
+	if !ok {
+		return
+	}
+
+	oldPath := category.Path
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := applyCategoryRequest(tx, category, &req); err != nil {
+			return err
+		}
+		if err := tx.Save(category).Error; err != nil {
+			return err
+		}
+		if category.Path == oldPath {
+			return nil
+		}
+
+		// Rewrite the path prefix of every descendant
+		var descendants []models.Category
+		if err := tx.Where("path LIKE ? AND id <> ?", oldPath+"%", category.ID).Find(&descendants).Error; err != nil {
+			return err
+		}
+		for _, d := range descendants {
+			path := category.Path + strings.TrimPrefix(d.Path, oldPath)
+			if err := tx.Model(&d).Update("path", path).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !writeCategoryError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory deletes a category without subcategories and unassigns its products
+func DeleteCategory(c *gin.Context) {
+	category, ok := loadCategory(c)
+	if !ok {
+		return
+	}
+
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var children int64
+		if err := tx.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children).Error; err != nil {
+			return err
+		}
+		if children > 0 {
+			return errCategoryInUse
+		}
+		if err := tx.Exec("DELETE FROM product_categories WHERE category_id = ?", category.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(category).Error
+	})
+	if !writeCategoryError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
+
+// ListCategoryProducts lists a page of the products in a category and all of its descendants
+func ListCategoryProducts(c *gin.Context) {
+	req, err := paging.FromRequest(c, productSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	category, ok := loadCategory(c)
+	if !ok {
+		return
+	}
+
+	db := database.Scoped(c.Request.Context())
+	query := db.Model(&models.Product{}).Where("id IN (?)", productsInCategory(db, category))
+	page, ok := findProductPage(c, req, query, query)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// SetProductCategories replaces the categories a product is assigned to
+func SetProductCategories(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var req struct {
+		CategoryIDs []uuid.UUID `json:"category_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var product models.Product
+	err = database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&product, "id = ?", productID).Error; err != nil {
+			return err
+		}
+		categories := []models.Category{}
+		if len(req.CategoryIDs) > 0 {
+			if err := tx.Where("id IN ?", req.CategoryIDs).Find(&categories).Error; err != nil {
+				return err
+			}
+		}
+		if len(categories) != len(uniqueIDs(req.CategoryIDs)) {
+			return errUnknownCategory
+		}
+		// Only touch the join table; the categories themselves are managed separately
+		return tx.Model(&product).Omit("Categories.*").Association("Categories").Replace(categories)
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	case errors.Is(err, errUnknownCategory):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown category"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+// loadCategory loads the category from the :id path parameter
+func loadCategory(c *gin.Context) (*models.Category, bool) {
+	categoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return nil, false
+	}
+
+	var category models.Category
+	if result := database.Scoped(c.Request.Context()).First(&category, "id = ?", categoryID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return nil, false
+	}
+	return &category, true
+}
+
+// findCategory looks a category up by ID or slug
+func findCategory(db *gorm.DB, ref string) (*models.Category, error) {
+	var category models.Category
+	query := db.Where("slug = ?", ref)
+	if id, err := uuid.Parse(ref); err == nil {
+		query = db.Where("id = ?", id)
+	}
+	if err := query.First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// productsInCategory returns a subquery selecting the IDs of products assigned to
+// the category or any of its descendants
+func productsInCategory(db *gorm.DB, category *models.Category) *gorm.DB {
+	subtree := db.Model(&models.Category{}).Select("id").Where("path LIKE ?", category.Path+"%")
+	return db.Table("product_categories").Select("product_id").Where("category_id IN (?)", subtree)
+}
+
+// applyCategoryRequest copies the request onto the category and recomputes its path
+func applyCategoryRequest(tx *gorm.DB, category *models.Category, req *categoryRequest) error {
+	var taken int64
+	if err := tx.Model(&models.Category{}).Where("slug = ? AND id <> ?", req.Slug, category.ID).Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return errSlugTaken
+	}
+
+	category.Name = req.Name
+	category.Slug = req.Slug
+	category.Position = req.Position
+	category.ParentID = req.ParentID
+	category.Path = "/" + category.ID.String() + "/"
+	if req.ParentID == nil {
+		return nil
+	}
+
+	var parent models.Category
+	if err := tx.First(&parent, "id = ?", *req.ParentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errParentNotFound
+		}
+		return err
+	}
+	if strings.Contains(parent.Path, "/"+category.ID.String()+"/") {
+		return errCategoryCycle
+	}
+	category.Path = parent.Path + category.ID.String() + "/"
+	return nil
+}
+
+// buildTree nests categories under their parents starting from the children of
+// parent (uuid.Nil for roots), keeping the query order among siblings
+func buildTree(categories []models.Category, parent uuid.UUID) []models.Category {
+	byParent := make(map[uuid.UUID][]models.Category)
+	for _, category := range categories {
+		key := uuid.Nil
+		if category.ParentID != nil {
+			key = *category.ParentID
+		}
+		byParent[key] = append(byParent[key], category)
+	}
+
+	var attach func(id uuid.UUID) []models.Category
+	attach = func(id uuid.UUID) []models.Category {
+		nodes := byParent[id]
+		for i := range nodes {
+			nodes[i].Children = attach(nodes[i].ID)
+		}
+		return nodes
+	}
+	if tree := attach(parent); tree != nil {
+		return tree
+	}
+	return []models.Category{}
+}
+
+// uniqueIDs removes duplicate IDs
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	var unique []uuid.UUID
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// writeCategoryError writes the response for a failed category change and reports whether err was nil
+func writeCategoryError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errParentNotFound), errors.Is(err, errCategoryCycle):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errSlugTaken), errors.Is(err, errCategoryInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
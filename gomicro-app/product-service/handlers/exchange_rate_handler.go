@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/product-service/database"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+)
+
+// ListExchangeRates lists the exchange rates used to convert prices
+func ListExchangeRates(c *gin.Context) {
+	var rates []models.ExchangeRate
+	result := database.DB.Order("base, quote").Find(&rates)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// SetExchangeRates upserts exchange rates from one base currency, in the same
+// layout as EXCHANGE_RATES_FILE
+func SetExchangeRates(c *gin.Context) {
+	var file money.RateFile
+	if err := c.ShouldBindJSON(&file); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := file.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.SaveExchangeRates(database.DB, &file, models.RateSourceAPI); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange rates updated successfully", "count": len(file.Rates)})
+}
+
+// convertPrices fills in ConvertedPrice on the products when the request asks for
+// a ?currency=, writing an error response and returning false if it can't
+func convertPrices(c *gin.Context, products []models.Product) bool {
+	currency := strings.ToUpper(c.Query("currency"))
+	if currency == "" {
+		return true
+	}
+	if !money.ValidCurrency(currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return false
+	}
+
+	table, err := database.LoadRateTable(database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+
+	for i := range products {
+		product := &products[i]
+		rate, err := table.Rate(product.Price.Currency, currency)
+		if errors.Is(err, money.ErrNoRate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No exchange rate from " + product.Price.Currency + " to " + currency})
+			return false
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		converted, err := product.Price.Convert(currency, rate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		product.ConvertedPrice = &converted
+	}
+	return true
+}
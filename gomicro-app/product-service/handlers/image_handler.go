@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/product-service/database"
+	"github.com/ozturkeniss/gomicro-app/product-service/imaging"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"github.com/ozturkeniss/gomicro-app/product-service/storage"
+	"gorm.io/gorm"
+)
+
+// defaultImageMaxBytes limits image uploads when IMAGE_MAX_BYTES is not set
+const defaultImageMaxBytes = 10 << 20
+
+// defaultThumbnailSizes are the longest sides of the thumbnails made for every image
+var defaultThumbnailSizes = []int{160, 480}
+
+var errImageOrder = errors.New("image_ids must list every image of the product exactly once")
+
+// imageExtensions maps decoded formats to file extensions for blob keys
+var imageExtensions = map[string]string{"jpeg": "jpg", "png": "png", "gif": "gif"}
+
+// ListImages lists a product's gallery in order
+func ListImages(c *gin.Context) {
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+
+	var images []models.ProductImage
+	result := database.Scoped(c.Request.Context()).Where("product_id = ?", product.ID).Order("position, created_at").Find(&images)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	for i := range images {
+		images[i].ResolveURLs(storage.Store.URL)
+	}
+
+	c.JSON(http.StatusOK, images)
+}
+
+// UploadImage stores an uploaded image and its thumbnails and appends it to the
+// product's gallery. The image is the file field of a multipart form, with an
+// optional alt_text field.
+func UploadImage(c *gin.Context) {
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, imageMaxBytes()+1<<20)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the image in a file field"})
+		return
+	}
+	defer file.Close()
+	if header.Size > imageMaxBytes() {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large"})
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	altText := strings.TrimSpace(c.PostForm("alt_text"))
+	if len(altText) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Alt text must be at most 255 characters"})
+		return
+	}
+
+	img, format, err := imaging.Decode(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	image := models.ProductImage{
+		ID:          uuid.New(),
+		ProductID:   product.ID,
+		ContentType: imaging.ContentType(format),
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Size:        int64(len(data)),
+		AltText:     altText,
+	}
+	prefix := fmt.Sprintf("%s/products/%s/%s/", product.TenantID, product.ID, image.ID)
+	image.Key = prefix + "original." + imageExtensions[format]
+
+	// Store the original and its thumbnails before the row, removing them again if anything fails
+	ctx := c.Request.Context()
+	if err := storage.Store.Put(ctx, image.Key, bytes.NewReader(data), int64(len(data)), image.ContentType); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to store image: " + err.Error()})
+		return
+	}
+	for _, size := range thumbnailSizes() {
+		thumbnail := imaging.Fit(img, size)
+		encoded, thumbnailFormat, err := imaging.Encode(thumbnail)
+		if err == nil {
+			key := prefix + strconv.Itoa(size) + "." + imageExtensions[thumbnailFormat]
+			err = storage.Store.Put(ctx, key, bytes.NewReader(encoded), int64(len(encoded)), imaging.ContentType(thumbnailFormat))
+			image.Renditions = append(image.Renditions, models.Rendition{
+				Size: size, Key: key, Width: thumbnail.Bounds().Dx(), Height: thumbnail.Bounds().Dy(),
+			})
+		}
+		if err != nil {
+			deleteImageBlobs(&image)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to store thumbnail: " + err.Error()})
+			return
+		}
+	}
+
+	err = database.Scoped(ctx).Transaction(func(tx *gorm.DB) error {
+		var last struct{ Position *int }
+		if err := tx.Model(&models.ProductImage{}).Select("MAX(position) AS position").Where("product_id = ?", product.ID).Scan(&last).Error; err != nil {
+			return err
+		}
+		if last.Position != nil {
+			image.Position = *last.Position + 1
+		}
+		return tx.Create(&image).Error
+	})
+	if err != nil {
+		deleteImageBlobs(&image)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	image.ResolveURLs(storage.Store.URL)
+	c.JSON(http.StatusCreated, image)
+}
+
+// UpdateImage changes an image's alt text
+func UpdateImage(c *gin.Context) {
+	var req struct {
+		AltText string `json:"alt_text" binding:"max=255"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	image, ok := loadImage(c)
+	if !ok {
+		return
+	}
+	image.AltText = strings.TrimSpace(req.AltText)
+	if result := database.Scoped(c.Request.Context()).Save(image); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	image.ResolveURLs(storage.Store.URL)
+	c.JSON(http.StatusOK, image)
+}
+
+// ReorderImages sets the gallery order from image_ids, which lists every image of
+// the product, first image first
+func ReorderImages(c *gin.Context) {
+	var req struct {
+		ImageIDs []uuid.UUID `json:"image_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+
+	var images []models.ProductImage
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", product.ID).Find(&images).Error; err != nil {
+			return err
+		}
+		positions := make(map[uuid.UUID]int, len(req.ImageIDs))
+		for i, id := range req.ImageIDs {
+			positions[id] = i
+		}
+		if len(positions) != len(req.ImageIDs) || len(positions) != len(images) {
+			return errImageOrder
+		}
+		for i := range images {
+			position, ok := positions[images[i].ID]
+			if !ok {
+				return errImageOrder
+			}
+			images[i].Position = position
+			if err := tx.Model(&images[i]).Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errImageOrder):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sort.Slice(images, func(i, j int) bool { return images[i].Position < images[j].Position })
+	for i := range images {
+		images[i].ResolveURLs(storage.Store.URL)
+	}
+	c.JSON(http.StatusOK, images)
+}
+
+// DeleteImage removes an image from the gallery and deletes its files
+func DeleteImage(c *gin.Context) {
+	image, ok := loadImage(c)
+	if !ok {
+		return
+	}
+
+	if result := database.Scoped(c.Request.Context()).Delete(image); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	deleteImageBlobs(image)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
+}
+
+// loadImage loads the image named in the URL, checking it belongs to the product
+func loadImage(c *gin.Context) (*models.ProductImage, bool) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return nil, false
+	}
+	imageID, err := uuid.Parse(c.Param("imageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return nil, false
+	}
+
+	var image models.ProductImage
+	result := database.Scoped(c.Request.Context()).First(&image, "id = ? AND product_id = ?", imageID, productID)
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return nil, false
+	}
+	return &image, true
+}
+
+// preloadImages loads each product's gallery in order along with the products
+func preloadImages(db *gorm.DB) *gorm.DB {
+	return db.Preload("Images", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, created_at")
+	})
+}
+
+// resolveImageURLs fills in the URLs of the products' preloaded images
+func resolveImageURLs(products []models.Product) {
+	for i := range products {
+		for j := range products[i].Images {
+			products[i].Images[j].ResolveURLs(storage.Store.URL)
+		}
+	}
+}
+
+// deleteImageBlobs removes an image's files from storage. Failures are only
+// logged; at worst they leave unreferenced files behind.
+func deleteImageBlobs(image *models.ProductImage) {
+	for _, key := range image.Keys() {
+		if err := storage.Store.Delete(context.Background(), key); err != nil {
+			log.Printf("Failed to delete image blob %s: %v", key, err)
+		}
+	}
+}
+
+// imageMaxBytes is the upload limit from IMAGE_MAX_BYTES
+func imageMaxBytes() int64 {
+	if limit, err := strconv.ParseInt(os.Getenv("IMAGE_MAX_BYTES"), 10, 64); err == nil && limit > 0 {
+		return limit
+	}
+	return defaultImageMaxBytes
+}
+
+// thumbnailSizes reads the comma-separated sizes in THUMBNAIL_SIZES
+func thumbnailSizes() []int {
+	var sizes []int
+	for _, field := range strings.Split(os.Getenv("THUMBNAIL_SIZES"), ",") {
+		if size, err := strconv.Atoi(strings.TrimSpace(field)); err == nil && size > 0 && size <= 4096 {
+			sizes = append(sizes, size)
+		}
+	}
+	if len(sizes) == 0 {
+		return defaultThumbnailSizes
+	}
+	return sizes
+}
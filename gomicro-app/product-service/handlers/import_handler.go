@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/tenant"
+	"github.com/ozturkeniss/gomicro-app/product-service/database"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"gorm.io/gorm"
+)
+
+// defaultImportMaxBytes limits uploads when IMPORT_MAX_BYTES is not set
+const defaultImportMaxBytes = 100 << 20
+
+// exportBatchSize is how many products an export loads at a time
+const exportBatchSize = 500
+
+// ImportProducts starts a background import of the uploaded catalog. The body is
+// the CSV or NDJSON file itself, or a multipart form with it in the file field.
+// It is spooled to disk first, then applied row by row while the job is polled.
+func ImportProducts(c *gin.Context) {
+	format := importFormat(c)
+	if format != models.FormatCSV && format != models.FormatNDJSON {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be csv or ndjson"})
+		return
+	}
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, importMaxBytes())
+	upload := io.Reader(c.Request.Body)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, _, err := c.Request.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Multipart uploads need a file field"})
+			return
+		}
+		defer file.Close()
+		upload = file
+	}
+
+	spool, err := os.CreateTemp(os.Getenv("IMPORT_DIR"), "product-import-*")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := io.Copy(spool, upload); err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Upload is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job := models.ImportJob{
+		ID:     uuid.New(),
+		UserID: c.GetString("userID"),
+		Format: format,
+		DryRun: dryRun,
+		Status: models.ImportPending,
+	}
+	if result := database.Scoped(c.Request.Context()).Create(&job); result.Error != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	// The job outlives the request, so it gets its own context carrying only the tenant
+	tenantID, _ := tenant.FromContext(c.Request.Context())
+	running := job
+	go func() {
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		runImport(tenant.WithTenant(context.Background(), tenantID), &running, spool)
+	}()
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetImportJob reports the progress of an import and the rows it rejected
+func GetImportJob(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import job ID"})
+		return
+	}
+
+	var job models.ImportJob
+	if result := database.Scoped(c.Request.Context()).First(&job, "id = ?", jobID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// ExportProducts streams the tenant's catalog in the import format, so an export
+// can be edited and imported again
+func ExportProducts(c *gin.Context) {
+	format := c.DefaultQuery("format", models.FormatCSV)
+	var (
+		write func(*models.Product) error
+		flush func() error
+	)
+	switch format {
+	case models.FormatCSV:
+		writer := csv.NewWriter(c.Writer)
+		write = func(product *models.Product) error {
+			return writer.Write(exportRecord(product))
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="products.csv"`)
+		c.Status(http.StatusOK)
+		if err := writer.Write(importColumns); err != nil {
+			return
+		}
+	case models.FormatNDJSON:
+		encoder := json.NewEncoder(c.Writer)
+		write = func(product *models.Product) error {
+			return encoder.Encode(exportRow(product))
+		}
+		flush = func() error { return nil }
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="products.ndjson"`)
+		c.Status(http.StatusOK)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be csv or ndjson"})
+		return
+	}
+
+	var products []models.Product
+	result := database.Scoped(c.Request.Context()).Preload("Categories").FindInBatches(&products, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		for i := range products {
+			if err := write(&products[i]); err != nil {
+				return err
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if result.Error != nil {
+		// The status line is already sent, so all we can do is cut the stream short
+		log.Printf("Product export failed: %v", result.Error)
+	}
+}
+
+// importFormat reads the format from the query, falling back to the content type
+func importFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return strings.ToLower(format)
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return models.FormatCSV
+	case "application/x-ndjson", "application/jsonl":
+		return models.FormatNDJSON
+	}
+	return ""
+}
+
+// importMaxBytes is the upload limit from IMPORT_MAX_BYTES
+func importMaxBytes() int64 {
+	if limit, err := strconv.ParseInt(os.Getenv("IMPORT_MAX_BYTES"), 10, 64); err == nil && limit > 0 {
+		return limit
+	}
+	return defaultImportMaxBytes
+}
+
+// exportRow converts a product to its NDJSON row
+func exportRow(product *models.Product) ndjsonRow {
+	categories := make([]string, len(product.Categories))
+	for i, category := range product.Categories {
+		categories[i] = category.Slug
+	}
+	return ndjsonRow{
+		SKU:         product.SKU,
+		Name:        product.Name,
+		Description: &product.Description,
+		Price:       json.Number(product.Price.AmountString()),
+		Currency:    product.Price.Currency,
+		Stock:       &product.Stock,
+		Categories:  categories,
+		Attributes:  product.Attributes,
+	}
+}
+
+// exportRecord converts a product to its CSV record, in importColumns order
+func exportRecord(product *models.Product) []string {
+	row := exportRow(product)
+	attributes := ""
+	if len(row.Attributes) > 0 {
+		encoded, _ := json.Marshal(row.Attributes)
+		attributes = string(encoded)
+	}
+	return []string{
+		row.SKU,
+		row.Name,
+		*row.Description,
+		string(row.Price),
+		row.Currency,
+		strconv.Itoa(*row.Stock),
+		strings.Join(row.Categories, "|"),
+		attributes,
+	}
+}
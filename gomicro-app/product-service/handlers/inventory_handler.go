@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/tenant"
+	"github.com/ozturkeniss/gomicro-app/product-service/alerts"
+	"github.com/ozturkeniss/gomicro-app/product-service/database"
+	"github.com/ozturkeniss/gomicro-app/product-service/inventory"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errStockTracked       = errors.New("stock is managed per warehouse")
+	errAllocationNotFound = errors.New("order has no stock allocation")
+)
+
+// GetAvailability reports a product's stock in every warehouse and its
+// available-to-promise total across active warehouses
+func GetAvailability(c *gin.Context) {
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+
+	var levels []models.StockLevel
+	result := database.Scoped(c.Request.Context()).Preload("Warehouse").
+		Where("product_id = ?", product.ID).Order("variant_id, warehouse_id").Find(&levels)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	for i := range levels {
+		levels[i].ResolveAvailable()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"product_id": product.ID,
+		"tracked":    len(levels) > 0,
+		"available":  product.Stock,
+		"levels":     levels,
+	})
+}
+
+// AllocateStock reserves stock for an order in the warehouse chosen by the
+// allocation strategy (internal only). Allocating an order again returns its
+// existing allocation. It responds 204 when the item's stock isn't kept per
+// warehouse, leaving the order to the product's own stock count.
+func AllocateStock(c *gin.Context) {
+	var req struct {
+		TenantID  uuid.UUID  `json:"tenant_id" binding:"required"`
+		OrderID   uuid.UUID  `json:"order_id" binding:"required"`
+		ProductID uuid.UUID  `json:"product_id" binding:"required"`
+		VariantID *uuid.UUID `json:"variant_id"`
+		Quantity  int        `json:"quantity" binding:"required,min=1"`
+		Country   string     `json:"country"`
+		Region    string     `json:"region"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	strategyName, err := inventory.StrategyName()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	strategy, _ := inventory.Lookup(strategyName)
+
+	variantID := uuid.Nil
+	if req.VariantID != nil {
+		variantID = *req.VariantID
+	}
+	ctx := tenant.WithTenant(c.Request.Context(), req.TenantID)
+	var allocation *models.Allocation
+	err = database.Scoped(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.Allocation
+		if err := tx.Where("order_id = ?", req.OrderID).Take(&existing).Error; err == nil {
+			allocation = &existing
+			return nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var levels []models.StockLevel
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "stock_levels"}}).
+			Joins("Warehouse").
+			Where("stock_levels.product_id = ? AND stock_levels.variant_id = ?", req.ProductID, variantID).
+			Find(&levels).Error
+		if err != nil || len(levels) == 0 {
+			return err
+		}
+
+		var candidates []inventory.Candidate
+		byWarehouse := make(map[uuid.UUID]*models.StockLevel, len(levels))
+		for i, level := range levels {
+			if level.Warehouse == nil || !level.Warehouse.Active {
+				continue
+			}
+			byWarehouse[level.WarehouseID] = &levels[i]
+			candidates = append(candidates, inventory.Candidate{
+				WarehouseID: level.WarehouseID,
+				Code:        level.Warehouse.Code,
+				Country:     level.Warehouse.Country,
+				Region:      level.Warehouse.Region,
+				Priority:    level.Warehouse.Priority,
+				Available:   level.OnHand - level.Reserved,
+			})
+		}
+		chosen, err := inventory.Allocate(strategy, inventory.Request{
+			Quantity: req.Quantity,
+			Country:  strings.ToUpper(req.Country),
+			Region:   req.Region,
+		}, candidates)
+		if err != nil {
+			return err
+		}
+
+		level := byWarehouse[chosen.WarehouseID]
+		if err := tx.Model(level).Update("reserved", gorm.Expr("reserved + ?", req.Quantity)).Error; err != nil {
+			return err
+		}
+		allocation = &models.Allocation{
+			ID:          uuid.New(),
+			OrderID:     req.OrderID,
+			WarehouseID: chosen.WarehouseID,
+			ProductID:   req.ProductID,
+			VariantID:   variantID,
+			Quantity:    req.Quantity,
+			Strategy:    strategyName,
+			Status:      models.AllocationReserved,
+		}
+		if err := tx.Create(allocation).Error; err != nil {
+			return err
+		}
+		return syncItemStock(tx, req.ProductID, variantID)
+	})
+	if errors.Is(err, inventory.ErrInsufficientStock) {
+		c.JSON(http.StatusConflict, gin.H{"error": "No warehouse has enough stock for this order"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if allocation == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	c.JSON(http.StatusOK, allocation)
+}
+
+// CommitAllocation ships an order's reserved stock, taking it off hand (internal only)
+func CommitAllocation(c *gin.Context) {
+	finishAllocation(c, models.AllocationCommitted)
+}
+
+// ReleaseAllocation returns an order's reserved stock to the warehouse (internal only)
+func ReleaseAllocation(c *gin.Context) {
+	finishAllocation(c, models.AllocationReleased)
+}
+
+// finishAllocation moves a reserved allocation to status, updating the stock it
+// reserved. Allocations that are already finished are left as they are.
+func finishAllocation(c *gin.Context, status string) {
+	orderID, err := uuid.Parse(c.Param("orderId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var allocation models.Allocation
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", orderID).Take(&allocation).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errAllocationNotFound
+		}
+		if err != nil || allocation.Status != models.AllocationReserved {
+			return err
+		}
+
+		tx = tx.WithContext(tenant.WithTenant(c.Request.Context(), allocation.TenantID))
+		updates := map[string]interface{}{"reserved": gorm.Expr("reserved - ?", allocation.Quantity)}
+		if status == models.AllocationCommitted {
+			updates["on_hand"] = gorm.Expr("on_hand - ?", allocation.Quantity)
+		}
+		err = tx.Model(&models.StockLevel{}).
+			Where("warehouse_id = ? AND product_id = ? AND variant_id = ?", allocation.WarehouseID, allocation.ProductID, allocation.VariantID).
+			Updates(updates).Error
+		if err != nil {
+			return err
+		}
+		allocation.Status = status
+		if err := tx.Save(&allocation).Error; err != nil {
+			return err
+		}
+		return syncItemStock(tx, allocation.ProductID, allocation.VariantID)
+	})
+	if errors.Is(err, errAllocationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, allocation)
+}
+
+// stockTracked reports whether the stock of a product, or of one of its variants,
+// is kept per warehouse
+func stockTracked(tx *gorm.DB, productID, variantID uuid.UUID) (bool, error) {
+	var levels int64
+	err := tx.Model(&models.StockLevel{}).Where("product_id = ? AND variant_id = ?", productID, variantID).Count(&levels).Error
+	return levels > 0, err
+}
+
+// syncItemStock sets the stock of a product or variant kept per warehouse to its
+// available-to-promise total across active warehouses. Variants then roll up into
+// their product's stock.
+func syncItemStock(tx *gorm.DB, productID, variantID uuid.UUID) error {
+	tracked, err := stockTracked(tx, productID, variantID)
+	if err != nil || !tracked {
+		return err
+	}
+
+	var available int64
+	err = tx.Model(&models.StockLevel{}).
+		Joins("JOIN warehouses ON warehouses.id = stock_levels.warehouse_id AND warehouses.active").
+		Where("stock_levels.product_id = ? AND stock_levels.variant_id = ?", productID, variantID).
+		Select("COALESCE(SUM(stock_levels.on_hand - stock_levels.reserved), 0)").
+		Scan(&available).Error
+	if err != nil {
+		return err
+	}
+
+	if variantID == uuid.Nil {
+		return alerts.Track(tx, productID, func() error {
+			return tx.Model(&models.Product{}).Where("id = ?", productID).Update("stock", available).Error
+		})
+	}
+	if err := tx.Model(&models.Variant{}).Where("id = ?", variantID).Update("stock", available).Error; err != nil {
+		return err
+	}
+	return syncProductStock(tx, productID)
+}
+
+// syncWarehouseStock resyncs every item stocked in a warehouse, after the
+// warehouse is activated or deactivated
+func syncWarehouseStock(tx *gorm.DB, warehouseID uuid.UUID) error {
+	var levels []models.StockLevel
+	if err := tx.Where("warehouse_id = ?", warehouseID).Find(&levels).Error; err != nil {
+		return err
+	}
+	for _, level := range levels {
+		if err := syncItemStock(tx, level.ProductID, level.VariantID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/common/paging"
+	"github.com/ozturkeniss/gomicro-app/product-service/database"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"github.com/ozturkeniss/gomicro-app/product-service/pricing"
+	"gorm.io/gorm"
+)
+
+// priceChangeSort lists the fields price history can be sorted by
+var priceChangeSort = paging.Sort{
+	Fields:  map[string]string{"effective_at": "effective_at"},
+	Default: "-effective_at",
+}
+
+// scheduledPriceSort lists the fields scheduled prices can be sorted by
+var scheduledPriceSort = paging.Sort{
+	Fields:  map[string]string{"effective_at": "effective_at", "created_at": "created_at"},
+	Default: "effective_at",
+}
+
+// GetPriceAt returns the product's price at the instant given by `at` (RFC 3339,
+// default now). Instants still to come include pending scheduled prices.
+func GetPriceAt(c *gin.Context) {
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+
+	at := time.Now()
+	if value := c.Query("at"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid at; use RFC 3339, e.g. 2024-01-02T15:04:05Z"})
+			return
+		}
+		at = parsed
+	}
+
+	change, err := pricing.At(database.Scoped(c.Request.Context()), product.ID, at)
+	if errors.Is(err, pricing.ErrNoPrice) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product had no price at that time"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"product_id":         product.ID,
+		"at":                 at,
+		"price":              change.Price,
+		"effective_at":       change.EffectiveAt,
+		"source":             change.Source,
+		"scheduled_price_id": change.ScheduledPriceID,
+	})
+}
+
+// ListPriceHistory retrieves a page of the product's price changes, newest first
+func ListPriceHistory(c *gin.Context) {
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+	req, err := paging.FromRequest(c, priceChangeSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.Scoped(c.Request.Context()).Model(&models.PriceChange{}).Where("product_id = ?", product.ID)
+	var total int64
+	if result := query.Session(&gorm.Session{}).Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	var changes []models.PriceChange
+	if result := req.Apply(query.Session(&gorm.Session{})).Find(&changes); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	page, err := paging.NewPage(database.DB, req, changes, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// ListScheduledPrices retrieves a page of the product's scheduled prices,
+// optionally filtered by status
+func ListScheduledPrices(c *gin.Context) {
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+	req, err := paging.FromRequest(c, scheduledPriceSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.Scoped(c.Request.Context()).Model(&models.ScheduledPrice{}).Where("product_id = ?", product.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if result := query.Session(&gorm.Session{}).Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	var schedules []models.ScheduledPrice
+	if result := req.Apply(query.Session(&gorm.Session{})).Find(&schedules); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	page, err := paging.NewPage(database.DB, req, schedules, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// CreateScheduledPrice schedules a new price for the product. The price must be
+// in the product's currency and take effect in the future.
+func CreateScheduledPrice(c *gin.Context) {
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Price       money.Money `json:"price" binding:"required"`
+		EffectiveAt time.Time   `json:"effective_at" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Price.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Price must be greater than zero"})
+		return
+	}
+	if err := req.Price.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Price.Currency != product.Price.Currency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Scheduled price must be in the product's currency"})
+		return
+	}
+	if !req.EffectiveAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Effective time must be in the future"})
+		return
+	}
+
+	schedule := models.ScheduledPrice{
+		ID:          uuid.New(),
+		ProductID:   product.ID,
+		Price:       req.Price,
+		EffectiveAt: req.EffectiveAt,
+		Status:      models.ScheduledPricePending,
+	}
+	if result := database.Scoped(c.Request.Context()).Create(&schedule); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// CancelScheduledPrice cancels a scheduled price that hasn't been applied yet
+func CancelScheduledPrice(c *gin.Context) {
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+	scheduleID, err := uuid.Parse(c.Param("scheduleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled price ID"})
+		return
+	}
+
+	var schedule models.ScheduledPrice
+	result := database.Scoped(c.Request.Context()).Model(&schedule).
+		Where("id = ? AND product_id = ? AND status = ?", scheduleID, product.ID, models.ScheduledPricePending).
+		Update("status", models.ScheduledPriceCancelled)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pending scheduled price not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scheduled price cancelled successfully"})
+}
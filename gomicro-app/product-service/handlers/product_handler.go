@@ -2,11 +2,16 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/paging"
+	"github.com/ozturkeniss/gomicro-app/product-service/alerts"
 	"github.com/ozturkeniss/gomicro-app/product-service/database"
 	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"github.com/ozturkeniss/gomicro-app/product-service/pricing"
+	"gorm.io/gorm"
 )
 
 // CreateProduct handles the creation of a new product
@@ -18,21 +23,57 @@ func CreateProduct(c *gin.Context) {
 	}
 
 	// Validate price and stock
-	if product.Price <= 0 {
+	if !product.Price.IsPositive() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Price must be greater than zero"})
 		return
 	}
+	if err := product.Price.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if product.Stock < 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Stock cannot be negative"})
 		return
 	}
+	if (product.ReorderPoint != nil && *product.ReorderPoint < 0) || product.ReorderQuantity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reorder point and quantity cannot be negative"})
+		return
+	}
+	if product.WeightGrams < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Weight cannot be negative"})
+		return
+	}
+	if !normalizeTaxClass(&product) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tax class must be at most 32 characters"})
+		return
+	}
+	if !validOptionAxes(product.Options) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Options must be distinct, non-empty axis names"})
+		return
+	}
+	if err := validateAttributes(database.Scoped(c.Request.Context()), product.Attributes); err != nil {
+		writeRequestError(c, err)
+		return
+	}
 
-	// Assign a new UUID to the product
+	// Assign a new UUID to the product; ratings start empty
 	product.ID = uuid.New()
+	product.RatingAverage, product.RatingCount = 0, 0
+	if !checkProductSKU(c, &product) {
+		return
+	}
 
-	result := database.DB.Create(&product)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		err := alerts.Track(tx, product.ID, func() error {
+			return tx.Omit("Categories", "Variants", "Images").Create(&product).Error
+		})
+		if err != nil {
+			return err
+		}
+		return pricing.Record(tx, &product, models.PriceSourceManual, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -49,13 +90,21 @@ func GetProduct(c *gin.Context) {
 	}
 
 	var product models.Product
-	result := database.DB.First(&product, "id = ?", productID)
+	result := preloadImages(database.Scoped(c.Request.Context())).Preload("Categories").Preload("Variants").First(&product, "id = ?", productID)
 	if result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
 	}
+	for i := range product.Variants {
+		product.Variants[i].ResolvePrice(product.Price)
+	}
+	products := []models.Product{product}
+	if !convertPrices(c, products) {
+		return
+	}
+	resolveImageURLs(products)
 
-	c.JSON(http.StatusOK, product)
+	c.JSON(http.StatusOK, products[0])
 }
 
 // UpdateProduct updates a product by ID
@@ -74,21 +123,86 @@ func UpdateProduct(c *gin.Context) {
 	}
 
 	// Validate price and stock
-	if product.Price <= 0 {
+	if !product.Price.IsPositive() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Price must be greater than zero"})
 		return
 	}
+	if err := product.Price.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if product.Stock < 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Stock cannot be negative"})
 		return
 	}
+	if (product.ReorderPoint != nil && *product.ReorderPoint < 0) || product.ReorderQuantity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reorder point and quantity cannot be negative"})
+		return
+	}
+	if product.WeightGrams < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Weight cannot be negative"})
+		return
+	}
+	if !normalizeTaxClass(&product) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tax class must be at most 32 characters"})
+		return
+	}
+	if !validOptionAxes(product.Options) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Options must be distinct, non-empty axis names"})
+		return
+	}
+	if err := validateAttributes(database.Scoped(c.Request.Context()), product.Attributes); err != nil {
+		writeRequestError(c, err)
+		return
+	}
 
-	product.ID = productID
-	result := database.DB.Save(&product)
-	if result.Error != nil {
+	// Make sure the product exists for this tenant before overwriting it
+	var existing models.Product
+	if result := database.Scoped(c.Request.Context()).First(&existing, "id = ?", productID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	// Products with variants keep their stock as the sum of the variants' stock,
+	// and their option axes can't change while variants depend on them. Stock kept
+	// per warehouse can't be overwritten either.
+	var variants int64
+	if result := database.Scoped(c.Request.Context()).Model(&models.Variant{}).Where("product_id = ?", productID).Count(&variants); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
 	}
+	if variants > 0 {
+		if !sameOptionAxes(existing.Options, product.Options) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Option axes can't change while the product has variants"})
+			return
+		}
+		product.Stock = existing.Stock
+	} else if tracked, err := stockTracked(database.Scoped(c.Request.Context()), productID, uuid.Nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if tracked {
+		product.Stock = existing.Stock
+	}
+
+	// Ratings are maintained from reviews and can't be set directly
+	product.ID = productID
+	product.RatingAverage, product.RatingCount = existing.RatingAverage, existing.RatingCount
+	if !checkProductSKU(c, &product) {
+		return
+	}
+	err = database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		err := alerts.Track(tx, productID, func() error {
+			return tx.Omit("Categories", "Variants", "Images", "RatingAverage", "RatingCount").Save(&product).Error
+		})
+		if err != nil {
+			return err
+		}
+		return pricing.Record(tx, &product, models.PriceSourceManual, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, product)
 }
@@ -102,7 +216,7 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	result := database.DB.Delete(&models.Product{}, "id = ?", productID)
+	result := database.Scoped(c.Request.Context()).Delete(&models.Product{}, "id = ?", productID)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
@@ -111,52 +225,116 @@ func DeleteProduct(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
 }
 
-// ListProducts retrieves a list of products
+// ListProducts retrieves a page of products
 func ListProducts(c *gin.Context) {
-	var products []models.Product
-	result := database.DB.Find(&products)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	req, err := paging.FromRequest(c, productSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, products)
-}
+	query := database.Scoped(c.Request.Context()).Model(&models.Product{})
+	page, ok := findProductPage(c, req, query, query)
+	if !ok {
+		return
+	}
 
-// SearchProducts searches products by name, description, price range, and stock status
-func SearchProducts(c *gin.Context) {
-	name := c.Query("name")
-	description := c.Query("description")
-	minPrice := c.Query("minPrice")
-	maxPrice := c.Query("maxPrice")
-	inStock := c.Query("inStock")
+	c.JSON(http.StatusOK, page)
+}
 
-	query := database.DB.Model(&models.Product{})
+// ListLowStockProducts retrieves a page of products at or below their reorder
+// point, lowest stock first
+func ListLowStockProducts(c *gin.Context) {
+	req, err := paging.FromRequest(c, lowStockSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if name != "" {
-		query = query.Where("name LIKE ?", "%"+name+"%")
+	query := database.Scoped(c.Request.Context()).Model(&models.Product{}).
+		Where("reorder_point IS NOT NULL AND stock <= reorder_point")
+	page, ok := findProductPage(c, req, query, query)
+	if !ok {
+		return
 	}
-	if description != "" {
-		query = query.Where("description LIKE ?", "%"+description+"%")
+
+	c.JSON(http.StatusOK, page)
+}
+
+// lowStockSort is productSort ordered by stock by default
+var lowStockSort = paging.Sort{Fields: productSort.Fields, Default: "stock"}
+
+// productSort lists the fields product lists can be sorted by
+var productSort = paging.Sort{
+	Fields: map[string]string{
+		"name":       "name",
+		"price":      "price_amount",
+		"stock":      "stock",
+		"created_at": "created_at",
+		"rating":     "rating_average",
+	},
+	Default: "-created_at",
+}
+
+// findProductPage counts the products matched by filtered and loads the requested
+// page from selected, which may add computed columns to filtered. It writes an
+// error response and returns false if it can't.
+func findProductPage(c *gin.Context, req *paging.Request, filtered, selected *gorm.DB) (*paging.Page[models.Product], bool) {
+	var total int64
+	if result := filtered.Session(&gorm.Session{}).Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return nil, false
 	}
-	if minPrice != "" {
-		query = query.Where("price >= ?", minPrice)
+
+	var products []models.Product
+	if result := preloadImages(req.Apply(selected.Session(&gorm.Session{}))).Find(&products); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return nil, false
 	}
-	if maxPrice != "" {
-		query = query.Where("price <= ?", maxPrice)
+	if !convertPrices(c, products) {
+		return nil, false
 	}
-	if inStock == "true" {
-		query = query.Where("stock > 0")
+	resolveImageURLs(products)
+
+	page, err := paging.NewPage(database.DB, req, products, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
 	}
+	return page, true
+}
 
-	var products []models.Product
-	result := query.Find(&products)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
-		return
+// normalizeTaxClass trims and lower-cases the product's tax class, defaulting
+// it to models.DefaultTaxClass, and reports whether it fits
+func normalizeTaxClass(product *models.Product) bool {
+	product.TaxClass = strings.ToLower(strings.TrimSpace(product.TaxClass))
+	if product.TaxClass == "" {
+		product.TaxClass = models.DefaultTaxClass
 	}
+	return len(product.TaxClass) <= 32
+}
 
-	c.JSON(http.StatusOK, products)
+// checkProductSKU trims the product's optional SKU and makes sure no other product
+// or variant uses it. It writes an error response and returns false if it can't.
+func checkProductSKU(c *gin.Context, product *models.Product) bool {
+	product.SKU = strings.TrimSpace(product.SKU)
+	if product.SKU == "" {
+		return true
+	}
+	if len(product.SKU) > 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SKU must be at most 64 characters"})
+		return false
+	}
+	taken, err := skuInUse(database.Scoped(c.Request.Context()), product.SKU, product.ID, uuid.Nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": errSKUTaken.Error()})
+		return false
+	}
+	return true
 }
 
 // UpdateStock updates the stock of a product
@@ -177,7 +355,7 @@ func UpdateStock(c *gin.Context) {
 	}
 
 	var product models.Product
-	result := database.DB.First(&product, "id = ?", productID)
+	result := database.Scoped(c.Request.Context()).First(&product, "id = ?", productID)
 	if result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
@@ -189,12 +367,34 @@ func UpdateStock(c *gin.Context) {
 		return
 	}
 
-	product.Stock = stockUpdate.Stock
-	result = database.DB.Save(&product)
-	if result.Error != nil {
+	// Stock of products with variants is kept per variant
+	var variants int64
+	if result := database.Scoped(c.Request.Context()).Model(&models.Variant{}).Where("product_id = ?", productID).Count(&variants); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
 	}
+	if variants > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Product has variants; update stock per variant"})
+		return
+	}
+	if tracked, err := stockTracked(database.Scoped(c.Request.Context()), productID, uuid.Nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if tracked {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stock is managed per warehouse; set it in each warehouse"})
+		return
+	}
+
+	product.Stock = stockUpdate.Stock
+	err = database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		return alerts.Track(tx, productID, func() error {
+			return tx.Model(&product).Update("stock", product.Stock).Error
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, product)
 } 
\ No newline at end of file
@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/product-service/alerts"
+	"github.com/ozturkeniss/gomicro-app/product-service/database"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"github.com/ozturkeniss/gomicro-app/product-service/pricing"
+	"gorm.io/gorm"
+)
+
+// importColumns are the CSV columns of imports and exports, in export order.
+// Categories are slugs separated by "|" and attributes are a JSON object.
+var importColumns = []string{"sku", "name", "description", "price", "currency", "stock", "categories", "attributes"}
+
+// importProgressEvery is how many rows are processed between progress saves
+const importProgressEvery = 100
+
+// importRow is one product read from an import. Optional fields are nil when the
+// row leaves them out or, in CSV, leaves their cell empty, so updates keep the
+// product's current value.
+type importRow struct {
+	SKU         string
+	Name        string
+	Description *string
+	Price       string
+	Currency    string
+	Stock       *int
+	Categories  []string
+	Attributes  models.Attributes
+}
+
+// rowReader reads the rows of an import one at a time. Next returns io.EOF after
+// the last row, a validationError for a row that can't be parsed and any other
+// error when the rest of the input can't be read.
+type rowReader interface {
+	Next() (*importRow, error)
+}
+
+// newRowReader returns a reader for the format
+func newRowReader(format string, r io.Reader) (rowReader, error) {
+	switch format {
+	case models.FormatCSV:
+		return newCSVRowReader(r)
+	case models.FormatNDJSON:
+		return &ndjsonRowReader{reader: bufio.NewReader(r)}, nil
+	}
+	return nil, validationError("Format must be csv or ndjson")
+}
+
+// csvRowReader reads rows from CSV with a header line naming the columns
+type csvRowReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVRowReader(r io.Reader) (*csvRowReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, validationError("CSV input is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !containsString(importColumns, name) {
+			return nil, validationError("Unknown CSV column " + name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, validationError("Duplicate CSV column " + name)
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"sku", "name", "price"} {
+		if _, ok := columns[name]; !ok {
+			return nil, validationError("CSV header is missing the " + name + " column")
+		}
+	}
+	return &csvRowReader{reader: reader, columns: columns}, nil
+}
+
+// Next implements rowReader
+func (r *csvRowReader) Next() (*importRow, error) {
+	record, err := r.reader.Read()
+	var malformed *csv.ParseError
+	if errors.As(err, &malformed) {
+		return &importRow{}, validationError("Invalid CSV: " + malformed.Err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	field := func(name string) (string, bool) {
+		i, ok := r.columns[name]
+		if !ok || i >= len(record) {
+			return "", false
+		}
+		value := strings.TrimSpace(record[i])
+		return value, value != ""
+	}
+
+	row := &importRow{}
+	row.SKU, _ = field("sku")
+	row.Name, _ = field("name")
+	row.Price, _ = field("price")
+	row.Currency, _ = field("currency")
+	if value, ok := field("description"); ok {
+		row.Description = &value
+	}
+	if value, ok := field("stock"); ok {
+		stock, err := strconv.Atoi(value)
+		if err != nil {
+			return row, validationError("Stock must be a whole number")
+		}
+		row.Stock = &stock
+	}
+	if value, ok := field("categories"); ok {
+		row.Categories = strings.Split(value, "|")
+	}
+	if value, ok := field("attributes"); ok {
+		if err := json.Unmarshal([]byte(value), &row.Attributes); err != nil {
+			return row, validationError("Attributes must be a JSON object")
+		}
+	}
+	return row, nil
+}
+
+// ndjsonRowReader reads rows from JSON objects, one per line
+type ndjsonRowReader struct {
+	reader *bufio.Reader
+}
+
+// ndjsonRow is the JSON form of an import row; prices may be strings or numbers
+type ndjsonRow struct {
+	SKU         string            `json:"sku"`
+	Name        string            `json:"name"`
+	Description *string           `json:"description"`
+	Price       json.Number       `json:"price"`
+	Currency    string            `json:"currency"`
+	Stock       *int              `json:"stock"`
+	Categories  []string          `json:"categories"`
+	Attributes  models.Attributes `json:"attributes"`
+}
+
+// Next implements rowReader; blank lines are skipped
+func (r *ndjsonRowReader) Next() (*importRow, error) {
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		var decoded ndjsonRow
+		if err := json.Unmarshal(line, &decoded); err != nil {
+			return &importRow{}, validationError("Invalid JSON: " + err.Error())
+		}
+		return &importRow{
+			SKU:         strings.TrimSpace(decoded.SKU),
+			Name:        strings.TrimSpace(decoded.Name),
+			Description: decoded.Description,
+			Price:       decoded.Price.String(),
+			Currency:    strings.TrimSpace(decoded.Currency),
+			Stock:       decoded.Stock,
+			Categories:  decoded.Categories,
+			Attributes:  decoded.Attributes,
+		}, nil
+	}
+}
+
+// productImporter upserts rows into the catalog of one tenant, caching the
+// lookups every row needs
+type productImporter struct {
+	db          *gorm.DB
+	dryRun      bool
+	definitions map[string]models.AttributeDefinition
+	categories  map[string]uuid.UUID
+	seen        map[string]int
+}
+
+// apply validates the row and creates or updates the product with its SKU. It
+// reports whether the product was created. In a dry run nothing is written.
+func (p *productImporter) apply(line int, row *importRow) (bool, error) {
+	if row.SKU == "" {
+		return false, validationError("SKU is required")
+	}
+	if len(row.SKU) > 64 {
+		return false, validationError("SKU must be at most 64 characters")
+	}
+	if first, ok := p.seen[row.SKU]; ok {
+		return false, validationError(fmt.Sprintf("SKU already imported on row %d", first))
+	}
+	if row.Name == "" {
+		return false, validationError("Name is required")
+	}
+	if row.Currency == "" {
+		row.Currency = money.DefaultCurrency()
+	}
+	price, err := money.New(row.Price, strings.ToUpper(row.Currency))
+	if err != nil {
+		return false, validationError("Invalid price: " + err.Error())
+	}
+	if !price.IsPositive() {
+		return false, validationError("Price must be greater than zero")
+	}
+	if row.Stock != nil && *row.Stock < 0 {
+		return false, validationError("Stock cannot be negative")
+	}
+	categoryIDs, err := p.categoryIDs(row.Categories)
+	if err != nil {
+		return false, err
+	}
+
+	var product models.Product
+	created := false
+	err = p.db.Transaction(func(tx *gorm.DB) error {
+		switch err := tx.Where("sku = ?", row.SKU).First(&product).Error; {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			created = true
+			product = models.Product{ID: uuid.New(), SKU: row.SKU, TaxClass: models.DefaultTaxClass}
+		case err != nil:
+			return err
+		}
+
+		var variants int64
+		if !created {
+			if err := tx.Model(&models.Variant{}).Where("product_id = ?", product.ID).Count(&variants).Error; err != nil {
+				return err
+			}
+		}
+		if variants > 0 && row.Stock != nil && *row.Stock != product.Stock {
+			return validationError("Product has variants; stock is kept per variant")
+		}
+		if !created && row.Stock != nil && *row.Stock != product.Stock {
+			if tracked, err := stockTracked(tx, product.ID, uuid.Nil); err != nil {
+				return err
+			} else if tracked {
+				return validationError("Stock is managed per warehouse")
+			}
+		}
+		if taken, err := skuInUse(tx, row.SKU, product.ID, uuid.Nil); err != nil {
+			return err
+		} else if taken {
+			return errSKUTaken
+		}
+
+		product.Name = row.Name
+		product.Price = price
+		if row.Description != nil {
+			product.Description = *row.Description
+		}
+		if row.Stock != nil {
+			product.Stock = *row.Stock
+		}
+		if row.Attributes != nil {
+			product.Attributes = row.Attributes
+		}
+		if err := checkAttributes(p.definitions, product.Attributes); err != nil {
+			return err
+		}
+		if p.dryRun {
+			return nil
+		}
+
+		err := alerts.Track(tx, product.ID, func() error {
+			return tx.Omit("Categories", "Variants", "Images", "RatingAverage", "RatingCount").Save(&product).Error
+		})
+		if err != nil {
+			return err
+		}
+		if err := pricing.Record(tx, &product, models.PriceSourceImport, nil); err != nil {
+			return err
+		}
+		if categoryIDs == nil {
+			return nil
+		}
+		categories := make([]models.Category, len(categoryIDs))
+		for i, id := range categoryIDs {
+			categories[i] = models.Category{ID: id}
+		}
+		return tx.Model(&product).Omit("Categories.*").Association("Categories").Replace(categories)
+	})
+	if err != nil {
+		return false, err
+	}
+	p.seen[row.SKU] = line
+	return created, nil
+}
+
+// categoryIDs resolves category slugs, keeping nil (leave unchanged) as nil
+func (p *productImporter) categoryIDs(slugs []string) ([]uuid.UUID, error) {
+	if slugs == nil {
+		return nil, nil
+	}
+	ids := make([]uuid.UUID, 0, len(slugs))
+	for _, slug := range slugs {
+		slug = strings.TrimSpace(slug)
+		if slug == "" {
+			continue
+		}
+		id, ok := p.categories[slug]
+		if !ok {
+			return nil, validationError("Unknown category " + slug)
+		}
+		ids = append(ids, id)
+	}
+	if unique := uniqueIDs(ids); unique != nil {
+		return unique, nil
+	}
+	return []uuid.UUID{}, nil
+}
+
+// runImport processes the rows in input for the job, saving progress as it goes.
+// It runs in the background with ctx carrying the job's tenant.
+func runImport(ctx context.Context, job *models.ImportJob, input io.Reader) {
+	db := database.Scoped(ctx)
+	started := time.Now()
+	job.Status = models.ImportRunning
+	job.StartedAt = &started
+	saveImportJob(db, job)
+
+	defer func() {
+		if r := recover(); r != nil {
+			finishImport(db, job, fmt.Errorf("import stopped: %v", r))
+		}
+	}()
+	finishImport(db, job, importRows(db, job, input))
+}
+
+// importRows reads and applies every row, returning an error if the input can't be read
+func importRows(db *gorm.DB, job *models.ImportJob, input io.Reader) error {
+	reader, err := newRowReader(job.Format, input)
+	if err != nil {
+		return err
+	}
+	definitions, err := loadDefinitions(db)
+	if err != nil {
+		return err
+	}
+	var categories []models.Category
+	if err := db.Select("id", "slug").Find(&categories).Error; err != nil {
+		return err
+	}
+	importer := &productImporter{
+		db:          db,
+		dryRun:      job.DryRun,
+		definitions: definitions,
+		categories:  make(map[string]uuid.UUID, len(categories)),
+		seen:        make(map[string]int),
+	}
+	for _, category := range categories {
+		importer.categories[category.Slug] = category.ID
+	}
+
+	var invalid validationError
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		job.Rows++
+		switch {
+		case errors.As(err, &invalid):
+			job.RecordRowError(job.Rows, row.SKU, err.Error())
+		case err != nil:
+			return fmt.Errorf("row %d: %w", job.Rows, err)
+		default:
+			created, err := importer.apply(job.Rows, row)
+			switch {
+			case errors.As(err, &invalid), errors.Is(err, errSKUTaken):
+				job.RecordRowError(job.Rows, row.SKU, err.Error())
+			case err != nil:
+				return fmt.Errorf("row %d: %w", job.Rows, err)
+			case created:
+				job.Created++
+			default:
+				job.Updated++
+			}
+		}
+		if job.Rows%importProgressEvery == 0 {
+			saveImportJob(db, job)
+		}
+	}
+}
+
+// finishImport records the outcome of the job
+func finishImport(db *gorm.DB, job *models.ImportJob, err error) {
+	finished := time.Now()
+	job.FinishedAt = &finished
+	job.Status = models.ImportCompleted
+	if err != nil {
+		job.Status = models.ImportFailed
+		job.Error = err.Error()
+		if len(job.Error) > 1000 {
+			job.Error = job.Error[:1000]
+		}
+	}
+	saveImportJob(db, job)
+}
+
+// saveImportJob stores the job's progress; failures are only logged so the import carries on
+func saveImportJob(db *gorm.DB, job *models.ImportJob) {
+	if err := db.Save(job).Error; err != nil {
+		log.Printf("Failed to save import job %s: %v", job.ID, err)
+	}
+}
+
+// FailInterruptedImports marks jobs left pending or running by a previous process
+// as failed; their uploads don't survive a restart
+func FailInterruptedImports() error {
+	return database.DB.Model(&models.ImportJob{}).
+		Where("status IN ?", []string{models.ImportPending, models.ImportRunning}).
+		Updates(map[string]interface{}{
+			"status":      models.ImportFailed,
+			"error":       "interrupted by a service restart",
+			"finished_at": time.Now(),
+		}).Error
+}
@@ -0,0 +1,464 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/paging"
+	"github.com/ozturkeniss/gomicro-app/common/tenant"
+	"github.com/ozturkeniss/gomicro-app/product-service/clients"
+	"github.com/ozturkeniss/gomicro-app/product-service/database"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reviewRequest is the body for writing and editing reviews
+type reviewRequest struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+	Title  string `json:"title" binding:"max=150"`
+	Body   string `json:"body" binding:"max=5000"`
+}
+
+// reviewSort lists the fields review lists can be sorted by
+var reviewSort = paging.Sort{
+	Fields: map[string]string{
+		"helpful":    "helpful_count",
+		"rating":     "rating",
+		"created_at": "created_at",
+	},
+	Default: "-helpful",
+}
+
+// ListReviews retrieves a page of a product's approved reviews, most helpful
+// first. Tenant owners and admins may list other moderation states with ?status.
+func ListReviews(c *gin.Context) {
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+
+	query := database.Scoped(c.Request.Context()).Model(&models.Review{}).Where("product_id = ?", product.ID)
+	listReviews(c, query, models.ReviewApproved)
+}
+
+// ListModerationQueue retrieves a page of reviews across all products, pending
+// ones by default, for tenant owners and admins
+func ListModerationQueue(c *gin.Context) {
+	if !tenant.IsManager(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only organization owners and admins can moderate reviews"})
+		return
+	}
+
+	query := database.Scoped(c.Request.Context()).Model(&models.Review{})
+	listReviews(c, query, models.ReviewPending)
+}
+
+// GetReview retrieves a review. Reviews awaiting or failing moderation are only
+// visible to their author and to moderators.
+func GetReview(c *gin.Context) {
+	review, ok := loadReview(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// CreateReview adds the caller's review of a product. Only users with a delivered
+// order of the product may review it; the review awaits moderation.
+func CreateReview(c *gin.Context) {
+	var req reviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+
+	var existing int64
+	if result := database.Scoped(c.Request.Context()).Model(&models.Review{}).Where("product_id = ? AND user_id = ?", product.ID, userID).Count(&existing); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reviewed this product"})
+		return
+	}
+
+	orderID, err := clients.FindDeliveredOrder(c.Request.Context(), product.TenantID, userID, product.ID)
+	if errors.Is(err, clients.ErrNoPurchase) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only customers who received this product can review it"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to verify purchase"})
+		return
+	}
+
+	review := models.Review{
+		ID:        uuid.New(),
+		ProductID: product.ID,
+		UserID:    userID,
+		OrderID:   orderID,
+		Rating:    req.Rating,
+		Title:     strings.TrimSpace(req.Title),
+		Body:      strings.TrimSpace(req.Body),
+		Status:    models.ReviewPending,
+	}
+	if result := database.Scoped(c.Request.Context()).Create(&review); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, review)
+}
+
+// UpdateReview lets the author edit their review, which sends it back to moderation
+func UpdateReview(c *gin.Context) {
+	var req reviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	review, ok := loadReview(c)
+	if !ok {
+		return
+	}
+	if review.UserID.String() != c.GetString("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can edit a review"})
+		return
+	}
+
+	review.Rating = req.Rating
+	review.Title = strings.TrimSpace(req.Title)
+	review.Body = strings.TrimSpace(req.Body)
+	review.Status = models.ReviewPending
+	review.ModerationNote, review.ModeratedBy, review.ModeratedAt = "", nil, nil
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(review).Error; err != nil {
+			return err
+		}
+		return syncProductRating(tx, review.ProductID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// DeleteReview removes a review; authors can delete their own, moderators any
+func DeleteReview(c *gin.Context) {
+	review, ok := loadReview(c)
+	if !ok {
+		return
+	}
+	if review.UserID.String() != c.GetString("userID") && !tenant.IsManager(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author or a moderator can delete a review"})
+		return
+	}
+
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("review_id = ?", review.ID).Delete(&models.ReviewVote{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(review).Error; err != nil {
+			return err
+		}
+		return syncProductRating(tx, review.ProductID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Review deleted successfully"})
+}
+
+// ModerateReview approves or rejects a review, or returns it to pending
+func ModerateReview(c *gin.Context) {
+	var req struct {
+		Status string `json:"status" binding:"required"`
+		Note   string `json:"note" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.ValidReviewStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be pending, approved or rejected"})
+		return
+	}
+	if !tenant.IsManager(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only organization owners and admins can moderate reviews"})
+		return
+	}
+	moderatorID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	review, ok := loadReview(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	review.Status = req.Status
+	review.ModerationNote = strings.TrimSpace(req.Note)
+	review.ModeratedBy, review.ModeratedAt = &moderatorID, &now
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(review).Error; err != nil {
+			return err
+		}
+		return syncProductRating(tx, review.ProductID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// VoteReview records whether the caller found an approved review helpful,
+// replacing any earlier vote of theirs
+func VoteReview(c *gin.Context) {
+	var req struct {
+		Helpful *bool `json:"helpful" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	review, ok := loadReview(c)
+	if !ok {
+		return
+	}
+	if review.Status != models.ReviewApproved {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only approved reviews can be voted on"})
+		return
+	}
+	if review.UserID == userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can't vote on your own review"})
+		return
+	}
+
+	vote := models.ReviewVote{ReviewID: review.ID, UserID: userID, Helpful: *req.Helpful}
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "review_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"helpful", "updated_at"}),
+		}).Create(&vote).Error
+		if err != nil {
+			return err
+		}
+		return syncHelpfulCounts(tx, review)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// UnvoteReview withdraws the caller's helpfulness vote on a review
+func UnvoteReview(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	review, ok := loadReview(c)
+	if !ok {
+		return
+	}
+
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("review_id = ? AND user_id = ?", review.ID, userID).Delete(&models.ReviewVote{}).Error; err != nil {
+			return err
+		}
+		return syncHelpfulCounts(tx, review)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// AnonymizeUserReviews clears the title and text of a user's reviews across all
+// tenants, keeping their ratings so product ratings don't change (internal only)
+func AnonymizeUserReviews(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	result := database.DB.Model(&models.Review{}).
+		Where("user_id = ? AND (title <> '' OR body <> '')", userID).
+		Updates(map[string]interface{}{"title": "", "body": ""})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"anonymized": result.RowsAffected})
+}
+
+// listReviews writes a page of the reviews matched by query. Callers see reviews
+// in defaultStatus; moderators may pick another with ?status.
+func listReviews(c *gin.Context, query *gorm.DB, defaultStatus string) {
+	req, err := paging.FromRequest(c, reviewSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := c.DefaultQuery("status", defaultStatus)
+	if !models.ValidReviewStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be pending, approved or rejected"})
+		return
+	}
+	if status != models.ReviewApproved && !tenant.IsManager(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only organization owners and admins can see unapproved reviews"})
+		return
+	}
+	query = query.Where("status = ?", status)
+	if value := c.Query("rating"); value != "" {
+		rating, err := strconv.Atoi(value)
+		if err != nil || rating < 1 || rating > 5 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Rating must be between 1 and 5"})
+			return
+		}
+		query = query.Where("rating = ?", rating)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	var reviews []models.Review
+	if result := req.Apply(query).Find(&reviews); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	page, err := paging.NewPage(database.DB, req, reviews, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// loadReview loads the review named in the URL, checking it belongs to the
+// product and is visible to the caller
+func loadReview(c *gin.Context) (*models.Review, bool) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return nil, false
+	}
+	reviewID, err := uuid.Parse(c.Param("reviewId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return nil, false
+	}
+
+	var review models.Review
+	result := database.Scoped(c.Request.Context()).First(&review, "id = ? AND product_id = ?", reviewID, productID)
+	visible := review.Status == models.ReviewApproved || review.UserID.String() == c.GetString("userID") || tenant.IsManager(c)
+	if result.Error != nil || !visible {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return nil, false
+	}
+	return &review, true
+}
+
+// currentUser returns the ID of the user making the request
+func currentUser(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token does not identify a user"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// syncProductRating sets a product's rating average and count from its approved
+// reviews. The product row is locked first so concurrent changes can't
+// overwrite each other's totals.
+func syncProductRating(tx *gorm.DB, productID uuid.UUID) error {
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&product, "id = ?", productID).Error; err != nil {
+		return err
+	}
+
+	var stats struct {
+		Average float64
+		Count   int
+	}
+	err := tx.Model(&models.Review{}).
+		Where("product_id = ? AND status = ?", productID, models.ReviewApproved).
+		Select("COALESCE(ROUND(AVG(rating), 2), 0) AS average, COUNT(*) AS count").
+		Scan(&stats).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&product).UpdateColumns(map[string]interface{}{
+		"rating_average": stats.Average,
+		"rating_count":   stats.Count,
+	}).Error
+}
+
+// syncHelpfulCounts recounts a review's helpfulness votes, locking the review so
+// concurrent votes are counted one after the other
+func syncHelpfulCounts(tx *gorm.DB, review *models.Review) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Review{}, "id = ?", review.ID).Error; err != nil {
+		return err
+	}
+
+	var counts struct {
+		Helpful    int
+		NotHelpful int
+	}
+	err := tx.Model(&models.ReviewVote{}).
+		Where("review_id = ?", review.ID).
+		Select("COUNT(*) FILTER (WHERE helpful) AS helpful, COUNT(*) FILTER (WHERE NOT helpful) AS not_helpful").
+		Scan(&counts).Error
+	if err != nil {
+		return err
+	}
+	review.HelpfulCount, review.NotHelpfulCount = counts.Helpful, counts.NotHelpful
+	return tx.Model(review).UpdateColumns(map[string]interface{}{
+		"helpful_count":     counts.Helpful,
+		"not_helpful_count": counts.NotHelpful,
+	}).Error
+}
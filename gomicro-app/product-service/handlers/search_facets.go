@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultPriceBuckets are the price bucket edges used when the request gives none
+const defaultPriceBuckets = "10,25,50,100,250"
+
+// searchFacets holds aggregate counts over all products matching a search. Price
+// buckets are in PriceCurrency.
+type searchFacets struct {
+	PriceCurrency string                  `json:"price_currency"`
+	Price         []priceBucket           `json:"price"`
+	Stock         stockFacet              `json:"stock"`
+	Categories    []categoryFacet         `json:"categories"`
+	Attributes    map[string][]valueFacet `json:"attributes"`
+}
+
+// priceBucket counts products priced in [Min, Max); open-ended buckets omit a bound
+type priceBucket struct {
+	Min   string `json:"min,omitempty"`
+	Max   string `json:"max,omitempty"`
+	Count int64  `json:"count"`
+}
+
+type stockFacet struct {
+	InStock    int64 `json:"in_stock"`
+	OutOfStock int64 `json:"out_of_stock"`
+}
+
+// categoryFacet counts products in a category or any of its subcategories
+type categoryFacet struct {
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Slug  string    `json:"slug"`
+	Count int64     `json:"count"`
+}
+
+type valueFacet struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// computeFacets aggregates the products matched by filtered, a session-safe search
+// query. Price buckets are split at the comma-separated edges in buckets, which
+// are amounts in currency; products priced in a currency with no exchange rate
+// to it fall in no bucket.
+func computeFacets(db, filtered *gorm.DB, buckets, currency string) (*searchFacets, error) {
+	if buckets == "" {
+		buckets = defaultPriceBuckets
+	}
+	var edges []money.Amount
+	var labels []string
+	for _, edge := range strings.Split(buckets, ",") {
+		edge = strings.TrimSpace(edge)
+		amount, err := money.ParseAmount(edge)
+		if err != nil || (len(edges) > 0 && amount <= edges[len(edges)-1]) {
+			return nil, validationError("price_buckets must be increasing amounts")
+		}
+		edges = append(edges, amount)
+		labels = append(labels, edge)
+	}
+
+	price, err := priceIn(db, currency)
+	if err != nil {
+		return nil, err
+	}
+	facets := &searchFacets{PriceCurrency: currency, Attributes: map[string][]valueFacet{}}
+	if err := priceAndStockFacets(filtered, price, edges, labels, facets); err != nil {
+		return nil, err
+	}
+
+	facets.Categories = []categoryFacet{}
+	err = db.Table("categories AS c").
+		Select("c.id, c.name, c.slug, COUNT(DISTINCT pc.product_id) AS count").
+		Joins("JOIN categories AS d ON d.path LIKE c.path || '%'").
+		Joins("JOIN product_categories AS pc ON pc.category_id = d.id").
+		Where("pc.product_id IN (?)", filtered.Select("id")).
+		Group("c.id, c.name, c.slug").
+		Order("count DESC, c.name").
+		Scan(&facets.Categories).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Only enum and boolean attributes have a small enough set of values to facet on
+	var definitions []models.AttributeDefinition
+	err = db.Where("type IN ?", []string{models.AttributeTypeEnum, models.AttributeTypeBoolean}).Order("key").Find(&definitions).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, definition := range definitions {
+		values := []valueFacet{}
+		err := filtered.Select("attributes->>? AS value, COUNT(*) AS count", definition.Key).
+			Where("jsonb_exists(attributes, ?)", definition.Key).
+			Group("value").
+			Order("count DESC, value").
+			Scan(&values).Error
+		if err != nil {
+			return nil, err
+		}
+		facets.Attributes[definition.Key] = values
+	}
+	return facets, nil
+}
+
+// priceAndStockFacets counts the price buckets and stock status in a single aggregate
+// query, bucketing on the price expression
+func priceAndStockFacets(filtered *gorm.DB, price clause.Expr, edges []money.Amount, labels []string, facets *searchFacets) error {
+	var columns []string
+	var args []interface{}
+	for i := 0; i <= len(edges); i++ {
+		bucket := priceBucket{}
+		var condition string
+		switch {
+		case i == 0:
+			condition = "? < ?"
+			args = append(args, price, edges[0])
+			bucket.Max = labels[0]
+		case i == len(edges):
+			condition = "? >= ?"
+			args = append(args, price, edges[i-1])
+			bucket.Min = labels[i-1]
+		default:
+			condition = "? >= ? AND ? < ?"
+			args = append(args, price, edges[i-1], price, edges[i])
+			bucket.Min, bucket.Max = labels[i-1], labels[i]
+		}
+		columns = append(columns, "COALESCE(SUM(CASE WHEN "+condition+" THEN 1 ELSE 0 END), 0)")
+		facets.Price = append(facets.Price, bucket)
+	}
+	columns = append(columns,
+		"COALESCE(SUM(CASE WHEN stock > 0 THEN 1 ELSE 0 END), 0)",
+		"COALESCE(SUM(CASE WHEN stock <= 0 THEN 1 ELSE 0 END), 0)",
+	)
+
+	dest := make([]interface{}, 0, len(columns))
+	for i := range facets.Price {
+		dest = append(dest, &facets.Price[i].Count)
+	}
+	dest = append(dest, &facets.Stock.InStock, &facets.Stock.OutOfStock)
+	return filtered.Select(strings.Join(columns, ", "), args...).Row().Scan(dest...)
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/common/paging"
+	"github.com/ozturkeniss/gomicro-app/product-service/database"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ts_headline marks matches with these private-use characters rather than HTML,
+// since its output is the raw product text. highlightHTML escapes that text and
+// only then turns the sentinels into <mark> tags.
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+// headlineOptions configure ts_headline for the highlighted name and description snippet
+const (
+	nameHeadlineOptions    = "StartSel=\"" + highlightStart + "\", StopSel=\"" + highlightStop + "\", HighlightAll=true"
+	snippetHeadlineOptions = "StartSel=\"" + highlightStart + "\", StopSel=\"" + highlightStop + "\", MaxWords=30, MinWords=10, MaxFragments=2, FragmentDelimiter=\" ... \""
+)
+
+// SearchProducts searches products by full-text query, name, description, price range,
+// stock status, category (including its subcategories) and custom attribute values.
+// Prices are compared in the requested currency, converting with the exchange rates.
+// With q, results are ordered by relevance and carry highlighted snippets. With
+// facets=true, the response also carries counts over all matching products.
+func SearchProducts(c *gin.Context) {
+	db := database.Scoped(c.Request.Context())
+	terms := searchTerms(c.Query("q"))
+	ranked := len(terms) > 0 && database.FullTextSearch(db)
+
+	sort := productSort
+	if ranked {
+		sort = relevanceSort
+	}
+	req, err := paging.FromRequest(c, sort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency := strings.ToUpper(c.DefaultQuery("currency", money.DefaultCurrency()))
+	if !money.ValidCurrency(currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return
+	}
+
+	filtered, err := searchQuery(db, c, currency)
+	if err != nil {
+		writeRequestError(c, err)
+		return
+	}
+	// Each use of the filtered query below starts from the same conditions
+	filtered = filtered.Session(&gorm.Session{})
+
+	selected := filtered
+	if ranked {
+		selected = selectRelevance(filtered, terms)
+	}
+	page, ok := findProductPage(c, req, filtered, selected)
+	if !ok {
+		return
+	}
+	if ranked {
+		for i := range page.Data {
+			page.Data[i].Highlight = highlightHTML(page.Data[i].Highlight)
+			page.Data[i].Snippet = highlightHTML(page.Data[i].Snippet)
+		}
+	}
+
+	var facets *searchFacets
+	if c.Query("facets") == "true" {
+		facets, err = computeFacets(db, filtered, c.Query("price_buckets"), currency)
+		if err != nil {
+			writeRequestError(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, searchPage{Page: page, Facets: facets})
+}
+
+// searchPage is the search response: a page of products plus optional facets
+type searchPage struct {
+	*paging.Page[models.Product]
+	Facets *searchFacets `json:"facets,omitempty"`
+}
+
+// relevanceSort extends productSort with the full-text rank, the default when searching with q
+var relevanceSort = paging.Sort{
+	Fields: map[string]string{
+		"name":       "name",
+		"price":      "price_amount",
+		"stock":      "stock",
+		"created_at": "created_at",
+		"rating":     "rating_average",
+		"relevance":  "search_rank",
+	},
+	Default:  "-relevance",
+	Computed: map[string]bool{"relevance": true},
+}
+
+// searchQuery builds the filtered product query for the search parameters on the
+// request. minPrice and maxPrice are amounts in currency.
+func searchQuery(db *gorm.DB, c *gin.Context, currency string) (*gorm.DB, error) {
+	query := db.Model(&models.Product{})
+
+	if terms := searchTerms(c.Query("q")); len(terms) > 0 {
+		if database.FullTextSearch(db) {
+			query = query.Where("search_vector @@ to_tsquery(?::regconfig, ?)", database.SearchConfig(), prefixQuery(terms))
+		} else {
+			// Databases without full-text search (e.g. SQLite in tests) match each term as a substring
+			for _, term := range terms {
+				pattern := "%" + term + "%"
+				query = query.Where("(LOWER(name) LIKE ? OR LOWER(description) LIKE ?)", pattern, pattern)
+			}
+		}
+	}
+	if name := c.Query("name"); name != "" {
+		query = query.Where("LOWER(name) LIKE ?", "%"+strings.ToLower(name)+"%")
+	}
+	if description := c.Query("description"); description != "" {
+		query = query.Where("LOWER(description) LIKE ?", "%"+strings.ToLower(description)+"%")
+	}
+	minPrice, maxPrice := c.Query("minPrice"), c.Query("maxPrice")
+	if minPrice != "" || maxPrice != "" {
+		price, err := priceIn(db, currency)
+		if err != nil {
+			return nil, err
+		}
+		if minPrice != "" {
+			amount, err := money.ParseAmount(minPrice)
+			if err != nil {
+				return nil, validationError("Invalid minPrice")
+			}
+			query = query.Where("? >= ?", price, amount)
+		}
+		if maxPrice != "" {
+			amount, err := money.ParseAmount(maxPrice)
+			if err != nil {
+				return nil, validationError("Invalid maxPrice")
+			}
+			query = query.Where("? <= ?", price, amount)
+		}
+	}
+	if c.Query("inStock") == "true" {
+		query = query.Where("stock > 0")
+	}
+	if category := c.Query("category"); category != "" {
+		found, err := findCategory(db, category)
+		if err != nil {
+			return nil, validationError("Unknown category")
+		}
+		query = query.Where("id IN (?)", productsInCategory(db, found))
+	}
+	return applyAttributeFilters(db, query, c.Request.URL.Query())
+}
+
+// priceIn returns a SQL expression for the product price converted into currency
+// with the stored exchange rates and rounded to its minor unit. It is NULL for
+// products priced in a currency with no rate to it, so price conditions leave
+// them out.
+func priceIn(db *gorm.DB, currency string) (clause.Expr, error) {
+	exp, err := money.Exponent(currency)
+	if err != nil {
+		return clause.Expr{}, validationError("Unsupported currency")
+	}
+	var currencies []string
+	if err := db.Model(&models.Product{}).Distinct("price_currency").Pluck("price_currency", &currencies).Error; err != nil {
+		return clause.Expr{}, err
+	}
+	rates, err := database.LoadRateTable(db)
+	if err != nil {
+		return clause.Expr{}, err
+	}
+
+	sql := "(CASE price_currency"
+	var vars []interface{}
+	for _, from := range currencies {
+		rate, err := rates.Rate(from, currency)
+		if err != nil {
+			continue
+		}
+		sql += fmt.Sprintf(" WHEN ? THEN ROUND(price_amount * CAST(? AS NUMERIC), %d)", exp)
+		vars = append(vars, from, money.FormatRate(rate))
+	}
+	if len(vars) == 0 {
+		return clause.Expr{SQL: "CAST(NULL AS NUMERIC)"}, nil
+	}
+	return clause.Expr{SQL: sql + " END)", Vars: vars}, nil
+}
+
+// selectRelevance selects the rank and highlighted fragments for a full-text search
+func selectRelevance(query *gorm.DB, terms []string) *gorm.DB {
+	config, tsquery := database.SearchConfig(), prefixQuery(terms)
+	return query.Select(
+		"products.*, "+
+			"ts_rank(search_vector, to_tsquery(?::regconfig, ?)) AS search_rank, "+
+			"ts_headline(?::regconfig, name, to_tsquery(?::regconfig, ?), ?) AS highlight, "+
+			"ts_headline(?::regconfig, description, to_tsquery(?::regconfig, ?), ?) AS snippet",
+		config, tsquery,
+		config, config, tsquery, nameHeadlineOptions,
+		config, config, tsquery, snippetHeadlineOptions,
+	)
+}
+
+// markReplacer turns the highlight sentinels into <mark> tags
+var markReplacer = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+// highlightHTML HTML-escapes a ts_headline fragment and wraps its matches in <mark>
+// tags, so markup in product names and descriptions is shown as text
+func highlightHTML(fragment string) string {
+	return markReplacer.Replace(html.EscapeString(fragment))
+}
+
+// searchTerms splits free text into lowercase words, dropping punctuation so
+// nothing the user types can be read as a tsquery operator
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// prefixQuery builds a tsquery matching every term as a prefix, so "red sho"
+// finds "red shoes": red:* & sho:*
+func prefixQuery(terms []string) string {
+	prefixed := make([]string, len(terms))
+	for i, term := range terms {
+		prefixed[i] = term + ":*"
+	}
+	return strings.Join(prefixed, " & ")
+}
@@ -0,0 +1,410 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"github.com/ozturkeniss/gomicro-app/product-service/alerts"
+	"github.com/ozturkeniss/gomicro-app/product-service/database"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"gorm.io/gorm"
+)
+
+var (
+	errSKUTaken         = errors.New("SKU is already in use")
+	errDuplicateOptions = errors.New("another variant already has these options")
+)
+
+// variantRequest is the body for creating and updating variants
+type variantRequest struct {
+	SKU           string            `json:"sku" binding:"required"`
+	Options       map[string]string `json:"options"`
+	PriceOverride *money.Money      `json:"price_override"`
+	Stock         int               `json:"stock"`
+	Barcode       string            `json:"barcode"`
+}
+
+// ListVariants lists a product's variants
+func ListVariants(c *gin.Context) {
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+
+	var variants []models.Variant
+	result := database.Scoped(c.Request.Context()).Where("product_id = ?", product.ID).Order("sku").Find(&variants)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	for i := range variants {
+		variants[i].ResolvePrice(product.Price)
+	}
+
+	c.JSON(http.StatusOK, variants)
+}
+
+// GetVariant retrieves a single variant of a product
+func GetVariant(c *gin.Context) {
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+	variant, ok := loadVariant(c, product)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, variant)
+}
+
+// CreateVariant adds a variant to a product
+func CreateVariant(c *gin.Context) {
+	var req variantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+
+	variant := models.Variant{ID: uuid.New(), ProductID: product.ID}
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if tracked, err := stockTracked(tx, product.ID, uuid.Nil); err != nil {
+			return err
+		} else if tracked {
+			return validationError("Product stock is kept per warehouse; clear it from the warehouses before adding variants")
+		}
+		if err := applyVariantRequest(tx, product, &variant, &req); err != nil {
+			return err
+		}
+		if err := tx.Create(&variant).Error; err != nil {
+			return err
+		}
+		return syncProductStock(tx, product.ID)
+	})
+	if !writeVariantError(c, err) {
+		return
+	}
+
+	variant.ResolvePrice(product.Price)
+	c.JSON(http.StatusCreated, variant)
+}
+
+// UpdateVariant updates a variant's SKU, options, price override, stock and barcode
+func UpdateVariant(c *gin.Context) {
+	var req variantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+	variant, ok := loadVariant(c, product)
+	if !ok {
+		return
+	}
+
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := applyVariantRequest(tx, product, variant, &req); err != nil {
+			return err
+		}
+		if err := tx.Save(variant).Error; err != nil {
+			return err
+		}
+		return syncProductStock(tx, product.ID)
+	})
+	if !writeVariantError(c, err) {
+		return
+	}
+
+	variant.ResolvePrice(product.Price)
+	c.JSON(http.StatusOK, variant)
+}
+
+// DeleteVariant deletes a variant; orders keep the SKU they were placed with
+func DeleteVariant(c *gin.Context) {
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+	variant, ok := loadVariant(c, product)
+	if !ok {
+		return
+	}
+
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(variant).Error; err != nil {
+			return err
+		}
+		return syncProductStock(tx, product.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Variant deleted successfully"})
+}
+
+// UpdateVariantStock sets the stock of a variant; the product's stock is their sum
+func UpdateVariantStock(c *gin.Context) {
+	var stockUpdate struct {
+		Stock *int `json:"stock" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&stockUpdate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if *stockUpdate.Stock < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stock cannot be negative"})
+		return
+	}
+
+	product, ok := loadProduct(c)
+	if !ok {
+		return
+	}
+	variant, ok := loadVariant(c, product)
+	if !ok {
+		return
+	}
+
+	variant.Stock = *stockUpdate.Stock
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if tracked, err := stockTracked(tx, product.ID, variant.ID); err != nil {
+			return err
+		} else if tracked {
+			return errStockTracked
+		}
+		if err := tx.Model(variant).Update("stock", variant.Stock).Error; err != nil {
+			return err
+		}
+		return syncProductStock(tx, product.ID)
+	})
+	if errors.Is(err, errStockTracked) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stock is managed per warehouse; set it in each warehouse"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, variant)
+}
+
+// loadProduct loads the product from the :id path parameter
+func loadProduct(c *gin.Context) (*models.Product, bool) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return nil, false
+	}
+
+	var product models.Product
+	if result := database.Scoped(c.Request.Context()).First(&product, "id = ?", productID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return nil, false
+	}
+	return &product, true
+}
+
+// loadVariant loads the product's variant from the :variantId path parameter
+func loadVariant(c *gin.Context, product *models.Product) (*models.Variant, bool) {
+	variantID, err := uuid.Parse(c.Param("variantId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid variant ID"})
+		return nil, false
+	}
+
+	var variant models.Variant
+	result := database.Scoped(c.Request.Context()).First(&variant, "id = ? AND product_id = ?", variantID, product.ID)
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Variant not found"})
+		return nil, false
+	}
+	variant.ResolvePrice(product.Price)
+	return &variant, true
+}
+
+// applyVariantRequest validates the request against the product and copies it onto the variant
+func applyVariantRequest(tx *gorm.DB, product *models.Product, variant *models.Variant, req *variantRequest) error {
+	req.SKU = strings.TrimSpace(req.SKU)
+	if err := validateVariantRequest(product, req); err != nil {
+		return err
+	}
+
+	taken, err := skuInUse(tx, req.SKU, uuid.Nil, variant.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errSKUTaken
+	}
+
+	var siblings []models.Variant
+	if err := tx.Where("product_id = ? AND id <> ?", product.ID, variant.ID).Find(&siblings).Error; err != nil {
+		return err
+	}
+	for _, sibling := range siblings {
+		if sibling.MatchesOptions(req.Options) {
+			return errDuplicateOptions
+		}
+	}
+
+	// Stock kept per warehouse is only changed through the warehouses
+	tracked, err := stockTracked(tx, product.ID, variant.ID)
+	if err != nil {
+		return err
+	}
+
+	variant.SKU = req.SKU
+	variant.Options = req.Options
+	if !tracked {
+		variant.Stock = req.Stock
+	}
+	variant.Barcode = req.Barcode
+	variant.PriceOverride = nil
+	if req.PriceOverride != nil {
+		variant.PriceOverride = &req.PriceOverride.Amount
+	}
+	return nil
+}
+
+// skuInUse reports whether a product other than productID or a variant other than
+// variantID already has the SKU. Products and variants share one SKU namespace.
+func skuInUse(tx *gorm.DB, sku string, productID, variantID uuid.UUID) (bool, error) {
+	var taken int64
+	if err := tx.Model(&models.Variant{}).Where("sku = ? AND id <> ?", sku, variantID).Count(&taken).Error; err != nil {
+		return false, err
+	}
+	if taken > 0 {
+		return true, nil
+	}
+	if err := tx.Model(&models.Product{}).Where("sku = ? AND id <> ?", sku, productID).Count(&taken).Error; err != nil {
+		return false, err
+	}
+	return taken > 0, nil
+}
+
+// validationError marks request validation failures so they're reported as 400s
+type validationError string
+
+func (e validationError) Error() string { return string(e) }
+
+// validateVariantRequest checks the request's options, price, stock and barcode
+func validateVariantRequest(product *models.Product, req *variantRequest) error {
+	if len(req.Options) != len(product.Options) {
+		return validationError("Options must have a value for each of the product's option axes")
+	}
+	for _, axis := range product.Options {
+		if strings.TrimSpace(req.Options[axis]) == "" {
+			return validationError("Missing value for option " + axis)
+		}
+	}
+	if req.PriceOverride != nil {
+		if req.PriceOverride.Currency != product.Price.Currency {
+			return validationError("Price override must be in the product's currency")
+		}
+		if !req.PriceOverride.IsPositive() {
+			return validationError("Price must be greater than zero")
+		}
+	}
+	if req.Stock < 0 {
+		return validationError("Stock cannot be negative")
+	}
+	if req.Barcode != "" && !validBarcode(req.Barcode) {
+		return validationError("Barcode must be a valid GTIN-8, 12, 13 or 14")
+	}
+	return nil
+}
+
+// syncProductStock sets a product's stock to the total stock of its variants
+func syncProductStock(tx *gorm.DB, productID uuid.UUID) error {
+	var total int64
+	err := tx.Model(&models.Variant{}).Where("product_id = ?", productID).Select("COALESCE(SUM(stock), 0)").Scan(&total).Error
+	if err != nil {
+		return err
+	}
+	return alerts.Track(tx, productID, func() error {
+		return tx.Model(&models.Product{}).Where("id = ?", productID).Update("stock", total).Error
+	})
+}
+
+// validOptionAxes reports whether the option axis names are non-empty and distinct
+func validOptionAxes(axes []string) bool {
+	seen := make(map[string]bool, len(axes))
+	for _, axis := range axes {
+		if strings.TrimSpace(axis) == "" || seen[axis] {
+			return false
+		}
+		seen[axis] = true
+	}
+	return true
+}
+
+// sameOptionAxes reports whether two products have the same option axes, in any order
+func sameOptionAxes(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]bool, len(a))
+	for _, axis := range a {
+		set[axis] = true
+	}
+	for _, axis := range b {
+		if !set[axis] {
+			return false
+		}
+	}
+	return true
+}
+
+// validBarcode checks the length and mod-10 check digit of a GTIN barcode
+func validBarcode(code string) bool {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+	sum := 0
+	for i := len(code) - 1; i >= 0; i-- {
+		if code[i] < '0' || code[i] > '9' {
+			return false
+		}
+		digit := int(code[i] - '0')
+		// Weights alternate 1, 3, 1, ... starting from the check digit on the right
+		if (len(code)-1-i)%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
+
+// writeVariantError writes the response for a failed variant change and reports whether err was nil
+func writeVariantError(c *gin.Context, err error) bool {
+	var invalid validationError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errSKUTaken), errors.Is(err, errDuplicateOptions):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
@@ -0,0 +1,495 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/paging"
+	"github.com/ozturkeniss/gomicro-app/product-service/database"
+	"github.com/ozturkeniss/gomicro-app/product-service/inventory"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errWarehouseCodeTaken = errors.New("warehouse code is already in use")
+	errWarehouseNotEmpty  = errors.New("warehouse still holds or reserves stock")
+	errWarehouseNotFound  = errors.New("warehouse not found")
+	errStockItemNotFound  = errors.New("product or variant not found")
+	errBelowReserved      = errors.New("on-hand stock can't be lower than the reserved stock")
+)
+
+// warehouseRequest is the body for creating and updating warehouses
+type warehouseRequest struct {
+	Code     string `json:"code" binding:"required,max=32"`
+	Name     string `json:"name" binding:"required,max=255"`
+	Country  string `json:"country" binding:"omitempty,len=2"`
+	Region   string `json:"region" binding:"max=100"`
+	Priority int    `json:"priority"`
+	Active   *bool  `json:"active"`
+}
+
+// stockItemRequest names a product, or a variant of it, whose stock is changed
+type stockItemRequest struct {
+	ProductID uuid.UUID  `json:"product_id" binding:"required"`
+	VariantID *uuid.UUID `json:"variant_id"`
+}
+
+// stockLevelSort lists the fields stock level lists can be sorted by
+var stockLevelSort = paging.Sort{
+	Fields: map[string]string{
+		"on_hand":    "on_hand",
+		"updated_at": "updated_at",
+	},
+	Default: "-updated_at",
+}
+
+// transferSort lists the fields transfer lists can be sorted by
+var transferSort = paging.Sort{
+	Fields:  map[string]string{"created_at": "created_at"},
+	Default: "-created_at",
+}
+
+// CreateWarehouse adds a stock location; warehouses are active unless created with active=false
+func CreateWarehouse(c *gin.Context) {
+	var req warehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warehouse := models.Warehouse{ID: uuid.New(), Active: true}
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := applyWarehouseRequest(tx, &warehouse, &req); err != nil {
+			return err
+		}
+		return tx.Create(&warehouse).Error
+	})
+	if !writeInventoryError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, warehouse)
+}
+
+// ListWarehouses lists the tenant's warehouses in allocation priority order
+func ListWarehouses(c *gin.Context) {
+	var warehouses []models.Warehouse
+	result := database.Scoped(c.Request.Context()).Order("priority, code").Find(&warehouses)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, warehouses)
+}
+
+// GetWarehouse retrieves a warehouse
+func GetWarehouse(c *gin.Context) {
+	warehouse, ok := loadWarehouse(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, warehouse)
+}
+
+// UpdateWarehouse updates a warehouse. Activating or deactivating it changes how
+// much of the stock it holds can be promised.
+func UpdateWarehouse(c *gin.Context) {
+	var req warehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warehouse, ok := loadWarehouse(c)
+	if !ok {
+		return
+	}
+
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		wasActive := warehouse.Active
+		if err := applyWarehouseRequest(tx, warehouse, &req); err != nil {
+			return err
+		}
+		if err := tx.Save(warehouse).Error; err != nil {
+			return err
+		}
+		if warehouse.Active == wasActive {
+			return nil
+		}
+		return syncWarehouseStock(tx, warehouse.ID)
+	})
+	if !writeInventoryError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, warehouse)
+}
+
+// DeleteWarehouse deletes a warehouse that holds and reserves no stock
+func DeleteWarehouse(c *gin.Context) {
+	warehouse, ok := loadWarehouse(c)
+	if !ok {
+		return
+	}
+
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var stocked int64
+		if err := tx.Model(&models.StockLevel{}).Where("warehouse_id = ? AND (on_hand > 0 OR reserved > 0)", warehouse.ID).Count(&stocked).Error; err != nil {
+			return err
+		}
+		if stocked > 0 {
+			return errWarehouseNotEmpty
+		}
+		if err := tx.Where("warehouse_id = ?", warehouse.ID).Delete(&models.StockLevel{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(warehouse).Error
+	})
+	if !writeInventoryError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Warehouse deleted successfully"})
+}
+
+// ListWarehouseStock retrieves a page of the stock levels held in a warehouse,
+// optionally for one product
+func ListWarehouseStock(c *gin.Context) {
+	req, err := paging.FromRequest(c, stockLevelSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	warehouse, ok := loadWarehouse(c)
+	if !ok {
+		return
+	}
+
+	query := database.Scoped(c.Request.Context()).Model(&models.StockLevel{}).Where("warehouse_id = ?", warehouse.ID)
+	if value := c.Query("product_id"); value != "" {
+		productID, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product_id"})
+			return
+		}
+		query = query.Where("product_id = ?", productID)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	var levels []models.StockLevel
+	if result := req.Apply(query).Find(&levels); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	for i := range levels {
+		levels[i].ResolveAvailable()
+	}
+
+	page, err := paging.NewPage(database.DB, req, levels, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// SetWarehouseStock sets the on-hand stock of a product or variant in a warehouse,
+// e.g. after a stock count. The product's stock becomes its available-to-promise
+// total across active warehouses.
+func SetWarehouseStock(c *gin.Context) {
+	var req struct {
+		stockItemRequest
+		OnHand *int `json:"on_hand" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if *req.OnHand < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stock cannot be negative"})
+		return
+	}
+
+	warehouse, ok := loadWarehouse(c)
+	if !ok {
+		return
+	}
+
+	var level *models.StockLevel
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		variantID, err := resolveStockItem(tx, &req.stockItemRequest)
+		if err != nil {
+			return err
+		}
+		level, err = lockStockLevel(tx, warehouse.ID, req.ProductID, variantID)
+		if err != nil {
+			return err
+		}
+		if *req.OnHand < level.Reserved {
+			return errBelowReserved
+		}
+		level.OnHand = *req.OnHand
+		if err := tx.Save(level).Error; err != nil {
+			return err
+		}
+		return syncItemStock(tx, req.ProductID, variantID)
+	})
+	if !writeInventoryError(c, err) {
+		return
+	}
+
+	level.ResolveAvailable()
+	c.JSON(http.StatusOK, level)
+}
+
+// CreateTransfer moves available stock of a product or variant between warehouses
+func CreateTransfer(c *gin.Context) {
+	var req struct {
+		stockItemRequest
+		FromWarehouseID uuid.UUID `json:"from_warehouse_id" binding:"required"`
+		ToWarehouseID   uuid.UUID `json:"to_warehouse_id" binding:"required"`
+		Quantity        int       `json:"quantity" binding:"required,min=1"`
+		Note            string    `json:"note" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.FromWarehouseID == req.ToWarehouseID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stock must be transferred to a different warehouse"})
+		return
+	}
+
+	transfer := models.StockTransfer{
+		ID:              uuid.New(),
+		FromWarehouseID: req.FromWarehouseID,
+		ToWarehouseID:   req.ToWarehouseID,
+		ProductID:       req.ProductID,
+		Quantity:        req.Quantity,
+		Note:            strings.TrimSpace(req.Note),
+		UserID:          c.GetString("userID"),
+	}
+	err := database.Scoped(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		var warehouses int64
+		if err := tx.Model(&models.Warehouse{}).Where("id IN ?", []uuid.UUID{req.FromWarehouseID, req.ToWarehouseID}).Count(&warehouses).Error; err != nil {
+			return err
+		}
+		if warehouses != 2 {
+			return errWarehouseNotFound
+		}
+		variantID, err := resolveStockItem(tx, &req.stockItemRequest)
+		if err != nil {
+			return err
+		}
+		transfer.VariantID = variantID
+
+		// Lock both levels in ID order so opposite transfers can't deadlock
+		ids := []uuid.UUID{req.FromWarehouseID, req.ToWarehouseID}
+		if ids[1].String() < ids[0].String() {
+			ids[0], ids[1] = ids[1], ids[0]
+		}
+		levels := make(map[uuid.UUID]*models.StockLevel, 2)
+		for _, id := range ids {
+			if levels[id], err = lockStockLevel(tx, id, req.ProductID, variantID); err != nil {
+				return err
+			}
+		}
+		from, to := levels[req.FromWarehouseID], levels[req.ToWarehouseID]
+		if from.OnHand-from.Reserved < req.Quantity {
+			return inventory.ErrInsufficientStock
+		}
+		from.OnHand -= req.Quantity
+		to.OnHand += req.Quantity
+		if err := tx.Save(from).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(to).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&transfer).Error; err != nil {
+			return err
+		}
+		return syncItemStock(tx, req.ProductID, variantID)
+	})
+	if !writeInventoryError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// ListTransfers retrieves a page of stock transfers, newest first, optionally
+// involving one warehouse or product
+func ListTransfers(c *gin.Context) {
+	req, err := paging.FromRequest(c, transferSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.Scoped(c.Request.Context()).Model(&models.StockTransfer{})
+	if value := c.Query("warehouse_id"); value != "" {
+		warehouseID, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid warehouse_id"})
+			return
+		}
+		query = query.Where("from_warehouse_id = ? OR to_warehouse_id = ?", warehouseID, warehouseID)
+	}
+	if value := c.Query("product_id"); value != "" {
+		productID, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product_id"})
+			return
+		}
+		query = query.Where("product_id = ?", productID)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	var transfers []models.StockTransfer
+	if result := req.Apply(query).Find(&transfers); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	page, err := paging.NewPage(database.DB, req, transfers, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// loadWarehouse loads the warehouse from the :id path parameter
+func loadWarehouse(c *gin.Context) (*models.Warehouse, bool) {
+	warehouseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid warehouse ID"})
+		return nil, false
+	}
+
+	var warehouse models.Warehouse
+	if result := database.Scoped(c.Request.Context()).First(&warehouse, "id = ?", warehouseID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warehouse not found"})
+		return nil, false
+	}
+	return &warehouse, true
+}
+
+// applyWarehouseRequest validates the request and copies it onto the warehouse
+func applyWarehouseRequest(tx *gorm.DB, warehouse *models.Warehouse, req *warehouseRequest) error {
+	code := strings.TrimSpace(req.Code)
+	name := strings.TrimSpace(req.Name)
+	if code == "" || name == "" {
+		return validationError("Code and name can't be blank")
+	}
+
+	var taken int64
+	if err := tx.Model(&models.Warehouse{}).Where("code = ? AND id <> ?", code, warehouse.ID).Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return errWarehouseCodeTaken
+	}
+
+	warehouse.Code = code
+	warehouse.Name = name
+	warehouse.Country = strings.ToUpper(req.Country)
+	warehouse.Region = strings.TrimSpace(req.Region)
+	warehouse.Priority = req.Priority
+	if req.Active != nil {
+		warehouse.Active = *req.Active
+	}
+	return nil
+}
+
+// resolveStockItem checks the product exists and that a variant is named exactly
+// when the product has variants. It returns the variant ID, or uuid.Nil.
+func resolveStockItem(tx *gorm.DB, req *stockItemRequest) (uuid.UUID, error) {
+	var product models.Product
+	if err := tx.Select("id").First(&product, "id = ?", req.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, errStockItemNotFound
+		}
+		return uuid.Nil, err
+	}
+
+	var variants int64
+	if err := tx.Model(&models.Variant{}).Where("product_id = ?", req.ProductID).Count(&variants).Error; err != nil {
+		return uuid.Nil, err
+	}
+	switch {
+	case variants == 0 && req.VariantID != nil && *req.VariantID != uuid.Nil:
+		return uuid.Nil, validationError("Product has no variants")
+	case variants == 0:
+		return uuid.Nil, nil
+	case req.VariantID == nil || *req.VariantID == uuid.Nil:
+		return uuid.Nil, validationError("variant_id is required for products with variants")
+	}
+
+	var variant models.Variant
+	if err := tx.Select("id").First(&variant, "id = ? AND product_id = ?", *req.VariantID, req.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, errStockItemNotFound
+		}
+		return uuid.Nil, err
+	}
+	return variant.ID, nil
+}
+
+// lockStockLevel loads and locks an item's stock level in a warehouse, creating
+// an empty one if the warehouse doesn't stock the item yet
+func lockStockLevel(tx *gorm.DB, warehouseID, productID, variantID uuid.UUID) (*models.StockLevel, error) {
+	empty := models.StockLevel{ID: uuid.New(), WarehouseID: warehouseID, ProductID: productID, VariantID: variantID}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&empty).Error; err != nil {
+		return nil, err
+	}
+
+	var level models.StockLevel
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&level, "warehouse_id = ? AND product_id = ? AND variant_id = ?", warehouseID, productID, variantID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &level, nil
+}
+
+// writeInventoryError writes the response for a failed inventory change and reports whether err was nil
+func writeInventoryError(c *gin.Context, err error) bool {
+	var invalid validationError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errWarehouseNotFound), errors.Is(err, errStockItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errWarehouseCodeTaken), errors.Is(err, errWarehouseNotEmpty),
+		errors.Is(err, errBelowReserved), errors.Is(err, inventory.ErrInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// MaxPixels bounds the images Decode accepts, so a small compressed upload can't
+// expand into an enormous bitmap
+const MaxPixels = 40_000_000
+
+// jpegQuality is used for thumbnails of opaque images
+const jpegQuality = 85
+
+var (
+	// ErrUnsupportedFormat is returned for data that isn't a JPEG, PNG or GIF image
+	ErrUnsupportedFormat = errors.New("image must be a JPEG, PNG or GIF")
+	// ErrTooLarge is returned for images with more than MaxPixels pixels
+	ErrTooLarge = errors.New("image dimensions are too large")
+)
+
+// Decode checks the image's format and dimensions before decoding it. It returns
+// the image and its format name (jpeg, png or gif).
+func Decode(data []byte) (image.Image, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedFormat
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, "", ErrTooLarge
+	}
+
+	var img image.Image
+	switch format {
+	case "jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case "png":
+		img, err = png.Decode(bytes.NewReader(data))
+	case "gif":
+		img, err = gif.Decode(bytes.NewReader(data))
+	default:
+		return nil, "", ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return img, format, nil
+}
+
+// ContentType returns the MIME type of a format returned by Decode
+func ContentType(format string) string {
+	return "image/" + format
+}
+
+// Fit scales img down so neither side exceeds size, keeping its aspect ratio.
+// Each target pixel averages the source pixels it covers. Images that already
+// fit are returned as they are.
+func Fit(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return img
+	}
+	targetWidth, targetHeight := size, size
+	if width > height {
+		targetHeight = max(1, height*size/width)
+	} else {
+		targetWidth = max(1, width*size/height)
+	}
+
+	target := image.NewNRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	for y := 0; y < targetHeight; y++ {
+		y0 := bounds.Min.Y + y*height/targetHeight
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*height/targetHeight)
+		for x := 0; x < targetWidth; x++ {
+			x0 := bounds.Min.X + x*width/targetWidth
+			x1 := max(x0+1, bounds.Min.X+(x+1)*width/targetWidth)
+
+			// Sum premultiplied channels so transparent pixels don't darken edges
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			target.Set(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n),
+			})
+		}
+	}
+	return target
+}
+
+// Encode writes a thumbnail as JPEG, or as PNG when it has transparent pixels,
+// and returns the format used
+func Encode(img image.Image) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if opaque(img) {
+		err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+		return buf.Bytes(), "jpeg", err
+	}
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), "png", err
+}
+
+// opaque reports whether every pixel of img is fully opaque
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
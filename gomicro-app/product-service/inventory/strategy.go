@@ -0,0 +1,145 @@
+// Package inventory chooses the warehouse an order ships from. Strategies are
+// registered by name and selected with ALLOCATION_STRATEGY.
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// DefaultStrategy is used when ALLOCATION_STRATEGY is not set
+const DefaultStrategy = "priority"
+
+// ErrInsufficientStock is returned when no warehouse can fulfil the whole quantity
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// Candidate is an active warehouse holding enough stock for the order
+type Candidate struct {
+	WarehouseID uuid.UUID
+	Code        string
+	Country     string
+	Region      string
+	Priority    int
+	Available   int
+}
+
+// Request describes the order being allocated
+type Request struct {
+	Quantity int
+	// Country and Region are where the order ships to
+	Country string
+	Region  string
+}
+
+// Strategy picks one of the candidates, which is never empty
+type Strategy interface {
+	Choose(req Request, candidates []Candidate) Candidate
+}
+
+// StrategyFunc adapts a function to the Strategy interface
+type StrategyFunc func(req Request, candidates []Candidate) Candidate
+
+// Choose implements Strategy
+func (f StrategyFunc) Choose(req Request, candidates []Candidate) Candidate {
+	return f(req, candidates)
+}
+
+var (
+	mu         sync.RWMutex
+	strategies = map[string]Strategy{
+		"priority":   StrategyFunc(byPriority),
+		"nearest":    StrategyFunc(nearest),
+		"most-stock": StrategyFunc(mostStock),
+	}
+)
+
+// Register makes a strategy available under name, replacing any existing one
+func Register(name string, strategy Strategy) {
+	mu.Lock()
+	defer mu.Unlock()
+	strategies[name] = strategy
+}
+
+// Lookup returns the strategy registered under name
+func Lookup(name string) (Strategy, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	strategy, ok := strategies[name]
+	return strategy, ok
+}
+
+// StrategyName returns the strategy selected by ALLOCATION_STRATEGY, checking it is registered
+func StrategyName() (string, error) {
+	name := os.Getenv("ALLOCATION_STRATEGY")
+	if name == "" {
+		name = DefaultStrategy
+	}
+	if _, ok := Lookup(name); !ok {
+		return "", fmt.Errorf("unknown ALLOCATION_STRATEGY %q", name)
+	}
+	return name, nil
+}
+
+// Allocate filters the warehouses that can ship the whole quantity and lets the
+// strategy choose among them
+func Allocate(strategy Strategy, req Request, warehouses []Candidate) (Candidate, error) {
+	var candidates []Candidate
+	for _, warehouse := range warehouses {
+		if warehouse.Available >= req.Quantity {
+			candidates = append(candidates, warehouse)
+		}
+	}
+	if len(candidates) == 0 {
+		return Candidate{}, ErrInsufficientStock
+	}
+	// Sort first so strategies that tie fall back to priority, then code
+	sort.SliceStable(candidates, func(i, j int) bool { return lessPriority(candidates[i], candidates[j]) })
+	return strategy.Choose(req, candidates), nil
+}
+
+// byPriority ships from the warehouse with the lowest priority number
+func byPriority(req Request, candidates []Candidate) Candidate {
+	return candidates[0]
+}
+
+// nearest prefers warehouses in the destination's region, then its country
+func nearest(req Request, candidates []Candidate) Candidate {
+	best, bestScore := candidates[0], -1
+	for _, candidate := range candidates {
+		score := 0
+		if req.Country != "" && strings.EqualFold(candidate.Country, req.Country) {
+			score = 1
+			if req.Region != "" && strings.EqualFold(candidate.Region, req.Region) {
+				score = 2
+			}
+		}
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return best
+}
+
+// mostStock ships from the warehouse with the most available stock
+func mostStock(req Request, candidates []Candidate) Candidate {
+	best := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if candidate.Available > best.Available {
+			best = candidate
+		}
+	}
+	return best
+}
+
+func lessPriority(a, b Candidate) bool {
+	if a.Priority != b.Priority {
+		return a.Priority < b.Priority
+	}
+	return a.Code < b.Code
+}
@@ -0,0 +1,155 @@
+package inventory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func warehouse(code, country, region string, priority, available int) Candidate {
+	return Candidate{WarehouseID: uuid.New(), Code: code, Country: country, Region: region, Priority: priority, Available: available}
+}
+
+func TestAllocate(t *testing.T) {
+	berlin := warehouse("BER", "DE", "BE", 2, 10)
+	munich := warehouse("MUC", "DE", "BY", 1, 4)
+	paris := warehouse("PAR", "FR", "IDF", 1, 50)
+	lyon := warehouse("LYS", "FR", "ARA", 1, 50)
+
+	tests := []struct {
+		name       string
+		strategy   string
+		req        Request
+		warehouses []Candidate
+		want       string
+		wantErr    error
+	}{
+		{
+			name:       "lowest priority number",
+			strategy:   "priority",
+			req:        Request{Quantity: 2},
+			warehouses: []Candidate{berlin, munich, paris},
+			want:       "MUC",
+		},
+		{
+			name:       "priority ties go to the code",
+			strategy:   "priority",
+			req:        Request{Quantity: 2},
+			warehouses: []Candidate{paris, lyon},
+			want:       "LYS",
+		},
+		{
+			name:       "skips warehouses without the whole quantity",
+			strategy:   "priority",
+			req:        Request{Quantity: 5},
+			warehouses: []Candidate{munich, berlin},
+			want:       "BER",
+		},
+		{
+			name:       "nearest region",
+			strategy:   "nearest",
+			req:        Request{Quantity: 1, Country: "DE", Region: "be"},
+			warehouses: []Candidate{paris, munich, berlin},
+			want:       "BER",
+		},
+		{
+			name:       "nearest country",
+			strategy:   "nearest",
+			req:        Request{Quantity: 1, Country: "fr", Region: "NAQ"},
+			warehouses: []Candidate{berlin, paris, lyon},
+			want:       "LYS",
+		},
+		{
+			name:       "nearest falls back to priority",
+			strategy:   "nearest",
+			req:        Request{Quantity: 1, Country: "US"},
+			warehouses: []Candidate{berlin, munich},
+			want:       "MUC",
+		},
+		{
+			name:       "most stock",
+			strategy:   "most-stock",
+			req:        Request{Quantity: 1},
+			warehouses: []Candidate{berlin, munich, paris},
+			want:       "PAR",
+		},
+		{
+			name:       "most stock ties go to priority",
+			strategy:   "most-stock",
+			req:        Request{Quantity: 1},
+			warehouses: []Candidate{paris, lyon},
+			want:       "LYS",
+		},
+		{
+			name:       "insufficient stock",
+			strategy:   "priority",
+			req:        Request{Quantity: 11},
+			warehouses: []Candidate{berlin, munich},
+			wantErr:    ErrInsufficientStock,
+		},
+		{
+			name:       "stock split across warehouses is not combined",
+			strategy:   "most-stock",
+			req:        Request{Quantity: 12},
+			warehouses: []Candidate{berlin, munich},
+			wantErr:    ErrInsufficientStock,
+		},
+		{
+			name:     "no warehouses",
+			strategy: "nearest",
+			req:      Request{Quantity: 1},
+			wantErr:  ErrInsufficientStock,
+		},
+	}
+	for _, tt := range tests {
+		strategy, ok := Lookup(tt.strategy)
+		if !ok {
+			t.Fatalf("%s: strategy %q is not registered", tt.name, tt.strategy)
+		}
+		got, err := Allocate(strategy, tt.req, tt.warehouses)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: Allocate error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Allocate error = %v", tt.name, err)
+		} else if got.Code != tt.want {
+			t.Errorf("%s: Allocate = %s, want %s", tt.name, got.Code, tt.want)
+		}
+	}
+}
+
+func TestAllocateKeepsWarehouses(t *testing.T) {
+	warehouses := []Candidate{warehouse("B", "", "", 2, 5), warehouse("A", "", "", 1, 5)}
+	if _, err := Allocate(StrategyFunc(byPriority), Request{Quantity: 1}, warehouses); err != nil {
+		t.Fatalf("Allocate: %v", err)
+	}
+	if warehouses[0].Code != "B" {
+		t.Errorf("Allocate reordered the caller's warehouses")
+	}
+}
+
+func TestStrategyName(t *testing.T) {
+	Register("test-first", StrategyFunc(func(req Request, candidates []Candidate) Candidate { return candidates[0] }))
+
+	tests := []struct {
+		env     string
+		want    string
+		wantErr bool
+	}{
+		{env: "", want: DefaultStrategy},
+		{env: "most-stock", want: "most-stock"},
+		{env: "test-first", want: "test-first"},
+		{env: "cheapest", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Setenv("ALLOCATION_STRATEGY", tt.env)
+		got, err := StrategyName()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("StrategyName() with %q = %q, %v; want %q, error %v", tt.env, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
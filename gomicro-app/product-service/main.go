@@ -3,11 +3,20 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/ozturkeniss/gomicro-app/common/serviceauth"
+	"github.com/ozturkeniss/gomicro-app/common/tenant"
+	"github.com/ozturkeniss/gomicro-app/product-service/alerts"
 	"github.com/ozturkeniss/gomicro-app/product-service/database"
 	"github.com/ozturkeniss/gomicro-app/product-service/handlers"
+	"github.com/ozturkeniss/gomicro-app/product-service/inventory"
+	"github.com/ozturkeniss/gomicro-app/product-service/notify"
+	"github.com/ozturkeniss/gomicro-app/product-service/pricing"
+	"github.com/ozturkeniss/gomicro-app/product-service/storage"
 )
 
 // HealthCheck handles the health check endpoint
@@ -20,6 +29,15 @@ func HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 }
 
+// intervalFromEnv reads a worker interval from the named variable, falling back
+// to the given default when it's unset or invalid
+func intervalFromEnv(name string, fallback time.Duration) time.Duration {
+	if interval, err := time.ParseDuration(os.Getenv(name)); err == nil && interval > 0 {
+		return interval
+	}
+	return fallback
+}
+
 func main() {
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
@@ -29,25 +47,132 @@ func main() {
 	// Initialize database
 	database.InitDB()
 
+	// Imports don't survive a restart; report the ones that were cut short
+	if err := handlers.FailInterruptedImports(); err != nil {
+		log.Fatal("Failed to clean up interrupted imports: ", err)
+	}
+
+	// Initialize blob storage for product images
+	storage.InitStore()
+
+	// Deliver low-stock alerts through the configured notifiers
+	notify.Init()
+	alerts.StartDispatchWorker(database.DB, intervalFromEnv("ALERT_DISPATCH_INTERVAL", 30*time.Second))
+
+	// Apply scheduled prices once they take effect
+	pricing.StartScheduler(database.DB, intervalFromEnv("PRICE_SCHEDULE_INTERVAL", time.Minute))
+
+	// Fail fast on a misconfigured warehouse allocation strategy
+	if _, err := inventory.StrategyName(); err != nil {
+		log.Fatal("Invalid allocation strategy: ", err)
+	}
+
 	// Create Gin router
 	router := gin.Default()
 
+	// Serve images kept on the local filesystem
+	if local, ok := storage.Store.(*storage.LocalStore); ok {
+		router.Static(storage.LocalMediaPath, local.Dir)
+	}
+
 	// Define API routes
 	api := router.Group("/api")
 	{
 		// Health check endpoint
 		api.GET("/health", HealthCheck)
 
-		products := api.Group("/products")
+		// Only owners and admins change the catalog, stock and warehouses
+		manage := tenant.RequireManager()
+
+		// OAuth clients need the products scopes
+		readCatalog, writeCatalog := tenant.RequireScope("products:read"), tenant.RequireScope("products:write")
+
+		products := api.Group("/products", tenant.Middleware())
 		{
-			products.POST("/", handlers.CreateProduct)
-			products.GET("/:id", handlers.GetProduct)
-			products.PUT("/:id", handlers.UpdateProduct)
-			products.DELETE("/:id", handlers.DeleteProduct)
-			products.GET("/", handlers.ListProducts)
-			products.GET("/search", handlers.SearchProducts)
-			products.PUT("/:id/stock", handlers.UpdateStock)
+			products.POST("/", writeCatalog, manage, handlers.CreateProduct)
+			products.GET("/:id", readCatalog, handlers.GetProduct)
+			products.PUT("/:id", writeCatalog, manage, handlers.UpdateProduct)
+			products.DELETE("/:id", writeCatalog, manage, handlers.DeleteProduct)
+			products.GET("/", readCatalog, handlers.ListProducts)
+			products.GET("/search", readCatalog, handlers.SearchProducts)
+			products.GET("/low-stock", readCatalog, handlers.ListLowStockProducts)
+			products.POST("/import", writeCatalog, manage, handlers.ImportProducts)
+			products.GET("/import/:jobId", readCatalog, handlers.GetImportJob)
+			products.GET("/export", readCatalog, handlers.ExportProducts)
+			products.PUT("/:id/stock", writeCatalog, manage, handlers.UpdateStock)
+			products.GET("/:id/availability", readCatalog, handlers.GetAvailability)
+			products.GET("/:id/price", readCatalog, handlers.GetPriceAt)
+			products.GET("/:id/prices", readCatalog, handlers.ListPriceHistory)
+			products.GET("/:id/scheduled-prices", readCatalog, handlers.ListScheduledPrices)
+			products.POST("/:id/scheduled-prices", writeCatalog, manage, handlers.CreateScheduledPrice)
+			products.DELETE("/:id/scheduled-prices/:scheduleId", writeCatalog, manage, handlers.CancelScheduledPrice)
+			products.PUT("/:id/categories", writeCatalog, manage, handlers.SetProductCategories)
+			products.GET("/:id/variants", readCatalog, handlers.ListVariants)
+			products.POST("/:id/variants", writeCatalog, manage, handlers.CreateVariant)
+			products.GET("/:id/variants/:variantId", readCatalog, handlers.GetVariant)
+			products.PUT("/:id/variants/:variantId", writeCatalog, manage, handlers.UpdateVariant)
+			products.DELETE("/:id/variants/:variantId", writeCatalog, manage, handlers.DeleteVariant)
+			products.PUT("/:id/variants/:variantId/stock", writeCatalog, manage, handlers.UpdateVariantStock)
+			products.GET("/:id/images", readCatalog, handlers.ListImages)
+			products.POST("/:id/images", writeCatalog, manage, handlers.UploadImage)
+			products.PUT("/:id/images/order", writeCatalog, manage, handlers.ReorderImages)
+			products.PUT("/:id/images/:imageId", writeCatalog, manage, handlers.UpdateImage)
+			products.DELETE("/:id/images/:imageId", writeCatalog, manage, handlers.DeleteImage)
+			products.GET("/:id/reviews", readCatalog, handlers.ListReviews)
+			products.POST("/:id/reviews", writeCatalog, handlers.CreateReview)
+			products.GET("/:id/reviews/:reviewId", readCatalog, handlers.GetReview)
+			products.PUT("/:id/reviews/:reviewId", writeCatalog, handlers.UpdateReview)
+			products.DELETE("/:id/reviews/:reviewId", writeCatalog, handlers.DeleteReview)
+			products.PUT("/:id/reviews/:reviewId/moderation", writeCatalog, handlers.ModerateReview)
+			products.PUT("/:id/reviews/:reviewId/vote", writeCatalog, handlers.VoteReview)
+			products.DELETE("/:id/reviews/:reviewId/vote", writeCatalog, handlers.UnvoteReview)
 		}
+
+		api.GET("/reviews", tenant.Middleware(), readCatalog, handlers.ListModerationQueue)
+
+		categories := api.Group("/categories", tenant.Middleware())
+		{
+			categories.POST("/", writeCatalog, manage, handlers.CreateCategory)
+			categories.GET("/", readCatalog, handlers.ListCategories)
+			categories.GET("/:id", readCatalog, handlers.GetCategory)
+			categories.PUT("/:id", writeCatalog, manage, handlers.UpdateCategory)
+			categories.DELETE("/:id", writeCatalog, manage, handlers.DeleteCategory)
+			categories.GET("/:id/products", readCatalog, handlers.ListCategoryProducts)
+		}
+
+		attributes := api.Group("/attributes", tenant.Middleware())
+		{
+			attributes.POST("/", writeCatalog, manage, handlers.CreateAttribute)
+			attributes.GET("/", readCatalog, handlers.ListAttributes)
+			attributes.GET("/:id", readCatalog, handlers.GetAttribute)
+			attributes.PUT("/:id", writeCatalog, manage, handlers.UpdateAttribute)
+			attributes.DELETE("/:id", writeCatalog, manage, handlers.DeleteAttribute)
+		}
+
+		warehouses := api.Group("/warehouses", tenant.Middleware())
+		{
+			warehouses.POST("/", writeCatalog, manage, handlers.CreateWarehouse)
+			warehouses.GET("/", readCatalog, handlers.ListWarehouses)
+			warehouses.POST("/transfers", writeCatalog, manage, handlers.CreateTransfer)
+			warehouses.GET("/transfers", readCatalog, handlers.ListTransfers)
+			warehouses.GET("/:id", readCatalog, handlers.GetWarehouse)
+			warehouses.PUT("/:id", writeCatalog, manage, handlers.UpdateWarehouse)
+			warehouses.DELETE("/:id", writeCatalog, manage, handlers.DeleteWarehouse)
+			warehouses.GET("/:id/stock", readCatalog, handlers.ListWarehouseStock)
+			warehouses.PUT("/:id/stock", writeCatalog, manage, handlers.SetWarehouseStock)
+		}
+
+		api.GET("/exchange-rates", tenant.Middleware(), readCatalog, handlers.ListExchangeRates)
+	}
+
+	// Operator and service-to-service endpoints authenticated with the shared service token
+	internal := router.Group("/internal", serviceauth.Middleware())
+	{
+		internal.PUT("/exchange-rates", handlers.SetExchangeRates)
+		internal.POST("/allocations", handlers.AllocateStock)
+		internal.POST("/allocations/:orderId/commit", handlers.CommitAllocation)
+		internal.POST("/allocations/:orderId/release", handlers.ReleaseAllocation)
+		internal.POST("/users/:userId/anonymize", handlers.AnonymizeUserReviews)
 	}
 
 	// Start the server
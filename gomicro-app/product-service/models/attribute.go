@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attribute types
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeNumber  = "number"
+	AttributeTypeBoolean = "boolean"
+	AttributeTypeEnum    = "enum"
+)
+
+// ValidAttributeType reports whether t is a supported attribute type
+func ValidAttributeType(t string) bool {
+	switch t {
+	case AttributeTypeString, AttributeTypeNumber, AttributeTypeBoolean, AttributeTypeEnum:
+		return true
+	}
+	return false
+}
+
+// Attributes holds a product's custom attribute values keyed by definition key
+type Attributes map[string]interface{}
+
+// AttributeDefinition declares a custom product attribute and how its values are validated
+type AttributeDefinition struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID  uuid.UUID `gorm:"type:uuid;not null;index;uniqueIndex:idx_attribute_definitions_tenant_key" json:"tenant_id"`
+	Key       string    `gorm:"size:64;not null;uniqueIndex:idx_attribute_definitions_tenant_key" json:"key"`
+	Name      string    `gorm:"size:255;not null" json:"name"`
+	Type      string    `gorm:"size:20;not null" json:"type"`
+	Required  bool      `gorm:"not null;default:false" json:"required"`
+	Unit      string    `gorm:"size:20" json:"unit,omitempty"`
+	Values    []string  `gorm:"serializer:json;type:jsonb" json:"values,omitempty"`
+	Min       *float64  `json:"min,omitempty"`
+	Max       *float64  `json:"max,omitempty"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the AttributeDefinition model
+func (AttributeDefinition) TableName() string {
+	return "attribute_definitions"
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Category is a node in a tenant's category tree. Path holds the IDs from the root
+// down to the category itself ("/<root>/<child>/"), so a subtree is a prefix match.
+type Category struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID  uuid.UUID  `gorm:"type:uuid;not null;index;uniqueIndex:idx_categories_tenant_slug" json:"tenant_id"`
+	ParentID  *uuid.UUID `gorm:"type:uuid;index" json:"parent_id"`
+	Name      string     `gorm:"size:255;not null" json:"name"`
+	Slug      string     `gorm:"size:255;not null;uniqueIndex:idx_categories_tenant_slug" json:"slug"`
+	Position  int        `gorm:"not null;default:0" json:"position"`
+	Path      string     `gorm:"size:2000;not null;index" json:"path"`
+	CreatedAt time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"not null" json:"updated_at"`
+
+	Children []Category `gorm:"-" json:"children,omitempty"`
+}
+
+// TableName specifies the table name for the Category model
+func (Category) TableName() string {
+	return "categories"
+}
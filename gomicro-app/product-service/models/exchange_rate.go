@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Exchange rate sources
+const (
+	RateSourceFile = "file"
+	RateSourceAPI  = "api"
+)
+
+// ExchangeRate is the value of one unit of Base in Quote. Rates are shared by all tenants.
+type ExchangeRate struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Base      string    `gorm:"type:char(3);not null;uniqueIndex:idx_exchange_rates_pair" json:"base"`
+	Quote     string    `gorm:"type:char(3);not null;uniqueIndex:idx_exchange_rates_pair" json:"quote"`
+	Rate      string    `gorm:"type:numeric(24,10);not null" json:"rate"`
+	Source    string    `gorm:"size:10;not null" json:"source"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the ExchangeRate model
+func (ExchangeRate) TableName() string {
+	return "exchange_rates"
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Import job states
+const (
+	ImportPending   = "pending"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// Import and export formats
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// MaxImportRowErrors caps how many row errors a job keeps; later failures are only counted
+const MaxImportRowErrors = 1000
+
+// ImportRowError reports why a row of an import was rejected
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	SKU     string `json:"sku,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportJob tracks a bulk product import running in the background. Rows are
+// counted as they are read, so Rows grows until the job finishes.
+type ImportJob struct {
+	ID         uuid.UUID        `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID   uuid.UUID        `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	UserID     string           `gorm:"size:64" json:"user_id,omitempty"`
+	Format     string           `gorm:"size:10;not null" json:"format"`
+	DryRun     bool             `gorm:"not null" json:"dry_run"`
+	Status     string           `gorm:"size:20;not null;index" json:"status"`
+	Rows       int              `gorm:"not null" json:"rows"`
+	Created    int              `gorm:"not null" json:"created"`
+	Updated    int              `gorm:"not null" json:"updated"`
+	Failed     int              `gorm:"not null" json:"failed"`
+	RowErrors  []ImportRowError `gorm:"serializer:json;type:jsonb" json:"row_errors"`
+	Error      string           `gorm:"size:1000" json:"error,omitempty"`
+	StartedAt  *time.Time       `json:"started_at,omitempty"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	CreatedAt  time.Time        `gorm:"not null" json:"created_at"`
+	UpdatedAt  time.Time        `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the ImportJob model
+func (ImportJob) TableName() string {
+	return "import_jobs"
+}
+
+// RecordRowError counts a rejected row and keeps its error if there is room
+func (j *ImportJob) RecordRowError(row int, sku, message string) {
+	j.Failed++
+	if len(j.RowErrors) < MaxImportRowErrors {
+		j.RowErrors = append(j.RowErrors, ImportRowError{Row: row, SKU: sku, Message: message})
+	}
+}
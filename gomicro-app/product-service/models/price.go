@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+)
+
+// Price change sources
+const (
+	PriceSourceInitial   = "initial"
+	PriceSourceManual    = "manual"
+	PriceSourceImport    = "import"
+	PriceSourceScheduled = "scheduled"
+)
+
+// Scheduled price states. Pending prices are applied by the scheduler once
+// they take effect, unless cancelled first.
+const (
+	ScheduledPricePending   = "pending"
+	ScheduledPriceApplied   = "applied"
+	ScheduledPriceCancelled = "cancelled"
+)
+
+// PriceChange records a product's price from EffectiveAt until the next change.
+// A row is written every time the price changes, however it was changed.
+type PriceChange struct {
+	ID               uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID         uuid.UUID   `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	ProductID        uuid.UUID   `gorm:"type:uuid;not null;index:idx_price_changes_product_effective" json:"product_id"`
+	Price            money.Money `gorm:"embedded;embeddedPrefix:price_" json:"price"`
+	Source           string      `gorm:"size:16;not null" json:"source"`
+	ScheduledPriceID *uuid.UUID  `gorm:"type:uuid" json:"scheduled_price_id,omitempty"`
+	EffectiveAt      time.Time   `gorm:"not null;index:idx_price_changes_product_effective" json:"effective_at"`
+	CreatedAt        time.Time   `gorm:"not null" json:"created_at"`
+}
+
+// TableName specifies the table name for the PriceChange model
+func (PriceChange) TableName() string {
+	return "price_changes"
+}
+
+// ScheduledPrice is a price a product takes at a future time
+type ScheduledPrice struct {
+	ID          uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID    uuid.UUID   `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	ProductID   uuid.UUID   `gorm:"type:uuid;not null;index" json:"product_id"`
+	Price       money.Money `gorm:"embedded;embeddedPrefix:price_" json:"price"`
+	EffectiveAt time.Time   `gorm:"not null;index" json:"effective_at"`
+	Status      string      `gorm:"size:16;not null;index" json:"status"`
+	AppliedAt   *time.Time  `json:"applied_at,omitempty"`
+	CreatedAt   time.Time   `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time   `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the ScheduledPrice model
+func (ScheduledPrice) TableName() string {
+	return "scheduled_prices"
+}
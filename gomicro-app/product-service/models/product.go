@@ -4,22 +4,64 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
 	"gorm.io/gorm"
 )
 
 // Product represents the product model
 type Product struct {
 	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	TenantID    uuid.UUID      `gorm:"type:uuid;not null;index;uniqueIndex:idx_products_tenant_sku"`
+	SKU         string         `gorm:"size:64;not null;default:'';uniqueIndex:idx_products_tenant_sku,where:sku <> '' AND deleted_at IS NULL"`
 	Name        string         `gorm:"size:255;not null"`
 	Description string         `gorm:"size:1000;not null"`
-	Price       float64        `gorm:"not null"`
+	Price       money.Money    `gorm:"embedded;embeddedPrefix:price_"`
 	Stock       int            `gorm:"not null"`
+	TaxClass    string         `gorm:"size:32;not null;default:'standard'"`
+	WeightGrams int            `gorm:"not null;default:0"`
+	Options     []string       `gorm:"serializer:json;type:jsonb"`
+	Attributes  Attributes     `gorm:"serializer:json;type:jsonb;index:idx_products_attributes,type:gin"`
 	CreatedAt   time.Time      `gorm:"not null"`
 	UpdatedAt   time.Time      `gorm:"not null"`
 	DeletedAt   gorm.DeletedAt `gorm:"index"`
+
+	// ReorderPoint is the stock level at or below which the product is low on
+	// stock; nil disables low-stock alerts. ReorderQuantity is how much to reorder.
+	ReorderPoint    *int
+	ReorderQuantity int `gorm:"not null;default:0"`
+
+	// Rating aggregates of approved reviews, kept up to date as reviews change
+	RatingAverage float64 `gorm:"type:numeric(3,2);not null;default:0"`
+	RatingCount   int     `gorm:"not null;default:0"`
+
+	// Associations are managed through their own endpoints, never inline
+	Categories []Category `gorm:"many2many:product_categories" json:",omitempty"`
+	Variants   []Variant  `gorm:"foreignKey:ProductID" json:",omitempty"`
+
+	// Images is the product's gallery in order
+	Images []ProductImage `gorm:"foreignKey:ProductID" json:",omitempty"`
+
+	// SearchVector is maintained by a database trigger for full-text search
+	SearchVector string `gorm:"type:tsvector;->:false;<-:false" json:"-"`
+
+	// ConvertedPrice is the price in the currency a read asked for; it is not stored
+	ConvertedPrice *money.Money `gorm:"-" json:",omitempty"`
+
+	// Relevance and highlighted fragments, only selected by full-text searches
+	SearchRank float32 `gorm:"->;-:migration" json:",omitempty"`
+	Highlight  string  `gorm:"->;-:migration" json:",omitempty"`
+	Snippet    string  `gorm:"->;-:migration" json:",omitempty"`
 }
 
 // TableName specifies the table name for the Product model
 func (Product) TableName() string {
 	return "products"
-} 
\ No newline at end of file
+}
+
+// DefaultTaxClass is the tax class of products created without one
+const DefaultTaxClass = "standard"
+
+// LowOnStock reports whether the product's stock is at or below its reorder point
+func (p Product) LowOnStock() bool {
+	return p.ReorderPoint != nil && p.Stock <= *p.ReorderPoint
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Rendition is a stored, resized copy of a product image
+type Rendition struct {
+	Size   int    `json:"size"`
+	Key    string `json:"key"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Thumbnail is a rendition as clients see it
+type Thumbnail struct {
+	Size   int    `json:"size"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	URL    string `json:"url"`
+}
+
+// ProductImage is an image in a product's gallery. The original and its
+// thumbnails live in blob storage under Key and the renditions' keys.
+type ProductImage struct {
+	ID          uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID    uuid.UUID   `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	ProductID   uuid.UUID   `gorm:"type:uuid;not null;index" json:"product_id"`
+	Position    int         `gorm:"not null" json:"position"`
+	Key         string      `gorm:"size:255;not null" json:"-"`
+	ContentType string      `gorm:"size:50;not null" json:"content_type"`
+	Width       int         `gorm:"not null" json:"width"`
+	Height      int         `gorm:"not null" json:"height"`
+	Size        int64       `gorm:"not null" json:"size"`
+	AltText     string      `gorm:"size:255" json:"alt_text"`
+	Renditions  []Rendition `gorm:"serializer:json;type:jsonb" json:"-"`
+	CreatedAt   time.Time   `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time   `gorm:"not null" json:"updated_at"`
+
+	// URL and Thumbnails are filled in by ResolveURLs; they are not stored
+	URL        string      `gorm:"-" json:"url"`
+	Thumbnails []Thumbnail `gorm:"-" json:"thumbnails"`
+}
+
+// TableName specifies the table name for the ProductImage model
+func (ProductImage) TableName() string {
+	return "product_images"
+}
+
+// Keys lists the blob keys of the original and every rendition
+func (i *ProductImage) Keys() []string {
+	keys := []string{i.Key}
+	for _, rendition := range i.Renditions {
+		keys = append(keys, rendition.Key)
+	}
+	return keys
+}
+
+// ResolveURLs fills in URL and Thumbnails using urlFor to map blob keys to URLs
+func (i *ProductImage) ResolveURLs(urlFor func(key string) string) {
+	i.URL = urlFor(i.Key)
+	i.Thumbnails = make([]Thumbnail, len(i.Renditions))
+	for n, rendition := range i.Renditions {
+		i.Thumbnails[n] = Thumbnail{Size: rendition.Size, Width: rendition.Width, Height: rendition.Height, URL: urlFor(rendition.Key)}
+	}
+}
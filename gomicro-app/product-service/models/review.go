@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Review moderation states. Only approved reviews are public and count towards
+// the product's rating.
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// Review is a customer's rating of a product they received. Each user reviews a
+// product at most once.
+type Review struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	ProductID       uuid.UUID  `gorm:"type:uuid;not null;index;uniqueIndex:idx_reviews_product_user" json:"product_id"`
+	UserID          uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_reviews_product_user" json:"user_id"`
+	OrderID         uuid.UUID  `gorm:"type:uuid;not null" json:"order_id"`
+	Rating          int        `gorm:"not null" json:"rating"`
+	Title           string     `gorm:"size:150;not null" json:"title"`
+	Body            string     `gorm:"size:5000;not null" json:"body"`
+	Status          string     `gorm:"size:20;not null;default:pending;index" json:"status"`
+	ModerationNote  string     `gorm:"size:500" json:"moderation_note,omitempty"`
+	ModeratedBy     *uuid.UUID `gorm:"type:uuid" json:"moderated_by,omitempty"`
+	ModeratedAt     *time.Time `json:"moderated_at,omitempty"`
+	HelpfulCount    int        `gorm:"not null;default:0" json:"helpful_count"`
+	NotHelpfulCount int        `gorm:"not null;default:0" json:"not_helpful_count"`
+	CreatedAt       time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt       time.Time  `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the Review model
+func (Review) TableName() string {
+	return "reviews"
+}
+
+// ValidReviewStatus reports whether status is a known moderation state
+func ValidReviewStatus(status string) bool {
+	return status == ReviewPending || status == ReviewApproved || status == ReviewRejected
+}
+
+// ReviewVote records whether a user found a review helpful
+type ReviewVote struct {
+	ReviewID  uuid.UUID `gorm:"type:uuid;primary_key" json:"review_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;primary_key" json:"user_id"`
+	TenantID  uuid.UUID `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	Helpful   bool      `gorm:"not null" json:"helpful"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the ReviewVote model
+func (ReviewVote) TableName() string {
+	return "review_votes"
+}
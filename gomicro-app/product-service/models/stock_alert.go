@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Stock alert types
+const (
+	StockAlertLow       = "stock.low"
+	StockAlertRestocked = "stock.restocked"
+)
+
+// StockAlert records a product's stock crossing its reorder point. Alerts are
+// written in the same transaction as the stock change and delivered to the
+// notifiers afterwards, so none are lost or sent for changes that rolled back.
+type StockAlert struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	ProductID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"product_id"`
+	Type         string     `gorm:"size:32;not null" json:"type"`
+	SKU          string     `gorm:"size:64;not null" json:"sku,omitempty"`
+	Name         string     `gorm:"size:255;not null" json:"name"`
+	Stock        int        `gorm:"not null" json:"stock"`
+	ReorderPoint int        `gorm:"not null" json:"reorder_point"`
+	Attempts     int        `gorm:"not null;default:0" json:"-"`
+	LastError    string     `gorm:"size:1000" json:"-"`
+	DeliveredAt  *time.Time `gorm:"index" json:"-"`
+	CreatedAt    time.Time  `gorm:"not null" json:"created_at"`
+}
+
+// TableName specifies the table name for the StockAlert model
+func (StockAlert) TableName() string {
+	return "stock_alerts"
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/money"
+	"gorm.io/gorm"
+)
+
+// Variant is a sellable SKU of a product, identified by a value for each of the
+// product's option axes (e.g. {"size": "M", "color": "red"})
+type Variant struct {
+	ID            uuid.UUID         `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID      uuid.UUID         `gorm:"type:uuid;not null;index;uniqueIndex:idx_variants_tenant_sku" json:"tenant_id"`
+	ProductID     uuid.UUID         `gorm:"type:uuid;not null;index" json:"product_id"`
+	SKU           string            `gorm:"size:64;not null;uniqueIndex:idx_variants_tenant_sku,where:deleted_at IS NULL" json:"sku"`
+	Options       map[string]string `gorm:"serializer:json;type:jsonb;not null" json:"options"`
+	PriceOverride *money.Amount     `gorm:"type:numeric(19,4)" json:"-"`
+	Stock         int               `gorm:"not null" json:"stock"`
+	Barcode       string            `gorm:"size:14;index" json:"barcode,omitempty"`
+	CreatedAt     time.Time         `gorm:"not null" json:"created_at"`
+	UpdatedAt     time.Time         `gorm:"not null" json:"updated_at"`
+	DeletedAt     gorm.DeletedAt    `gorm:"index" json:"-"`
+
+	// Price is the effective price, filled in by ResolvePrice; it is not stored
+	Price           money.Money `gorm:"-" json:"price"`
+	PriceOverridden bool        `gorm:"-" json:"price_overridden"`
+}
+
+// TableName specifies the table name for the Variant model
+func (Variant) TableName() string {
+	return "variants"
+}
+
+// ResolvePrice sets Price to the override, in the product's currency, or to the product price
+func (v *Variant) ResolvePrice(productPrice money.Money) {
+	v.Price = productPrice
+	v.PriceOverridden = v.PriceOverride != nil
+	if v.PriceOverride != nil {
+		v.Price.Amount = *v.PriceOverride
+	}
+}
+
+// MatchesOptions reports whether the variant has exactly the given option values
+func (v Variant) MatchesOptions(options map[string]string) bool {
+	if len(v.Options) != len(options) {
+		return false
+	}
+	for axis, value := range options {
+		if v.Options[axis] != value {
+			return false
+		}
+	}
+	return true
+}
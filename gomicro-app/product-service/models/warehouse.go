@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Allocation states. Reserved stock is held for an order until it ships
+// (committed) or is cancelled (released).
+const (
+	AllocationReserved  = "reserved"
+	AllocationCommitted = "committed"
+	AllocationReleased  = "released"
+)
+
+// Warehouse is a location the tenant holds stock in
+type Warehouse struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID  uuid.UUID `gorm:"type:uuid;not null;index;uniqueIndex:idx_warehouses_tenant_code" json:"tenant_id"`
+	Code      string    `gorm:"size:32;not null;uniqueIndex:idx_warehouses_tenant_code" json:"code"`
+	Name      string    `gorm:"size:255;not null" json:"name"`
+	Country   string    `gorm:"size:2;not null;default:''" json:"country"`
+	Region    string    `gorm:"size:100;not null;default:''" json:"region"`
+	Priority  int       `gorm:"not null;default:0" json:"priority"`
+	Active    bool      `gorm:"not null" json:"active"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the Warehouse model
+func (Warehouse) TableName() string {
+	return "warehouses"
+}
+
+// StockLevel is the stock of a product, or of one of its variants, held in a
+// warehouse. VariantID is uuid.Nil for products without variants.
+type StockLevel struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	WarehouseID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_stock_levels_item" json:"warehouse_id"`
+	Warehouse   *Warehouse `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
+	ProductID   uuid.UUID  `gorm:"type:uuid;not null;index;uniqueIndex:idx_stock_levels_item" json:"product_id"`
+	VariantID   uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_stock_levels_item" json:"variant_id"`
+	OnHand      int        `gorm:"not null" json:"on_hand"`
+	Reserved    int        `gorm:"not null;default:0" json:"reserved"`
+	CreatedAt   time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"not null" json:"updated_at"`
+
+	// Available is the stock that can still be promised, filled in by
+	// ResolveAvailable; it is not stored
+	Available int `gorm:"-" json:"available"`
+}
+
+// TableName specifies the table name for the StockLevel model
+func (StockLevel) TableName() string {
+	return "stock_levels"
+}
+
+// ResolveAvailable sets Available to the stock on hand that isn't reserved
+func (s *StockLevel) ResolveAvailable() {
+	s.Available = s.OnHand - s.Reserved
+}
+
+// StockTransfer records stock moved from one warehouse to another
+type StockTransfer struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID        uuid.UUID `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	FromWarehouseID uuid.UUID `gorm:"type:uuid;not null;index" json:"from_warehouse_id"`
+	ToWarehouseID   uuid.UUID `gorm:"type:uuid;not null;index" json:"to_warehouse_id"`
+	ProductID       uuid.UUID `gorm:"type:uuid;not null;index" json:"product_id"`
+	VariantID       uuid.UUID `gorm:"type:uuid;not null" json:"variant_id"`
+	Quantity        int       `gorm:"not null" json:"quantity"`
+	Note            string    `gorm:"size:500" json:"note,omitempty"`
+	UserID          string    `gorm:"size:64" json:"user_id,omitempty"`
+	CreatedAt       time.Time `gorm:"not null" json:"created_at"`
+}
+
+// TableName specifies the table name for the StockTransfer model
+func (StockTransfer) TableName() string {
+	return "stock_transfers"
+}
+
+// Allocation is the stock an order ships from, chosen by the allocation strategy
+type Allocation struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TenantID    uuid.UUID `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	OrderID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"order_id"`
+	WarehouseID uuid.UUID `gorm:"type:uuid;not null;index" json:"warehouse_id"`
+	ProductID   uuid.UUID `gorm:"type:uuid;not null" json:"product_id"`
+	VariantID   uuid.UUID `gorm:"type:uuid;not null" json:"variant_id"`
+	Quantity    int       `gorm:"not null" json:"quantity"`
+	Strategy    string    `gorm:"size:32;not null" json:"strategy"`
+	Status      string    `gorm:"size:20;not null" json:"status"`
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the Allocation model
+func (Allocation) TableName() string {
+	return "allocations"
+}
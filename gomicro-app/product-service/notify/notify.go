@@ -0,0 +1,144 @@
+// Package notify delivers operational events, such as low-stock alerts, to the
+// sinks selected with NOTIFIERS
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event is something the tenant's staff should hear about
+type Event struct {
+	ID         uuid.UUID   `json:"id"`
+	Type       string      `json:"type"`
+	TenantID   uuid.UUID   `json:"tenant_id"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+// Notifier sends events to a sink. Notify may be called again with the same
+// event if an earlier attempt failed.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// Factory builds a notifier from the environment
+type Factory func() (Notifier, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{
+		"log":  func() (Notifier, error) { return LogNotifier{}, nil },
+		"file": newFileNotifier,
+	}
+)
+
+// Default is the notifier built by Init
+var Default Notifier = LogNotifier{}
+
+// Register makes a notifier available under name for NOTIFIERS
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = factory
+}
+
+// Init builds Default from NOTIFIERS, a comma-separated list of registered
+// notifiers (default log). Events go to every listed notifier.
+func Init() {
+	names := os.Getenv("NOTIFIERS")
+	if names == "" {
+		names = "log"
+	}
+
+	var notifiers Multi
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		factory, ok := factories[name]
+		if !ok {
+			log.Fatalf("Unknown notifier %q in NOTIFIERS", name)
+		}
+		notifier, err := factory()
+		if err != nil {
+			log.Fatalf("Failed to initialize %s notifier: %v", name, err)
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	Default = notifiers
+	log.Printf("Notifiers initialized: %s", names)
+}
+
+// Multi sends each event to all of its notifiers, returning their joined errors
+type Multi []Notifier
+
+// Notify implements Notifier
+func (m Multi) Notify(ctx context.Context, event Event) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogNotifier writes events to the service log
+type LogNotifier struct{}
+
+// Notify implements Notifier
+func (LogNotifier) Notify(ctx context.Context, event Event) error {
+	log.Printf("[NOTICE] %s (tenant %s): %s", event.Type, event.TenantID, event.Message)
+	return nil
+}
+
+// FileNotifier appends events as JSON lines to a file
+type FileNotifier struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// newFileNotifier appends to NOTIFY_FILE, by default logs/notifications.log
+func newFileNotifier() (Notifier, error) {
+	path := os.Getenv("NOTIFY_FILE")
+	if path == "" {
+		path = "logs/notifications.log"
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileNotifier{Path: path}, file.Close()
+}
+
+// Notify implements Notifier
+func (f *FileNotifier) Notify(ctx context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("write %s: %w", f.Path, err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,161 @@
+// Package pricing keeps the price history of products and applies scheduled
+// price changes once they take effect
+package pricing
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/tenant"
+	"github.com/ozturkeniss/gomicro-app/product-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// batchSize is how many scheduled prices one run applies at most
+const batchSize = 100
+
+// ErrNoPrice is returned when a product had no recorded price at the asked time
+var ErrNoPrice = errors.New("product had no price at that time")
+
+// Record adds a price change in tx when the product's price differs from the
+// latest one recorded. Call it after saving the product, in the same transaction.
+func Record(tx *gorm.DB, product *models.Product, source string, scheduleID *uuid.UUID) error {
+	var latest models.PriceChange
+	err := tx.Where("product_id = ?", product.ID).Order("effective_at DESC, created_at DESC").Take(&latest).Error
+	if err == nil && latest.Price == product.Price {
+		return nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	return tx.Create(&models.PriceChange{
+		ID:               uuid.New(),
+		TenantID:         product.TenantID,
+		ProductID:        product.ID,
+		Price:            product.Price,
+		Source:           source,
+		ScheduledPriceID: scheduleID,
+		EffectiveAt:      time.Now(),
+	}).Error
+}
+
+// At returns the price change in effect for the product at the given time. For
+// times still to come, pending scheduled prices are taken into account too.
+func At(db *gorm.DB, productID uuid.UUID, at time.Time) (*models.PriceChange, error) {
+	var change models.PriceChange
+	err := db.Where("product_id = ? AND effective_at <= ?", productID, at).
+		Order("effective_at DESC, created_at DESC").Take(&change).Error
+	found := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if at.After(time.Now()) {
+		var schedule models.ScheduledPrice
+		err := db.Where("product_id = ? AND status = ? AND effective_at <= ?", productID, models.ScheduledPricePending, at).
+			Order("effective_at DESC, created_at DESC").Take(&schedule).Error
+		if err == nil && (!found || schedule.EffectiveAt.After(change.EffectiveAt)) {
+			return &models.PriceChange{
+				TenantID:         schedule.TenantID,
+				ProductID:        schedule.ProductID,
+				Price:            schedule.Price,
+				Source:           models.PriceSourceScheduled,
+				ScheduledPriceID: &schedule.ID,
+				EffectiveAt:      schedule.EffectiveAt,
+			}, nil
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	if !found {
+		return nil, ErrNoPrice
+	}
+	return &change, nil
+}
+
+// ApplyDue sets the price of products whose scheduled prices have taken effect,
+// oldest first, and returns how many were applied. Schedules for products that
+// were deleted or have since changed currency are cancelled. Schedules being
+// applied by another instance are skipped.
+func ApplyDue(ctx context.Context, db *gorm.DB) (int, error) {
+	applied := 0
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var due []models.ScheduledPrice
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND effective_at <= ?", models.ScheduledPricePending, time.Now()).
+			Order("effective_at").Limit(batchSize).
+			Find(&due).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range due {
+			ok, err := apply(tx.WithContext(tenant.WithTenant(ctx, due[i].TenantID)), &due[i])
+			if err != nil {
+				return err
+			}
+			if ok {
+				applied++
+			}
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// apply sets the product's price to the scheduled one, or cancels the schedule
+// when it no longer fits the product
+func apply(tx *gorm.DB, schedule *models.ScheduledPrice) (bool, error) {
+	var product models.Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&product, "id = ?", schedule.ProductID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if err != nil || product.Price.Currency != schedule.Price.Currency {
+		log.Printf("[WARN] Cancelling scheduled price %s: product %s was deleted or changed currency", schedule.ID, schedule.ProductID)
+		return false, tx.Model(schedule).Update("status", models.ScheduledPriceCancelled).Error
+	}
+
+	product.Price = schedule.Price
+	err = tx.Model(&product).Updates(map[string]interface{}{
+		"price_amount":   product.Price.Amount,
+		"price_currency": product.Price.Currency,
+	}).Error
+	if err != nil {
+		return false, err
+	}
+	if err := Record(tx, &product, models.PriceSourceScheduled, &schedule.ID); err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	return true, tx.Model(schedule).Updates(map[string]interface{}{
+		"status":     models.ScheduledPriceApplied,
+		"applied_at": now,
+	}).Error
+}
+
+// StartScheduler periodically applies scheduled prices that have taken effect
+func StartScheduler(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			applied, err := ApplyDue(context.Background(), db)
+			if err != nil {
+				log.Printf("[ERROR] Applying scheduled prices failed: %v", err)
+				continue
+			}
+			if applied > 0 {
+				log.Printf("Applied %d scheduled prices", applied)
+			}
+		}
+	}()
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// ErrInvalidKey is returned for keys that are empty or escape the store
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// BlobStore stores uploaded media under slash-separated keys and tells clients
+// where to fetch it from
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing any existing blob
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Delete removes the blob; deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+	// URL returns the address clients load the blob from
+	URL(key string) string
+}
+
+// Store is the blob store selected by InitStore
+var Store BlobStore
+
+// InitStore selects the blob store from STORAGE_DRIVER: local (the default) keeps
+// files under STORAGE_DIR, s3 uses an S3-compatible bucket configured by the S3_*
+// variables
+func InitStore() {
+	var err error
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		Store, err = NewLocalStore(envOr("STORAGE_DIR", "media"), envOr("STORAGE_BASE_URL", LocalMediaPath))
+	case "s3":
+		Store, err = NewS3Store(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          envOr("S3_REGION", "us-east-1"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PublicURL:       os.Getenv("S3_PUBLIC_URL"),
+		})
+	default:
+		err = fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
+	}
+	if err != nil {
+		log.Fatalf("Failed to initialize blob storage: %v", err)
+	}
+	log.Println("Blob storage initialized")
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -8,11 +8,31 @@ import (
 )
 
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID     string `json:"user_id"`
+	Email      string `json:"email"`
+	ClientID   string `json:"client_id,omitempty"`
+	Scope      string `json:"scope,omitempty"`
+	TenantID   string `json:"tenant_id,omitempty"`
+	TenantRole string `json:"tenant_role,omitempty"`
+	// Impersonation tokens name the admin acting as the user
+	Impersonated   bool   `json:"impersonated,omitempty"`
+	ImpersonatorID string `json:"impersonator_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// DefaultTenantTokenTTL is how long organization tokens last when
+// TENANT_JWT_EXPIRES_IN is not set. Product and order services can't see
+// suspensions or membership changes, so these tokens are kept short-lived.
+const DefaultTenantTokenTTL = 15 * time.Minute
+
+// TenantTokenTTL returns how long tokens carrying a tenant last
+func TenantTokenTTL() time.Duration {
+	if v, err := time.ParseDuration(os.Getenv("TENANT_JWT_EXPIRES_IN")); err == nil && v > 0 {
+		return v
+	}
+	return DefaultTenantTokenTTL
+}
+
 // GenerateJWT generates a JWT token for a user
 func GenerateJWT(userID, email string) (string, error) {
 	secret := os.Getenv("JWT_SECRET")
@@ -34,6 +54,78 @@ func GenerateJWT(userID, email string) (string, error) {
 	return token.SignedString([]byte(secret))
 }
 
+// GenerateTenantJWT generates a short-lived JWT token for a user acting within an
+// organization. The token expires no later than notAfter, the expiry of the
+// session it was issued from, and tokens issued while impersonating keep naming
+// the impersonating admin.
+func GenerateTenantJWT(userID, email, tenantID, tenantRole, impersonatorID string, notAfter time.Time) (string, error) {
+	secret := os.Getenv("JWT_SECRET")
+	expiresAt := time.Now().Add(TenantTokenTTL())
+	if !notAfter.IsZero() && notAfter.Before(expiresAt) {
+		expiresAt = notAfter
+	}
+	claims := Claims{
+		UserID:         userID,
+		Email:          email,
+		TenantID:       tenantID,
+		TenantRole:     tenantRole,
+		Impersonated:   impersonatorID != "",
+		ImpersonatorID: impersonatorID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
+// GenerateAccessToken generates a scoped OAuth access token for a client.
+// userID and email are empty for client-credentials tokens, and tenantID and
+// tenantRole are only set when the user authorized the client for an organization.
+func GenerateAccessToken(tokenID, userID, email, clientID, scope, tenantID, tenantRole string, expiresAt time.Time) (string, error) {
+	secret := os.Getenv("JWT_SECRET")
+	subject := userID
+	if subject == "" {
+		subject = clientID
+	}
+	claims := Claims{
+		UserID:     userID,
+		Email:      email,
+		ClientID:   clientID,
+		Scope:      scope,
+		TenantID:   tenantID,
+		TenantRole: tenantRole,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
+// GenerateImpersonationToken generates a short-lived token that lets an admin act as a user.
+// The token is marked as impersonated and names the admin, so it can't be mistaken for the user's own.
+func GenerateImpersonationToken(tokenID, userID, email, impersonatorID string, expiresAt time.Time) (string, error) {
+	secret := os.Getenv("JWT_SECRET")
+	claims := Claims{
+		UserID:         userID,
+		Email:          email,
+		Impersonated:   true,
+		ImpersonatorID: impersonatorID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
 // ValidateJWT validates a JWT token and returns the claims if valid
 func ValidateJWT(tokenString string) (*Claims, error) {
 	secret := os.Getenv("JWT_SECRET")
@@ -45,4 +137,4 @@ func ValidateJWT(tokenString string) (*Claims, error) {
 		return nil, err
 	}
 	return claims, nil
-} 
\ No newline at end of file
+}
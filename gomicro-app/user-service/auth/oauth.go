@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// SupportedScopes lists the scopes third-party clients may request
+var SupportedScopes = []string{
+	"profile",
+	"email",
+	"products:read",
+	"products:write",
+	"orders:read",
+	"orders:write",
+}
+
+// tenantScopePrefixes are the scopes enforced by product-service and
+// order-service, which only accept tokens issued for an organization
+var tenantScopePrefixes = []string{"products:", "orders:"}
+
+// ParseScope splits a space-delimited scope string into unique scopes
+func ParseScope(scope string) []string {
+	seen := make(map[string]bool)
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// ScopeSubset reports whether every scope in requested is also in allowed
+func ScopeSubset(requested, allowed string) bool {
+	granted := make(map[string]bool)
+	for _, s := range ParseScope(allowed) {
+		granted[s] = true
+	}
+	for _, s := range ParseScope(requested) {
+		if !granted[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// HasScope reports whether scope contains the given scope
+func HasScope(scope, want string) bool {
+	for _, s := range ParseScope(scope) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiresTenant reports whether scope grants access to product or order data,
+// so the token must be issued for one of the user's organizations
+func RequiresTenant(scope string) bool {
+	for _, s := range ParseScope(scope) {
+		for _, prefix := range tenantScopePrefixes {
+			if strings.HasPrefix(s, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidScopes reports whether every requested scope is supported
+func ValidScopes(scope string) bool {
+	return ScopeSubset(scope, strings.Join(SupportedScopes, " "))
+}
+
+// VerifyCodeChallenge checks a PKCE code verifier against an S256 challenge (RFC 7636)
+func VerifyCodeChallenge(verifier, challenge string) bool {
+	if verifier == "" || challenge == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// GenerateOpaqueToken returns a random URL-safe token of n bytes of entropy
+func GenerateOpaqueToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashOpaqueToken returns the SHA-256 hex digest used to store opaque tokens
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,62 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/serviceauth"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// ListUserOrders fetches every order placed by the user from order-service.
+// The orders are returned as raw JSON since user-service only passes them through.
+func ListUserOrders(ctx context.Context, userID uuid.UUID) (json.RawMessage, error) {
+	body, err := callOrderService(ctx, http.MethodGet, fmt.Sprintf("/internal/users/%s/orders", userID))
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(body), nil
+}
+
+// AnonymizeUserOrders asks order-service to strip personal data from the user's
+// orders, carts and payments
+func AnonymizeUserOrders(ctx context.Context, userID uuid.UUID) error {
+	_, err := callOrderService(ctx, http.MethodPost, fmt.Sprintf("/internal/users/%s/anonymize", userID))
+	return err
+}
+
+// callOrderService performs an internal request against order-service and returns the response body
+func callOrderService(ctx context.Context, method, path string) ([]byte, error) {
+	return callService(ctx, method, os.Getenv("ORDER_SERVICE_URL")+path)
+}
+
+// callService performs an internal request against another service and returns the response body
+func callService(ctx context.Context, method, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	serviceauth.Authorize(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: unexpected status %d", method, url, resp.StatusCode)
+	}
+	return body, nil
+}
@@ -0,0 +1,21 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/google/uuid"
+)
+
+// AnonymizeUserReviews asks product-service to strip personal data from the user's reviews
+func AnonymizeUserReviews(ctx context.Context, userID uuid.UUID) error {
+	_, err := callProductService(ctx, http.MethodPost, fmt.Sprintf("/internal/users/%s/anonymize", userID))
+	return err
+}
+
+// callProductService performs an internal request against product-service and returns the response body
+func callProductService(ctx context.Context, method, path string) ([]byte, error) {
+	return callService(ctx, method, os.Getenv("PRODUCT_SERVICE_URL")+path)
+}
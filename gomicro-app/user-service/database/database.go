@@ -35,6 +35,41 @@ func InitDB() {
 		log.Fatalf("Failed to auto migrate User model: %v", err)
 	}
 	log.Println("User table auto migrated successfully")
+
+	// AutoMigrate the OAuth models
+	err = DB.AutoMigrate(&models.OAuthClient{}, &models.OAuthAuthorizationCode{}, &models.OAuthConsent{}, &models.OAuthToken{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate OAuth models: %v", err)
+	}
+	log.Println("OAuth tables auto migrated successfully")
+
+	// AutoMigrate the Organization models
+	err = DB.AutoMigrate(&models.Organization{}, &models.Membership{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate Organization models: %v", err)
+	}
+	log.Println("Organization tables auto migrated successfully")
+
+	// AutoMigrate the Address model
+	err = DB.AutoMigrate(&models.Address{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate Address model: %v", err)
+	}
+	log.Println("Address table auto migrated successfully")
+
+	// AutoMigrate the ErasureRequest model
+	err = DB.AutoMigrate(&models.ErasureRequest{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate ErasureRequest model: %v", err)
+	}
+	log.Println("ErasureRequest table auto migrated successfully")
+
+	// AutoMigrate the AuditLog model
+	err = DB.AutoMigrate(&models.AuditLog{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate AuditLog model: %v", err)
+	}
+	log.Println("AuditLog table auto migrated successfully")
 }
 
 // TestDatabaseConnection tries to connect to the database and logs the result
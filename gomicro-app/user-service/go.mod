@@ -2,7 +2,11 @@ module github.com/ozturkeniss/gomicro-app/user-service
 
 go 1.24.2
 
-require github.com/micro/go-micro/v2 v2.9.1
+require (
+	github.com/google/uuid v1.1.1
+	github.com/micro/go-micro/v2 v2.9.1
+	golang.org/x/crypto v0.23.0
+)
 
 require (
 	github.com/BurntSushi/toml v0.3.1 // indirect
@@ -20,7 +24,6 @@ require (
 	github.com/go-git/go-git/v5 v5.1.0 // indirect
 	github.com/gogo/protobuf v1.2.1 // indirect
 	github.com/golang/protobuf v1.5.0 // indirect
-	github.com/google/uuid v1.1.1 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/hpcloud/tail v1.0.0 // indirect
 	github.com/imdario/mergo v0.3.9 // indirect
@@ -49,7 +52,6 @@ require (
 	go.uber.org/multierr v1.3.0 // indirect
 	go.uber.org/tools v0.0.0-20190618225709-2cfd321de3ee // indirect
 	go.uber.org/zap v1.13.0 // indirect
-	golang.org/x/crypto v0.23.0 // indirect
 	golang.org/x/lint v0.0.0-20191125180803-fdd1cda4f05f // indirect
 	golang.org/x/mod v0.17.0 // indirect
 	golang.org/x/net v0.25.0 // indirect
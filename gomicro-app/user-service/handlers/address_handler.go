@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/user-service/database"
+	"github.com/ozturkeniss/gomicro-app/user-service/models"
+	"github.com/ozturkeniss/gomicro-app/user-service/utils"
+	"gorm.io/gorm"
+)
+
+// ListAddresses lists the current user's address book
+func ListAddresses(c *gin.Context) {
+	var addresses []models.Address
+	result := database.DB.Where("user_id = ?", c.GetString("userID")).Order("created_at").Find(&addresses)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, addresses)
+}
+
+// GetAddress retrieves one of the current user's addresses
+func GetAddress(c *gin.Context) {
+	address, ok := loadAddress(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, address)
+}
+
+// CreateAddress adds an address to the current user's address book.
+// The first address becomes the default shipping and billing address.
+func CreateAddress(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user in token"})
+		return
+	}
+
+	var req models.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	address := models.Address{ID: uuid.New(), UserID: userID}
+	applyAddressRequest(&address, &req)
+	utils.NormalizeAddress(&address)
+	if err := utils.ValidateAddress(&address); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Address{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			address.IsDefaultShipping = true
+			address.IsDefaultBilling = true
+		}
+		if err := clearDefaultFlags(tx, &address); err != nil {
+			return err
+		}
+		return tx.Create(&address).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, address)
+}
+
+// UpdateAddress updates one of the current user's addresses
+func UpdateAddress(c *gin.Context) {
+	var req models.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	address, ok := loadAddress(c)
+	if !ok {
+		return
+	}
+
+	applyAddressRequest(address, &req)
+	utils.NormalizeAddress(address)
+	if err := utils.ValidateAddress(address); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := clearDefaultFlags(tx, address); err != nil {
+			return err
+		}
+		return tx.Save(address).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, address)
+}
+
+// DeleteAddress removes an address from the current user's address book.
+// Orders keep their own copy of the address, so they are not affected.
+func DeleteAddress(c *gin.Context) {
+	address, ok := loadAddress(c)
+	if !ok {
+		return
+	}
+
+	if result := database.DB.Delete(address); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Address deleted successfully"})
+}
+
+// loadAddress loads the address from the :addressId path parameter owned by the current user
+func loadAddress(c *gin.Context) (*models.Address, bool) {
+	addressID, err := uuid.Parse(c.Param("addressId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
+		return nil, false
+	}
+
+	var address models.Address
+	result := database.DB.Where("id = ? AND user_id = ?", addressID, c.GetString("userID")).First(&address)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+		return nil, false
+	}
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return nil, false
+	}
+	return &address, true
+}
+
+// applyAddressRequest copies the request fields onto the address
+func applyAddressRequest(address *models.Address, req *models.AddressRequest) {
+	address.Label = req.Label
+	address.RecipientName = req.RecipientName
+	address.Line1 = req.Line1
+	address.Line2 = req.Line2
+	address.City = req.City
+	address.Region = req.Region
+	address.PostalCode = req.PostalCode
+	address.Country = req.Country
+	address.Phone = req.Phone
+	address.IsDefaultShipping = req.IsDefaultShipping
+	address.IsDefaultBilling = req.IsDefaultBilling
+}
+
+// clearDefaultFlags unsets the default flags on the user's other addresses
+// for every flag the given address is taking over
+func clearDefaultFlags(tx *gorm.DB, address *models.Address) error {
+	others := tx.Model(&models.Address{}).Where("user_id = ? AND id <> ?", address.UserID, address.ID)
+	if address.IsDefaultShipping {
+		if err := others.Session(&gorm.Session{}).Update("is_default_shipping", false).Error; err != nil {
+			return err
+		}
+	}
+	if address.IsDefaultBilling {
+		if err := others.Session(&gorm.Session{}).Update("is_default_billing", false).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
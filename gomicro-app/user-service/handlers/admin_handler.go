@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/paging"
+	"github.com/ozturkeniss/gomicro-app/user-service/auth"
+	"github.com/ozturkeniss/gomicro-app/user-service/database"
+	"github.com/ozturkeniss/gomicro-app/user-service/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultImpersonationTTL = 15 * time.Minute
+	maxImpersonationTTL     = time.Hour
+)
+
+// adminUserView is the user representation returned by admin endpoints
+type adminUserView struct {
+	ID               uuid.UUID  `json:"id"`
+	Name             string     `json:"name"`
+	Email            string     `json:"email"`
+	Role             string     `json:"role"`
+	Status           string     `json:"status"`
+	SuspendedAt      *time.Time `json:"suspended_at,omitempty"`
+	SuspensionReason string     `json:"suspension_reason,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
+	ErasedAt         *time.Time `json:"erased_at,omitempty"`
+}
+
+func newAdminUserView(user models.User) adminUserView {
+	view := adminUserView{
+		ID:               user.ID,
+		Name:             user.Name,
+		Email:            user.Email,
+		Role:             user.Role,
+		Status:           user.Status,
+		SuspendedAt:      user.SuspendedAt,
+		SuspensionReason: user.SuspensionReason,
+		CreatedAt:        user.CreatedAt,
+		ErasedAt:         user.ErasedAt,
+	}
+	if user.DeletedAt.Valid {
+		view.DeletedAt = &user.DeletedAt.Time
+	}
+	return view
+}
+
+// SearchUsers searches users by name or email and status with paging.
+// status=deleted lists soft-deleted users so they can be restored.
+func SearchUsers(c *gin.Context) {
+	req, err := paging.FromRequest(c, userSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.DB.Model(&models.User{})
+	switch status := c.Query("status"); status {
+	case "":
+	case "deleted":
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	case models.UserStatusActive, models.UserStatusSuspended:
+		query = query.Where("status = ?", status)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(name) LIKE ? OR LOWER(email) LIKE ?", pattern, pattern)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	var users []models.User
+	result := req.Apply(query).Find(&users)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	// The cursor is taken from the loaded users before they're mapped to views
+	page, err := paging.NewPage(database.DB, req, users, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	views := make([]adminUserView, len(page.Data))
+	for i, user := range page.Data {
+		views[i] = newAdminUserView(user)
+	}
+
+	c.JSON(http.StatusOK, paging.Page[adminUserView]{Data: views, NextCursor: page.NextCursor, Total: page.Total})
+}
+
+// SuspendUser suspends an account and revokes its OAuth tokens
+func SuspendUser(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadTargetUser(c, database.DB)
+	if !ok {
+		return
+	}
+	if user.ID.String() == c.GetString("userID") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can't suspend yourself"})
+		return
+	}
+
+	now := time.Now()
+	user.Status = models.UserStatusSuspended
+	user.SuspendedAt = &now
+	user.SuspensionReason = req.Reason
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		if err := revokeTokens(tx, "user_id = ?", user.ID); err != nil {
+			return err
+		}
+		return writeAuditLog(tx, c, models.AuditLog{Action: models.AuditActionSuspend, TargetUserID: user.ID, Reason: req.Reason})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newAdminUserView(*user))
+}
+
+// ReactivateUser lifts a suspension
+func ReactivateUser(c *gin.Context) {
+	user, ok := loadTargetUser(c, database.DB)
+	if !ok {
+		return
+	}
+
+	user.Status = models.UserStatusActive
+	user.SuspendedAt = nil
+	user.SuspensionReason = ""
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		return writeAuditLog(tx, c, models.AuditLog{Action: models.AuditActionReactivate, TargetUserID: user.ID})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newAdminUserView(*user))
+}
+
+// RestoreUser restores a soft-deleted user. Erased accounts can't be restored.
+func RestoreUser(c *gin.Context) {
+	user, ok := loadTargetUser(c, database.DB.Unscoped())
+	if !ok {
+		return
+	}
+	if !user.DeletedAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is not deleted"})
+		return
+	}
+	if user.ErasedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Erased users can't be restored"})
+		return
+	}
+
+	var conflicts int64
+	if result := database.DB.Model(&models.User{}).Where("email = ? AND id <> ?", user.Email, user.ID).Count(&conflicts); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if conflicts > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another account uses this email"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(user).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return writeAuditLog(tx, c, models.AuditLog{Action: models.AuditActionRestore, TargetUserID: user.ID})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	user.DeletedAt = gorm.DeletedAt{}
+	c.JSON(http.StatusOK, newAdminUserView(*user))
+}
+
+// ImpersonateUser issues an audited, short-lived token that lets an admin act as a user
+func ImpersonateUser(c *gin.Context) {
+	var req struct {
+		Reason   string `json:"reason" binding:"required"`
+		Duration string `json:"duration"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ttl := defaultImpersonationTTL
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 || d > maxImpersonationTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duration must be a positive duration of at most " + maxImpersonationTTL.String()})
+			return
+		}
+		ttl = d
+	}
+
+	user, ok := loadTargetUser(c, database.DB)
+	if !ok {
+		return
+	}
+	if user.Status == models.UserStatusSuspended {
+		c.JSON(http.StatusConflict, gin.H{"error": "Suspended users can't be impersonated"})
+		return
+	}
+	if user.Role == models.UserRoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admins can't be impersonated"})
+		return
+	}
+
+	// The audit entry ID doubles as the token ID so the token can be traced back to it
+	expiresAt := time.Now().Add(ttl)
+	auditID := uuid.New()
+	err := writeAuditLog(database.DB, c, models.AuditLog{
+		ID:           auditID,
+		Action:       models.AuditActionImpersonate,
+		TargetUserID: user.ID,
+		Reason:       req.Reason,
+		ExpiresAt:    &expiresAt,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := auth.GenerateImpersonationToken(auditID.String(), user.ID.String(), user.Email, c.GetString("userID"), expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "expires_at": expiresAt, "audit_log_id": auditID})
+}
+
+// auditLogSort lists the fields audit log lists can be sorted by
+var auditLogSort = paging.Sort{
+	Fields:  map[string]string{"created_at": "created_at"},
+	Default: "-created_at",
+}
+
+// ListAuditLogs lists a page of admin actions, optionally filtered by target user and action
+func ListAuditLogs(c *gin.Context) {
+	req, err := paging.FromRequest(c, auditLogSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.DB.Model(&models.AuditLog{})
+	if target := c.Query("user_id"); target != "" {
+		query = query.Where("target_user_id = ?", target)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	var logs []models.AuditLog
+	if result := req.Apply(query).Find(&logs); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	page, err := paging.NewPage(database.DB, req, logs, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// loadTargetUser loads the user from the :id path parameter
+func loadTargetUser(c *gin.Context, db *gorm.DB) (*models.User, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false
+	}
+
+	var user models.User
+	result := db.First(&user, "id = ?", userID)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return nil, false
+	}
+	return &user, true
+}
+
+// writeAuditLog records an admin action performed by the current user
+func writeAuditLog(tx *gorm.DB, c *gin.Context, entry models.AuditLog) error {
+	actorID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		return err
+	}
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	entry.ActorID = actorID
+	return tx.Create(&entry).Error
+}
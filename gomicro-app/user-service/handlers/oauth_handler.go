@@ -0,0 +1,632 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/user-service/auth"
+	"github.com/ozturkeniss/gomicro-app/user-service/database"
+	"github.com/ozturkeniss/gomicro-app/user-service/models"
+	"github.com/ozturkeniss/gomicro-app/user-service/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	grantAuthorizationCode = "authorization_code"
+	grantClientCredentials = "client_credentials"
+
+	authorizationCodeTTL = 10 * time.Minute
+)
+
+var errInvalidClient = errors.New("invalid client credentials")
+
+type clientRegistrationRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes" binding:"required"`
+	GrantTypes   []string `json:"grant_types" binding:"required"`
+	Confidential bool     `json:"confidential"`
+}
+
+type authorizationRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type" binding:"required"`
+	ClientID            string `form:"client_id" json:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri" binding:"required"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	TenantID            string `form:"tenant_id" json:"tenant_id"`
+	Approve             bool   `form:"-" json:"approve"`
+
+	// tenant is the organization named by TenantID, set once the user's membership is checked
+	tenant *uuid.UUID
+}
+
+// RegisterOAuthClient registers a new third-party application owned by the current user
+func RegisterOAuthClient(c *gin.Context) {
+	ownerID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user in token"})
+		return
+	}
+
+	var req clientRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope := strings.Join(req.Scopes, " ")
+	if !auth.ValidScopes(scope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported scope requested"})
+		return
+	}
+	for _, grant := range req.GrantTypes {
+		switch grant {
+		case grantAuthorizationCode:
+			if len(req.RedirectURIs) == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "authorization_code clients require at least one redirect URI"})
+				return
+			}
+		case grantClientCredentials:
+			if !req.Confidential {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "client_credentials is only available to confidential clients"})
+				return
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported grant type: " + grant})
+			return
+		}
+	}
+	for _, uri := range req.RedirectURIs {
+		parsed, err := url.Parse(uri)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" || parsed.Fragment != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid redirect URI: " + uri})
+			return
+		}
+	}
+
+	clientID, err := auth.GenerateOpaqueToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate client ID"})
+		return
+	}
+
+	client := models.OAuthClient{
+		ID:           uuid.New(),
+		OwnerID:      ownerID,
+		Name:         req.Name,
+		ClientID:     clientID,
+		RedirectURIs: strings.Join(req.RedirectURIs, " "),
+		Scopes:       scope,
+		GrantTypes:   strings.Join(req.GrantTypes, " "),
+		Confidential: req.Confidential,
+	}
+
+	// The plain secret is only returned once, at registration time
+	var secret string
+	if req.Confidential {
+		secret, err = auth.GenerateOpaqueToken(32)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate client secret"})
+			return
+		}
+		client.SecretHash, err = utils.HashPassword(secret)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash client secret"})
+			return
+		}
+	}
+
+	if result := database.DB.Create(&client); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"client": client, "client_secret": secret})
+}
+
+// ListOAuthClients lists the clients owned by the current user
+func ListOAuthClients(c *gin.Context) {
+	var clients []models.OAuthClient
+	result := database.DB.Where("owner_id = ?", c.GetString("userID")).Find(&clients)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, clients)
+}
+
+// DeleteOAuthClient deletes a client owned by the current user and revokes its tokens
+func DeleteOAuthClient(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND owner_id = ?", id, c.GetString("userID")).Delete(&models.OAuthClient{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("client_id = ?", id).Delete(&models.OAuthConsent{}).Error; err != nil {
+			return err
+		}
+		return revokeTokens(tx, "client_id = ?", id)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Client deleted successfully"})
+}
+
+// Authorize validates an authorization request for the signed-in user. When the user
+// has already consented to the requested scopes a code is issued immediately,
+// otherwise the client details are returned so the consent screen can be shown.
+func Authorize(c *gin.Context) {
+	var req authorizationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, userID, ok := validateAuthorizationRequest(c, &req)
+	if !ok {
+		return
+	}
+
+	// Consent isn't recorded per organization, so access to one is always confirmed
+	var consent models.OAuthConsent
+	result := database.DB.Where("user_id = ? AND client_id = ?", userID, client.ID).First(&consent)
+	if result.Error == nil && req.tenant == nil && auth.ScopeSubset(req.Scope, consent.Scope) {
+		issueAuthorizationCode(c, client, userID, &req)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"consent_required": true,
+		"client":           gin.H{"client_id": client.ClientID, "name": client.Name},
+		"scopes":           auth.ParseScope(req.Scope),
+		"tenant_id":        req.tenant,
+	})
+}
+
+// ApproveAuthorization records the user's consent decision and redirects back to the client
+func ApproveAuthorization(c *gin.Context) {
+	var req authorizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, userID, ok := validateAuthorizationRequest(c, &req)
+	if !ok {
+		return
+	}
+
+	if !req.Approve {
+		c.JSON(http.StatusOK, gin.H{"redirect_to": redirectWith(req.RedirectURI, url.Values{
+			"error": {"access_denied"},
+			"state": {req.State},
+		})})
+		return
+	}
+
+	var consent models.OAuthConsent
+	result := database.DB.Where("user_id = ? AND client_id = ?", userID, client.ID).First(&consent)
+	switch {
+	case errors.Is(result.Error, gorm.ErrRecordNotFound):
+		consent = models.OAuthConsent{ID: uuid.New(), UserID: userID, ClientID: client.ID, Scope: req.Scope}
+		result = database.DB.Create(&consent)
+	case result.Error == nil:
+		consent.Scope = strings.Join(auth.ParseScope(consent.Scope+" "+req.Scope), " ")
+		result = database.DB.Save(&consent)
+	}
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	issueAuthorizationCode(c, client, userID, &req)
+}
+
+// Token implements the token endpoint for the authorization_code and client_credentials grants
+func Token(c *gin.Context) {
+	client, err := authenticateClient(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client", "error_description": err.Error()})
+		return
+	}
+
+	grantType := c.PostForm("grant_type")
+	if !containsField(client.GrantTypes, grantType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unauthorized_client", "error_description": "Grant type not allowed for this client"})
+		return
+	}
+
+	switch grantType {
+	case grantAuthorizationCode:
+		exchangeAuthorizationCode(c, client)
+	case grantClientCredentials:
+		scope := c.PostForm("scope")
+		if scope == "" {
+			scope = client.Scopes
+		}
+		if !auth.ScopeSubset(scope, client.Scopes) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope"})
+			return
+		}
+		// Product and order scopes act for a user within an organization
+		if auth.RequiresTenant(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope", "error_description": "Product and order scopes need a user's authorization"})
+			return
+		}
+		issueAccessToken(c, client, nil, "", scope, grantClientCredentials, nil)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_grant_type"})
+	}
+}
+
+// Introspect reports whether a token issued to the calling client is active (RFC 7662)
+func Introspect(c *gin.Context) {
+	client, err := authenticateClient(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client", "error_description": err.Error()})
+		return
+	}
+
+	claims, token, err := lookupToken(c.PostForm("token"))
+	if err != nil || token.ClientID != client.ID || !token.Active() {
+		c.JSON(http.StatusOK, gin.H{"active": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"active":     true,
+		"scope":      token.Scope,
+		"tenant_id":  token.TenantID,
+		"client_id":  client.ClientID,
+		"sub":        claims.Subject,
+		"username":   claims.Email,
+		"token_type": "Bearer",
+		"exp":        token.ExpiresAt.Unix(),
+		"iat":        token.CreatedAt.Unix(),
+	})
+}
+
+// Revoke revokes a token issued to the calling client (RFC 7009)
+func Revoke(c *gin.Context) {
+	client, err := authenticateClient(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client", "error_description": err.Error()})
+		return
+	}
+
+	// Unknown or foreign tokens are ignored so the endpoint doesn't leak token validity
+	_, token, err := lookupToken(c.PostForm("token"))
+	if err == nil && token.ClientID == client.ID {
+		if err := revokeTokens(database.DB, "id = ?", token.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// ListConsents lists the applications the current user has granted access to
+func ListConsents(c *gin.Context) {
+	var consents []models.OAuthConsent
+	result := database.DB.Preload("Client").Where("user_id = ?", c.GetString("userID")).Find(&consents)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, consents)
+}
+
+// RevokeConsent withdraws a consent and revokes every token issued under it
+func RevokeConsent(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid consent ID"})
+		return
+	}
+
+	userID := c.GetString("userID")
+	var consent models.OAuthConsent
+	if result := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&consent); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Consent not found"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&consent).Error; err != nil {
+			return err
+		}
+		return revokeTokens(tx, "client_id = ? AND user_id = ?", consent.ClientID, consent.UserID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Consent revoked successfully"})
+}
+
+// validateAuthorizationRequest checks the client, redirect URI, scopes and PKCE parameters.
+// It writes the error response itself and returns ok=false when the request is invalid.
+func validateAuthorizationRequest(c *gin.Context, req *authorizationRequest) (*models.OAuthClient, uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user in token"})
+		return nil, uuid.Nil, false
+	}
+
+	var client models.OAuthClient
+	if result := database.DB.Where("client_id = ?", req.ClientID).First(&client); result.Error != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_client"})
+		return nil, uuid.Nil, false
+	}
+
+	// Never redirect to an unregistered URI, so these errors are returned directly
+	if !containsField(client.RedirectURIs, req.RedirectURI) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "redirect_uri is not registered"})
+		return nil, uuid.Nil, false
+	}
+	if req.ResponseType != "code" || !containsField(client.GrantTypes, grantAuthorizationCode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_response_type"})
+		return nil, uuid.Nil, false
+	}
+	if req.Scope == "" {
+		req.Scope = client.Scopes
+	}
+	if !auth.ScopeSubset(req.Scope, client.Scopes) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope"})
+		return nil, uuid.Nil, false
+	}
+
+	// Product and order scopes are granted within one of the user's organizations
+	if auth.RequiresTenant(req.Scope) {
+		tenantID, err := uuid.Parse(req.TenantID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "tenant_id is required for product and order scopes"})
+			return nil, uuid.Nil, false
+		}
+		var membership models.Membership
+		if result := database.DB.Where("organization_id = ? AND user_id = ?", tenantID, userID).First(&membership); result.Error != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "You are not a member of this organization"})
+			return nil, uuid.Nil, false
+		}
+		req.tenant = &tenantID
+	}
+
+	// PKCE is mandatory for public clients and, when used, only S256 is accepted
+	if req.CodeChallenge == "" && !client.Confidential {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "code_challenge is required for public clients"})
+		return nil, uuid.Nil, false
+	}
+	if req.CodeChallenge != "" && req.CodeChallengeMethod != "S256" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "code_challenge_method must be S256"})
+		return nil, uuid.Nil, false
+	}
+
+	return &client, userID, true
+}
+
+// issueAuthorizationCode stores a single-use code and returns the client redirect URL
+func issueAuthorizationCode(c *gin.Context, client *models.OAuthClient, userID uuid.UUID, req *authorizationRequest) {
+	code, err := auth.GenerateOpaqueToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authorization code"})
+		return
+	}
+
+	authCode := models.OAuthAuthorizationCode{
+		ID:                  uuid.New(),
+		CodeHash:            auth.HashOpaqueToken(code),
+		ClientID:            client.ID,
+		UserID:              userID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               req.Scope,
+		TenantID:            req.tenant,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		ExpiresAt:           time.Now().Add(authorizationCodeTTL),
+	}
+	if result := database.DB.Create(&authCode); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redirect_to": redirectWith(req.RedirectURI, url.Values{
+		"code":  {code},
+		"state": {req.State},
+	})})
+}
+
+// exchangeAuthorizationCode redeems an authorization code for an access token
+func exchangeAuthorizationCode(c *gin.Context, client *models.OAuthClient) {
+	var authCode models.OAuthAuthorizationCode
+	result := database.DB.Where("code_hash = ? AND client_id = ?", auth.HashOpaqueToken(c.PostForm("code")), client.ID).First(&authCode)
+	if result.Error != nil || authCode.UsedAt != nil || time.Now().After(authCode.ExpiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant"})
+		return
+	}
+	if c.PostForm("redirect_uri") != authCode.RedirectURI {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant", "error_description": "redirect_uri mismatch"})
+		return
+	}
+	if authCode.CodeChallenge != "" && !auth.VerifyCodeChallenge(c.PostForm("code_verifier"), authCode.CodeChallenge) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant", "error_description": "code_verifier does not match"})
+		return
+	}
+
+	// Mark the code as used atomically so a replayed code can't be redeemed twice
+	now := time.Now()
+	result = database.DB.Model(&models.OAuthAuthorizationCode{}).
+		Where("id = ? AND used_at IS NULL", authCode.ID).
+		Update("used_at", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant"})
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, "id = ?", authCode.UserID); result.Error != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant"})
+		return
+	}
+
+	// The token takes the user's current role, and fails if they have left the organization
+	var membership *models.Membership
+	if authCode.TenantID != nil {
+		membership = &models.Membership{}
+		if result := database.DB.Where("organization_id = ? AND user_id = ?", *authCode.TenantID, user.ID).First(membership); result.Error != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant"})
+			return
+		}
+	}
+
+	issueAccessToken(c, client, &user.ID, user.Email, authCode.Scope, grantAuthorizationCode, membership)
+}
+
+// issueAccessToken records and signs a new access token and writes the token response.
+// Tokens for an organization carry its tenant and the user's role there. Product and
+// order services can't check revocation, so those tokens last no longer than tenant tokens.
+func issueAccessToken(c *gin.Context, client *models.OAuthClient, userID *uuid.UUID, email, scope, grantType string, membership *models.Membership) {
+	token := models.OAuthToken{
+		ID:        uuid.New(),
+		ClientID:  client.ID,
+		UserID:    userID,
+		Scope:     scope,
+		GrantType: grantType,
+		ExpiresAt: time.Now().Add(accessTokenTTL()),
+	}
+	tenantID, tenantRole := "", ""
+	if membership != nil {
+		token.TenantID = &membership.OrganizationID
+		if ttl := auth.TenantTokenTTL(); ttl < accessTokenTTL() {
+			token.ExpiresAt = time.Now().Add(ttl)
+		}
+		tenantID, tenantRole = membership.OrganizationID.String(), membership.Role
+	}
+	if result := database.DB.Create(&token); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	subject := ""
+	if userID != nil {
+		subject = userID.String()
+	}
+	signed, err := auth.GenerateAccessToken(token.ID.String(), subject, email, client.ClientID, scope, tenantID, tenantRole, token.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": signed,
+		"token_type":   "Bearer",
+		"expires_in":   int(time.Until(token.ExpiresAt).Seconds()),
+		"scope":        scope,
+	})
+}
+
+// authenticateClient authenticates the calling client using HTTP Basic or form credentials.
+// Public clients authenticate with their client_id only.
+func authenticateClient(c *gin.Context) (*models.OAuthClient, error) {
+	clientID, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID = c.PostForm("client_id")
+		secret = c.PostForm("client_secret")
+	}
+	if clientID == "" {
+		return nil, errInvalidClient
+	}
+
+	var client models.OAuthClient
+	if result := database.DB.Where("client_id = ?", clientID).First(&client); result.Error != nil {
+		return nil, errInvalidClient
+	}
+	if client.Confidential && !utils.CheckPasswordHash(secret, client.SecretHash) {
+		return nil, errInvalidClient
+	}
+	return &client, nil
+}
+
+// lookupToken parses a signed access token and loads its stored record
+func lookupToken(tokenString string) (*auth.Claims, *models.OAuthToken, error) {
+	claims, err := auth.ValidateJWT(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+	var token models.OAuthToken
+	if result := database.DB.First(&token, "id = ?", claims.ID); result.Error != nil {
+		return nil, nil, result.Error
+	}
+	return claims, &token, nil
+}
+
+// revokeTokens marks every still-active token matching the condition as revoked
+func revokeTokens(tx *gorm.DB, query string, args ...interface{}) error {
+	return tx.Model(&models.OAuthToken{}).
+		Where(query, args...).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now()).Error
+}
+
+// accessTokenTTL returns the configured OAuth access token lifetime
+func accessTokenTTL() time.Duration {
+	if v, err := time.ParseDuration(os.Getenv("OAUTH_ACCESS_TOKEN_TTL")); err == nil && v > 0 {
+		return v
+	}
+	return time.Hour
+}
+
+// containsField reports whether a space-delimited list contains value
+func containsField(list, value string) bool {
+	for _, field := range strings.Fields(list) {
+		if field == value {
+			return true
+		}
+	}
+	return false
+}
+
+// redirectWith appends non-empty query parameters to a redirect URI
+func redirectWith(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	q := u.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			q.Set(key, values[0])
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/user-service/auth"
+	"github.com/ozturkeniss/gomicro-app/user-service/database"
+	"github.com/ozturkeniss/gomicro-app/user-service/models"
+	"gorm.io/gorm"
+)
+
+var (
+	slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+	errLastOwner       = errors.New("an organization must keep at least one owner")
+	errOwnerOnlyAction = errors.New("only owners can remove owners")
+)
+
+// CreateOrganization creates an organization owned by the current user
+func CreateOrganization(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user in token"})
+		return
+	}
+
+	var req struct {
+		Name string `json:"name" binding:"required"`
+		Slug string `json:"slug" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !slugPattern.MatchString(req.Slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug may only contain lowercase letters, digits and dashes"})
+		return
+	}
+
+	org := models.Organization{ID: uuid.New(), Name: req.Name, Slug: req.Slug}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.Membership{
+			ID:             uuid.New(),
+			OrganizationID: org.ID,
+			UserID:         userID,
+			Role:           models.RoleOwner,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// ListOrganizations lists the organizations the current user belongs to
+func ListOrganizations(c *gin.Context) {
+	var memberships []models.Membership
+	result := database.DB.Preload("Organization").Where("user_id = ?", c.GetString("userID")).Find(&memberships)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, memberships)
+}
+
+// GetOrganization retrieves an organization and its members
+func GetOrganization(c *gin.Context) {
+	orgID, ok := organizationMember(c)
+	if !ok {
+		return
+	}
+
+	var org models.Organization
+	if result := database.DB.First(&org, "id = ?", orgID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	var members []models.Membership
+	if result := database.DB.Where("organization_id = ?", orgID).Find(&members); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organization": org, "members": members})
+}
+
+// AddMember adds an existing user to an organization by email
+func AddMember(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+		Role  string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	orgID, ok := organizationMember(c)
+	if !ok {
+		return
+	}
+	actor := c.MustGet("membership").(models.Membership)
+	if !actor.CanManageMembers() || (req.Role == models.RoleOwner && actor.Role != models.RoleOwner) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient organization role"})
+		return
+	}
+
+	var user models.User
+	if result := database.DB.Where("email = ?", req.Email).First(&user); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	membership := models.Membership{ID: uuid.New(), OrganizationID: orgID, UserID: user.ID, Role: req.Role}
+	if result := database.DB.Create(&membership); result.Error != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, membership)
+}
+
+// UpdateMemberRole changes a member's role; only owners may change roles
+func UpdateMemberRole(c *gin.Context) {
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	orgID, ok := organizationMember(c)
+	if !ok {
+		return
+	}
+	if c.MustGet("membership").(models.Membership).Role != models.RoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can change roles"})
+		return
+	}
+
+	var membership models.Membership
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ? AND user_id = ?", orgID, c.Param("userId")).First(&membership).Error; err != nil {
+			return err
+		}
+		if membership.Role == models.RoleOwner && req.Role != models.RoleOwner {
+			if err := ensureAnotherOwner(tx, orgID); err != nil {
+				return err
+			}
+		}
+		membership.Role = req.Role
+		return tx.Save(&membership).Error
+	})
+	if !writeMembershipError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, membership)
+}
+
+// RemoveMember removes a member from an organization; members may always remove themselves
+func RemoveMember(c *gin.Context) {
+	orgID, ok := organizationMember(c)
+	if !ok {
+		return
+	}
+	actor := c.MustGet("membership").(models.Membership)
+	if !actor.CanManageMembers() && actor.UserID.String() != c.Param("userId") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient organization role"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var membership models.Membership
+		if err := tx.Where("organization_id = ? AND user_id = ?", orgID, c.Param("userId")).First(&membership).Error; err != nil {
+			return err
+		}
+		if membership.Role == models.RoleOwner {
+			if actor.Role != models.RoleOwner {
+				return errOwnerOnlyAction
+			}
+			if err := ensureAnotherOwner(tx, orgID); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&membership).Error
+	})
+	if !writeMembershipError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// IssueOrganizationToken issues a short-lived token scoped to one of the user's
+// organizations. Product and order services only serve requests whose token
+// carries a tenant. AuthMiddleware has already turned away suspended accounts;
+// the token doesn't outlive the caller's own, and an impersonating admin's token
+// stays marked as impersonated.
+func IssueOrganizationToken(c *gin.Context) {
+	orgID, ok := organizationMember(c)
+	if !ok {
+		return
+	}
+	membership := c.MustGet("membership").(models.Membership)
+
+	token, err := auth.GenerateTenantJWT(c.GetString("userID"), c.GetString("email"), orgID.String(), membership.Role, c.GetString("impersonatorID"), c.GetTime("expiresAt"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "tenant_id": orgID, "role": membership.Role})
+}
+
+// organizationMember loads the current user's membership in the organization from
+// the :id path parameter and stores it on the context as "membership"
+func organizationMember(c *gin.Context) (uuid.UUID, bool) {
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return uuid.Nil, false
+	}
+
+	var membership models.Membership
+	result := database.DB.Where("organization_id = ? AND user_id = ?", orgID, c.GetString("userID")).First(&membership)
+	if result.Error != nil {
+		// Non-members can't tell whether the organization exists
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return uuid.Nil, false
+	}
+
+	c.Set("membership", membership)
+	return orgID, true
+}
+
+// ensureAnotherOwner fails when the organization has a single owner left
+func ensureAnotherOwner(tx *gorm.DB, orgID uuid.UUID) error {
+	var owners int64
+	if err := tx.Model(&models.Membership{}).Where("organization_id = ? AND role = ?", orgID, models.RoleOwner).Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return errLastOwner
+	}
+	return nil
+}
+
+// writeMembershipError writes the response for a failed membership change and reports whether err was nil
+func writeMembershipError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+	case errors.Is(err, errLastOwner):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errOwnerOnlyAction):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
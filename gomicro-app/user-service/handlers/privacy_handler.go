@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/user-service/clients"
+	"github.com/ozturkeniss/gomicro-app/user-service/database"
+	"github.com/ozturkeniss/gomicro-app/user-service/models"
+	"github.com/ozturkeniss/gomicro-app/user-service/privacy"
+	"github.com/ozturkeniss/gomicro-app/user-service/utils"
+	"gorm.io/gorm"
+)
+
+// ExportMyData streams a zip archive with everything we hold about the current user:
+// profile, sessions (issued tokens and consents), memberships, addresses and orders
+func ExportMyData(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user in token"})
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, "id = ?", userID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var (
+		addresses   []models.Address
+		tokens      []models.OAuthToken
+		consents    []models.OAuthConsent
+		memberships []models.Membership
+	)
+	queries := []*gorm.DB{
+		database.DB.Where("user_id = ?", userID).Find(&addresses),
+		database.DB.Where("user_id = ?", userID).Find(&tokens),
+		database.DB.Preload("Client").Where("user_id = ?", userID).Find(&consents),
+		database.DB.Preload("Organization").Where("user_id = ?", userID).Find(&memberships),
+	}
+	for _, result := range queries {
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+	}
+
+	// Orders are gathered before streaming starts so a failure can still be reported
+	orders, err := clients.ListUserOrders(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch orders"})
+		return
+	}
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", gin.H{
+			"id":         user.ID,
+			"name":       user.Name,
+			"email":      user.Email,
+			"created_at": user.CreatedAt,
+			"updated_at": user.UpdatedAt,
+		}},
+		{"addresses.json", addresses},
+		{"sessions.json", gin.H{"tokens": tokens, "consents": consents}},
+		{"organizations.json", memberships},
+		{"orders.json", orders},
+	}
+
+	filename := fmt.Sprintf("user-export-%s-%s.zip", userID, time.Now().UTC().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	for _, file := range files {
+		w, err := archive.Create(file.name)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		c.Error(err)
+	}
+}
+
+// EraseMyAccount anonymizes the current user's personal data across services and
+// deletes the account. The password must be confirmed.
+func EraseMyAccount(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user in token"})
+		return
+	}
+
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, "id = ?", userID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !utils.CheckPasswordHash(req.Password, user.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	request := models.ErasureRequest{ID: uuid.New(), UserID: userID, Status: models.ErasureStatusPending}
+	if result := database.DB.Create(&request); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	now := time.Now()
+	request.CompletedAt = &now
+	request.Status = models.ErasureStatusCompleted
+	eraseErr := privacy.Erase(c.Request.Context(), database.DB, userID)
+	if eraseErr != nil {
+		request.Status = models.ErasureStatusFailed
+		request.Error = eraseErr.Error()
+	}
+	if result := database.DB.Save(&request); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if eraseErr != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Erasure failed, please retry", "request": request})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account erased", "request": request})
+}
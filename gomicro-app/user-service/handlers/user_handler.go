@@ -1,12 +1,11 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/common/paging"
 	"github.com/ozturkeniss/gomicro-app/user-service/database"
 	"github.com/ozturkeniss/gomicro-app/user-service/models"
 	"github.com/ozturkeniss/gomicro-app/user-service/utils"
@@ -62,7 +61,14 @@ func GetUser(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-// UpdateUser updates a user by ID
+// userProfileRequest holds the profile fields users may change themselves. Role,
+// status and suspension are only changed through the admin endpoints.
+type userProfileRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Email string `json:"email" binding:"required,email"`
+}
+
+// UpdateUser updates a user's profile; users may update their own, admins anyone's
 func UpdateUser(c *gin.Context) {
 	id := c.Param("id")
 	userID, err := uuid.Parse(id)
@@ -71,14 +77,25 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	isAdmin := c.GetString("role") == models.UserRoleAdmin && c.GetString("impersonatorID") == "" && c.GetString("clientID") == ""
+	if userID.String() != c.GetString("userID") && !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only update your own profile"})
+		return
+	}
+
+	var req userProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	user.ID = userID
-	result := database.DB.Save(&user)
+	var user models.User
+	if result := database.DB.First(&user, "id = ?", userID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	result := database.DB.Model(&user).Updates(models.User{Name: req.Name, Email: req.Email})
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
@@ -107,14 +124,42 @@ func DeleteUser(c *gin.Context) {
 
 // ListUsers retrieves a list of users
 func ListUsers(c *gin.Context) {
+	req, err := paging.FromRequest(c, userSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total int64
+	if result := database.DB.Model(&models.User{}).Count(&total); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
 	var users []models.User
-	result := database.DB.Find(&users)
+	result := req.Apply(database.DB).Find(&users)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	page, err := paging.NewPage(database.DB, req, users, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// userSort lists the fields user lists can be sorted by
+var userSort = paging.Sort{
+	Fields: map[string]string{
+		"name":       "name",
+		"email":      "email",
+		"created_at": "created_at",
+	},
+	Default: "-created_at",
 }
 
 // RegisterUser handles user registration
@@ -171,6 +216,11 @@ func LoginUser(c *gin.Context) {
 		return
 	}
 
+	if user.Status == models.UserStatusSuspended {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is suspended"})
+		return
+	}
+
 	token, err := utils.GenerateJWT(user.ID.String(), user.Email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
@@ -3,11 +3,14 @@ package main
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/ozturkeniss/gomicro-app/user-service/database"
 	"github.com/ozturkeniss/gomicro-app/user-service/handlers"
+	"github.com/ozturkeniss/gomicro-app/user-service/middleware"
+	"github.com/ozturkeniss/gomicro-app/user-service/privacy"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	_ "github.com/ozturkeniss/gomicro-app/user-service/docs" // Swagger docs
@@ -38,6 +41,9 @@ func main() {
 	// Initialize database
 	database.InitDB()
 
+	// Hard-delete soft-deleted users once their retention period has passed
+	privacy.StartPurgeWorker(database.DB, time.Hour)
+
 	// Create Gin router
 	router := gin.Default()
 
@@ -55,9 +61,62 @@ func main() {
 			users.POST("/register", handlers.RegisterUser)
 			users.POST("/login", handlers.LoginUser)
 			users.GET("/:id", handlers.GetUser)
-			users.PUT("/:id", handlers.UpdateUser)
+			users.PUT("/:id", middleware.AuthMiddleware(), middleware.RequireScope("profile"), handlers.UpdateUser)
 			users.DELETE("/:id", handlers.DeleteUser)
 			users.GET("/", handlers.ListUsers)
+
+			me := users.Group("/me", middleware.AuthMiddleware(), middleware.RequireScope("profile"))
+			{
+				me.GET("/addresses", handlers.ListAddresses)
+				me.POST("/addresses", handlers.CreateAddress)
+				me.GET("/addresses/:addressId", handlers.GetAddress)
+				me.PUT("/addresses/:addressId", handlers.UpdateAddress)
+				me.DELETE("/addresses/:addressId", handlers.DeleteAddress)
+				me.POST("/export", middleware.RequireFirstParty(), middleware.ForbidImpersonation(), handlers.ExportMyData)
+				me.POST("/erase", middleware.RequireFirstParty(), middleware.ForbidImpersonation(), handlers.EraseMyAccount)
+			}
+		}
+
+		admin := api.Group("/admin", middleware.AuthMiddleware(), middleware.RequireAdmin())
+		{
+			admin.GET("/users", handlers.SearchUsers)
+			admin.POST("/users/:id/suspend", handlers.SuspendUser)
+			admin.POST("/users/:id/reactivate", handlers.ReactivateUser)
+			admin.POST("/users/:id/restore", handlers.RestoreUser)
+			admin.POST("/users/:id/impersonate", handlers.ImpersonateUser)
+			admin.GET("/audit-logs", handlers.ListAuditLogs)
+		}
+
+		oauth := api.Group("/oauth")
+		{
+			// Client-authenticated endpoints
+			oauth.POST("/token", handlers.Token)
+			oauth.POST("/introspect", handlers.Introspect)
+			oauth.POST("/revoke", handlers.Revoke)
+
+			// User-authenticated endpoints; neither clients nor impersonating admins may consent on the user's behalf
+			userOAuth := oauth.Group("", middleware.AuthMiddleware(), middleware.RequireFirstParty(), middleware.ForbidImpersonation())
+			{
+				userOAuth.GET("/authorize", handlers.Authorize)
+				userOAuth.POST("/authorize", handlers.ApproveAuthorization)
+				userOAuth.POST("/clients", handlers.RegisterOAuthClient)
+				userOAuth.GET("/clients", handlers.ListOAuthClients)
+				userOAuth.DELETE("/clients/:id", handlers.DeleteOAuthClient)
+				userOAuth.GET("/consents", handlers.ListConsents)
+				userOAuth.DELETE("/consents/:id", handlers.RevokeConsent)
+			}
+		}
+
+		// OAuth clients and impersonating admins can't manage organizations or mint tenant tokens
+		organizations := api.Group("/organizations", middleware.AuthMiddleware(), middleware.RequireFirstParty(), middleware.ForbidImpersonation())
+		{
+			organizations.POST("/", handlers.CreateOrganization)
+			organizations.GET("/", handlers.ListOrganizations)
+			organizations.GET("/:id", handlers.GetOrganization)
+			organizations.POST("/:id/token", handlers.IssueOrganizationToken)
+			organizations.POST("/:id/members", handlers.AddMember)
+			organizations.PUT("/:id/members/:userId", handlers.UpdateMemberRole)
+			organizations.DELETE("/:id/members/:userId", handlers.RemoveMember)
 		}
 	}
 
@@ -5,6 +5,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/ozturkeniss/gomicro-app/user-service/auth"
+	"github.com/ozturkeniss/gomicro-app/user-service/database"
+	"github.com/ozturkeniss/gomicro-app/user-service/models"
 )
 
 // AuthMiddleware is a middleware that validates JWT tokens
@@ -32,9 +34,96 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// OAuth access tokens are tracked so they can be revoked before they expire
+		if claims.ClientID != "" {
+			var token models.OAuthToken
+			if result := database.DB.First(&token, "id = ?", claims.ID); result.Error != nil || !token.Active() {
+				c.JSON(401, gin.H{"error": "Token has been revoked"})
+				c.Abort()
+				return
+			}
+		}
+
+		// Suspended and deleted accounts lose access immediately, whatever their token says
+		role := ""
+		if claims.UserID != "" {
+			var user models.User
+			if result := database.DB.First(&user, "id = ?", claims.UserID); result.Error != nil {
+				c.JSON(401, gin.H{"error": "User no longer exists"})
+				c.Abort()
+				return
+			}
+			if user.Status == models.UserStatusSuspended {
+				c.JSON(403, gin.H{"error": "Account is suspended"})
+				c.Abort()
+				return
+			}
+			role = user.Role
+		}
+
 		// Token geçerliyse, claims'i context'e ekle
 		c.Set("userID", claims.UserID)
 		c.Set("email", claims.Email)
+		c.Set("clientID", claims.ClientID)
+		c.Set("scope", claims.Scope)
+		c.Set("tenantID", claims.TenantID)
+		c.Set("tenantRole", claims.TenantRole)
+		c.Set("role", role)
+		c.Set("impersonatorID", claims.ImpersonatorID)
+		if claims.ExpiresAt != nil {
+			c.Set("expiresAt", claims.ExpiresAt.Time)
+		}
+		c.Next()
+	}
+}
+
+// RequireAdmin only admits platform admins acting as themselves
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != models.UserRoleAdmin || c.GetString("impersonatorID") != "" || c.GetString("clientID") != "" {
+			c.JSON(403, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// ForbidImpersonation blocks impersonation tokens from account-level actions
+// such as erasing or exporting the account
+func ForbidImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("impersonatorID") != "" {
+			c.JSON(403, gin.H{"error": "Not allowed while impersonating"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireFirstParty only admits the user's own sessions. OAuth access tokens are
+// rejected whatever their scope, so clients can't act on the user's account.
+func RequireFirstParty() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("clientID") != "" {
+			c.JSON(403, gin.H{"error": "Not allowed with an OAuth access token"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope rejects OAuth access tokens that were not granted the given scope.
+// First-party tokens issued at login carry no client and are not restricted.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("clientID") != "" && !auth.HasScope(c.GetString("scope"), scope) {
+			c.JSON(403, gin.H{"error": "Insufficient scope", "required_scope": scope})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 } 
\ No newline at end of file
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Address represents an entry in a user's address book
+type Address struct {
+	ID                uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID            uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	Label             string         `gorm:"size:100" json:"label"`
+	RecipientName     string         `gorm:"size:255;not null" json:"recipient_name"`
+	Line1             string         `gorm:"size:255;not null" json:"line1"`
+	Line2             string         `gorm:"size:255" json:"line2"`
+	City              string         `gorm:"size:100;not null" json:"city"`
+	Region            string         `gorm:"size:100" json:"region"`
+	PostalCode        string         `gorm:"size:20" json:"postal_code"`
+	Country           string         `gorm:"size:2;not null" json:"country"`
+	Phone             string         `gorm:"size:30" json:"phone"`
+	IsDefaultShipping bool           `gorm:"not null;default:false" json:"is_default_shipping"`
+	IsDefaultBilling  bool           `gorm:"not null;default:false" json:"is_default_billing"`
+	CreatedAt         time.Time      `gorm:"not null" json:"created_at"`
+	UpdatedAt         time.Time      `gorm:"not null" json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for the Address model
+func (Address) TableName() string {
+	return "addresses"
+}
+
+// AddressRequest is the payload for creating or updating an address
+type AddressRequest struct {
+	Label             string `json:"label"`
+	RecipientName     string `json:"recipient_name" binding:"required"`
+	Line1             string `json:"line1" binding:"required"`
+	Line2             string `json:"line2"`
+	City              string `json:"city" binding:"required"`
+	Region            string `json:"region"`
+	PostalCode        string `json:"postal_code"`
+	Country           string `json:"country" binding:"required,len=2"`
+	Phone             string `json:"phone"`
+	IsDefaultShipping bool   `json:"is_default_shipping"`
+	IsDefaultBilling  bool   `json:"is_default_billing"`
+}
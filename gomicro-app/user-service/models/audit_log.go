@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audited admin actions
+const (
+	AuditActionSuspend     = "user.suspend"
+	AuditActionReactivate  = "user.reactivate"
+	AuditActionRestore     = "user.restore"
+	AuditActionImpersonate = "user.impersonate"
+)
+
+// AuditLog records an administrative action taken on a user account
+type AuditLog struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	ActorID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"actor_id"`
+	Action       string     `gorm:"size:50;not null;index" json:"action"`
+	TargetUserID uuid.UUID  `gorm:"type:uuid;not null;index" json:"target_user_id"`
+	Reason       string     `gorm:"size:500" json:"reason,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	CreatedAt    time.Time  `gorm:"not null" json:"created_at"`
+}
+
+// TableName specifies the table name for the AuditLog model
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
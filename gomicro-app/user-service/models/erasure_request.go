@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Erasure request statuses
+const (
+	ErasureStatusPending   = "pending"
+	ErasureStatusCompleted = "completed"
+	ErasureStatusFailed    = "failed"
+)
+
+// ErasureRequest records a right-to-erasure request. It only references the user
+// by ID so it can be kept as evidence after the account has been purged.
+type ErasureRequest struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Status      string     `gorm:"size:20;not null" json:"status"`
+	Error       string     `gorm:"size:1000" json:"error,omitempty"`
+	CreatedAt   time.Time  `gorm:"not null" json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// TableName specifies the table name for the ErasureRequest model
+func (ErasureRequest) TableName() string {
+	return "erasure_requests"
+}
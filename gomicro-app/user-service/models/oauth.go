@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuthClient represents a third-party application registered for delegated access
+type OAuthClient struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	OwnerID      uuid.UUID `gorm:"type:uuid;not null;index" json:"owner_id"`
+	Name         string    `gorm:"size:255;not null" json:"name"`
+	ClientID     string    `gorm:"size:64;not null;unique" json:"client_id"`
+	SecretHash   string    `gorm:"size:255" json:"-"`
+	RedirectURIs string    `gorm:"size:2000;not null" json:"redirect_uris"`
+	Scopes       string    `gorm:"size:1000;not null" json:"scopes"`
+	GrantTypes   string    `gorm:"size:255;not null" json:"grant_types"`
+	Confidential bool      `gorm:"not null" json:"confidential"`
+	CreatedAt    time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the OAuthClient model
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// OAuthAuthorizationCode represents a single-use code issued by the authorization endpoint
+type OAuthAuthorizationCode struct {
+	ID                  uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	CodeHash            string     `gorm:"size:64;not null;unique"`
+	ClientID            uuid.UUID  `gorm:"type:uuid;not null;index"`
+	UserID              uuid.UUID  `gorm:"type:uuid;not null"`
+	RedirectURI         string     `gorm:"size:1000;not null"`
+	Scope               string     `gorm:"size:1000;not null"`
+	TenantID            *uuid.UUID `gorm:"type:uuid"`
+	CodeChallenge       string     `gorm:"size:128"`
+	CodeChallengeMethod string     `gorm:"size:10"`
+	ExpiresAt           time.Time  `gorm:"not null"`
+	UsedAt              *time.Time
+	CreatedAt           time.Time `gorm:"not null"`
+}
+
+// TableName specifies the table name for the OAuthAuthorizationCode model
+func (OAuthAuthorizationCode) TableName() string {
+	return "oauth_authorization_codes"
+}
+
+// OAuthConsent records the scopes a user has granted to a client
+type OAuthConsent struct {
+	ID        uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID    uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex:idx_oauth_consents_user_client" json:"user_id"`
+	ClientID  uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex:idx_oauth_consents_user_client" json:"client_id"`
+	Client    OAuthClient `gorm:"foreignKey:ClientID" json:"client"`
+	Scope     string      `gorm:"size:1000;not null" json:"scope"`
+	CreatedAt time.Time   `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time   `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the OAuthConsent model
+func (OAuthConsent) TableName() string {
+	return "oauth_consents"
+}
+
+// OAuthToken tracks an issued access token so it can be introspected and revoked
+type OAuthToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	ClientID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"client_id"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	Scope     string     `gorm:"size:1000;not null" json:"scope"`
+	TenantID  *uuid.UUID `gorm:"type:uuid" json:"tenant_id,omitempty"`
+	GrantType string     `gorm:"size:50;not null" json:"grant_type"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `gorm:"not null" json:"created_at"`
+}
+
+// TableName specifies the table name for the OAuthToken model
+func (OAuthToken) TableName() string {
+	return "oauth_tokens"
+}
+
+// Active reports whether the token is neither expired nor revoked
+func (t OAuthToken) Active() bool {
+	return t.RevokedAt == nil && time.Now().Before(t.ExpiresAt)
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Organization roles, ordered from most to least privileged
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Organization represents a tenant that owns products and orders
+type Organization struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Name      string         `gorm:"size:255;not null" json:"name"`
+	Slug      string         `gorm:"size:100;not null;unique" json:"slug"`
+	CreatedAt time.Time      `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"not null" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for the Organization model
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// Membership links a user to an organization with a per-organization role
+type Membership struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	OrganizationID uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_memberships_org_user" json:"organization_id"`
+	Organization   Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+	UserID         uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_memberships_org_user;index" json:"user_id"`
+	Role           string       `gorm:"size:20;not null" json:"role"`
+	CreatedAt      time.Time    `gorm:"not null" json:"created_at"`
+	UpdatedAt      time.Time    `gorm:"not null" json:"updated_at"`
+}
+
+// TableName specifies the table name for the Membership model
+func (Membership) TableName() string {
+	return "memberships"
+}
+
+// ValidRole reports whether role is a known organization role
+func ValidRole(role string) bool {
+	return role == RoleOwner || role == RoleAdmin || role == RoleMember
+}
+
+// CanManageMembers reports whether the membership may add or remove members
+func (m Membership) CanManageMembers() bool {
+	return m.Role == RoleOwner || m.Role == RoleAdmin
+}
@@ -7,6 +7,18 @@ import (
 	"gorm.io/gorm"
 )
 
+// Platform roles
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin"
+)
+
+// Account statuses
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+)
+
 // User represents the user model
 type User struct {
 	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
@@ -16,6 +28,13 @@ type User struct {
 	CreatedAt time.Time      `gorm:"not null"`
 	UpdatedAt time.Time      `gorm:"not null"`
 	DeletedAt gorm.DeletedAt `gorm:"index"`
+	ErasedAt  *time.Time     `gorm:"index"`
+
+	// Account administration
+	Role             string `gorm:"size:20;not null;default:user"`
+	Status           string `gorm:"size:20;not null;default:active;index"`
+	SuspendedAt      *time.Time
+	SuspensionReason string `gorm:"size:500"`
 }
 
 // TableName specifies the table name for the User model
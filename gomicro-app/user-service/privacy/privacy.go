@@ -0,0 +1,144 @@
+package privacy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ozturkeniss/gomicro-app/user-service/auth"
+	"github.com/ozturkeniss/gomicro-app/user-service/clients"
+	"github.com/ozturkeniss/gomicro-app/user-service/models"
+	"github.com/ozturkeniss/gomicro-app/user-service/utils"
+	"gorm.io/gorm"
+)
+
+// DefaultRetention is how long soft-deleted users are kept before being purged
+const DefaultRetention = 30 * 24 * time.Hour
+
+// Retention returns the configured retention period for soft-deleted users
+func Retention() time.Duration {
+	if v, err := time.ParseDuration(os.Getenv("USER_RETENTION_PERIOD")); err == nil && v > 0 {
+		return v
+	}
+	return DefaultRetention
+}
+
+// Erase anonymizes a user's personal data in every service and soft-deletes the
+// account. Orders keep their financial data; the user row is hard-deleted by the
+// purge worker once the retention period has passed.
+func Erase(ctx context.Context, db *gorm.DB, userID uuid.UUID) error {
+	// Orders and reviews live in other databases, so they are anonymized first:
+	// if that fails the account is left untouched and the request can be retried
+	if err := anonymizeElsewhere(ctx, userID); err != nil {
+		return err
+	}
+
+	// The password is replaced with an unusable random hash
+	secret, err := auth.GenerateOpaqueToken(32)
+	if err != nil {
+		return err
+	}
+	password, err := utils.HashPassword(secret)
+	if err != nil {
+		return err
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := deletePersonalData(tx, []uuid.UUID{userID}); err != nil {
+			return err
+		}
+		now := time.Now()
+		err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"name":      "Deleted user",
+			"email":     fmt.Sprintf("erased+%s@invalid", userID),
+			"password":  password,
+			"erased_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, "id = ?", userID).Error
+	})
+}
+
+// PurgeDeletedUsers hard-deletes users that were soft-deleted before the retention
+// cutoff, together with any personal data still attached to them
+func PurgeDeletedUsers(ctx context.Context, db *gorm.DB, retention time.Duration) (int, error) {
+	var users []models.User
+	cutoff := time.Now().Add(-retention)
+	if err := db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Find(&users).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, user := range users {
+		// Accounts deleted without going through erasure still have PII in other services
+		if user.ErasedAt == nil {
+			if err := anonymizeElsewhere(ctx, user.ID); err != nil {
+				log.Printf("[ERROR] Failed to anonymize data of user %s: %v", user.ID, err)
+				continue
+			}
+		}
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := deletePersonalData(tx, []uuid.UUID{user.ID}); err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(&models.User{}, "id = ?", user.ID).Error
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// StartPurgeWorker periodically purges soft-deleted users past the retention period
+func StartPurgeWorker(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purged, err := PurgeDeletedUsers(context.Background(), db, Retention())
+			if err != nil {
+				log.Printf("[ERROR] User purge failed: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("[INFO] Purged %d deleted users", purged)
+			}
+		}
+	}()
+}
+
+// anonymizeElsewhere strips the user's personal data from order-service (orders,
+// carts and payments) and product-service (reviews)
+func anonymizeElsewhere(ctx context.Context, userID uuid.UUID) error {
+	if err := clients.AnonymizeUserOrders(ctx, userID); err != nil {
+		return fmt.Errorf("anonymize orders: %w", err)
+	}
+	if err := clients.AnonymizeUserReviews(ctx, userID); err != nil {
+		return fmt.Errorf("anonymize reviews: %w", err)
+	}
+	return nil
+}
+
+// deletePersonalData removes the data linked to the users that has no value once they are gone
+func deletePersonalData(tx *gorm.DB, userIDs []uuid.UUID) error {
+	if err := tx.Unscoped().Where("user_id IN ?", userIDs).Delete(&models.Address{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id IN ?", userIDs).Delete(&models.OAuthConsent{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id IN ?", userIDs).Delete(&models.OAuthAuthorizationCode{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id IN ?", userIDs).Delete(&models.OAuthToken{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id IN ?", userIDs).Delete(&models.Membership{}).Error
+}
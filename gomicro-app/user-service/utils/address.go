@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ozturkeniss/gomicro-app/user-service/models"
+)
+
+// countryRule describes the address format of a country we ship to
+type countryRule struct {
+	postalCode     *regexp.Regexp
+	postalRequired bool
+	regionRequired bool
+}
+
+// countryRules lists the supported shipping countries by ISO 3166-1 alpha-2 code
+var countryRules = map[string]countryRule{
+	"US": {regexp.MustCompile(`^\d{5}(-\d{4})?$`), true, true},
+	"CA": {regexp.MustCompile(`^[A-Z]\d[A-Z] \d[A-Z]\d$`), true, true},
+	"AU": {regexp.MustCompile(`^\d{4}$`), true, true},
+	"GB": {regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? \d[A-Z]{2}$`), true, false},
+	"IE": {regexp.MustCompile(`^[A-Z]\d[\dW] [A-Z\d]{4}$`), false, false},
+	"DE": {regexp.MustCompile(`^\d{5}$`), true, false},
+	"FR": {regexp.MustCompile(`^\d{5}$`), true, false},
+	"ES": {regexp.MustCompile(`^\d{5}$`), true, false},
+	"IT": {regexp.MustCompile(`^\d{5}$`), true, false},
+	"TR": {regexp.MustCompile(`^\d{5}$`), true, false},
+	"NL": {regexp.MustCompile(`^\d{4} [A-Z]{2}$`), true, false},
+	"BE": {regexp.MustCompile(`^\d{4}$`), true, false},
+	"AT": {regexp.MustCompile(`^\d{4}$`), true, false},
+	"CH": {regexp.MustCompile(`^\d{4}$`), true, false},
+	"SE": {regexp.MustCompile(`^\d{3} \d{2}$`), true, false},
+	"PL": {regexp.MustCompile(`^\d{2}-\d{3}$`), true, false},
+	"JP": {regexp.MustCompile(`^\d{3}-\d{4}$`), true, true},
+}
+
+// postalSpaceFromEnd gives, for countries whose postal codes contain a space,
+// how many characters follow the space
+var postalSpaceFromEnd = map[string]int{"CA": 3, "GB": 3, "IE": 4, "NL": 2, "SE": 2}
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9 ()-]{6,20}$`)
+
+// NormalizeAddress trims fields and brings country and postal code into canonical form
+func NormalizeAddress(address *models.Address) {
+	address.Label = strings.TrimSpace(address.Label)
+	address.RecipientName = strings.TrimSpace(address.RecipientName)
+	address.Line1 = strings.TrimSpace(address.Line1)
+	address.Line2 = strings.TrimSpace(address.Line2)
+	address.City = strings.TrimSpace(address.City)
+	address.Region = strings.TrimSpace(address.Region)
+	address.Phone = strings.TrimSpace(address.Phone)
+	address.Country = strings.ToUpper(strings.TrimSpace(address.Country))
+
+	postal := strings.ToUpper(strings.Join(strings.Fields(address.PostalCode), ""))
+	if n, ok := postalSpaceFromEnd[address.Country]; ok && len(postal) > n {
+		postal = postal[:len(postal)-n] + " " + postal[len(postal)-n:]
+	}
+	address.PostalCode = postal
+}
+
+// ValidateAddress checks an address against the rules of its country
+func ValidateAddress(address *models.Address) error {
+	rule, ok := countryRules[address.Country]
+	if !ok {
+		return fmt.Errorf("shipping to country %q is not supported", address.Country)
+	}
+	if address.PostalCode == "" {
+		if rule.postalRequired {
+			return fmt.Errorf("postal code is required for %s", address.Country)
+		}
+	} else if !rule.postalCode.MatchString(address.PostalCode) {
+		return fmt.Errorf("postal code %q is not valid for %s", address.PostalCode, address.Country)
+	}
+	if rule.regionRequired && address.Region == "" {
+		return fmt.Errorf("region is required for %s", address.Country)
+	}
+	if address.Phone != "" && !phonePattern.MatchString(address.Phone) {
+		return fmt.Errorf("phone number %q is not valid", address.Phone)
+	}
+	return nil
+}